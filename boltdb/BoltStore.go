@@ -2,6 +2,7 @@ package boltdb
 
 import (
 	"encoding/json"
+	"errors"
 	"expvar"
 	"fmt"
 	"io"
@@ -34,6 +35,9 @@ type (
 )
 
 var (
+	// ErrLocked will be returned if a database is locked by another process.
+	ErrLocked = errors.New("database is locked by another process")
+
 	saves    = expvar.NewInt("database_saves")
 	deletes  = expvar.NewInt("database_deletes")
 	applied  = expvar.NewInt("database_applied")
@@ -57,6 +61,33 @@ func NewBoltStore(path string) (*BoltStore, error) {
 	return d, nil
 }
 
+// NewReadOnlyStore will open an existing database for reading only. A
+// running core holds an exclusive lock on the database. If the lock is not
+// released within timeout, ErrLocked is returned.
+func NewReadOnlyStore(path string, timeout time.Duration) (*BoltStore, error) {
+	_, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &BoltStore{
+		dbMutex:       new(sync.RWMutex),
+		broadcastFrom: math.MaxUint64,
+		listenersLock: new(sync.RWMutex),
+	}
+
+	err = d.openOptions(path, &bbolt.Options{Timeout: timeout, ReadOnly: true})
+	if errors.Is(err, bbolt.ErrTimeout) {
+		return nil, ErrLocked
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
 // Close will close the database. Accessing the database after this will
 // result in a deadlock.
 func (d *BoltStore) Close() error {
@@ -67,14 +98,25 @@ func (d *BoltStore) Close() error {
 
 // open will open the underlying file storage.
 func (d *BoltStore) open(filepath string) error {
+	return d.openOptions(filepath, &bbolt.Options{Timeout: 1 * time.Second})
+}
+
+// openOptions will open the underlying file storage using options.
+func (d *BoltStore) openOptions(filepath string, options *bbolt.Options) error {
 	db, err := storm.Open(
 		filepath,
-		storm.BoltOptions(0600, &bbolt.Options{Timeout: 1 * time.Second}),
+		storm.BoltOptions(0600, options),
 	)
 	if err != nil {
 		return err
 	}
 
+	if options.ReadOnly {
+		d.db = db
+
+		return nil
+	}
+
 	var st syscall.Stat_t
 
 	// If possible, set owner to the same as parent directory. Fail silently.
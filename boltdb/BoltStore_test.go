@@ -2,6 +2,7 @@ package boltdb
 
 import (
 	"fmt"
+	"io/ioutil"
 	"math/rand"
 	"os"
 	"path"
@@ -41,6 +42,45 @@ func TestDatabaseOpenFail(t *testing.T) {
 	}
 }
 
+func TestNewReadOnlyStore(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "TestNewReadOnlyStore")
+	defer os.RemoveAll(dir)
+
+	p := path.Join(dir, "gansoi.db")
+
+	_, err := NewReadOnlyStore(p, 50*time.Millisecond)
+	if err == nil {
+		t.Fatalf("NewReadOnlyStore() did not fail for missing database")
+	}
+
+	db, err := NewBoltStore(p)
+	if err != nil {
+		t.Fatalf("NewBoltStore() failed: %s", err.Error())
+	}
+
+	d := &data{A: "a"}
+	d.ID = "a"
+	db.ProcessLogEntry(database.NewLogEntry(database.CommandSave, d))
+
+	_, err = NewReadOnlyStore(p, 50*time.Millisecond)
+	if err != ErrLocked {
+		t.Fatalf("NewReadOnlyStore() did not return ErrLocked for a locked database, got %v", err)
+	}
+
+	db.Close()
+
+	ro, err := NewReadOnlyStore(p, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewReadOnlyStore() failed: %s", err.Error())
+	}
+	defer ro.Close()
+
+	err = ro.One("ID", "a", d)
+	if err != nil {
+		t.Fatalf("One() failed on read-only store: %s", err.Error())
+	}
+}
+
 func TestBoltStoreSave(t *testing.T) {
 	db := NewTestStore()
 
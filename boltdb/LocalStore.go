@@ -0,0 +1,44 @@
+package boltdb
+
+import (
+	"math"
+	"sync"
+)
+
+type (
+	// LocalStore is a database local to a single node. Nothing is replicated
+	// through Raft, and changes will not be broadcast. It's suited for data
+	// only relevant to the local node, like raw check results.
+	LocalStore struct {
+		BoltStore
+	}
+)
+
+// NewLocalStore will instantiate a new LocalStore. path will be created if it
+// doesn't exist.
+func NewLocalStore(path string) (*LocalStore, error) {
+	d := BoltStore{
+		dbMutex:       new(sync.RWMutex),
+		broadcastFrom: math.MaxUint64,
+		listenersLock: new(sync.RWMutex),
+	}
+
+	err := d.open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LocalStore{
+		BoltStore: d,
+	}, nil
+}
+
+// Save will save an object to the local database.
+func (l *LocalStore) Save(data interface{}) error {
+	return l.save(data)
+}
+
+// Delete will delete an object from the local database.
+func (l *LocalStore) Delete(data interface{}) error {
+	return l.delete(data)
+}
@@ -0,0 +1,58 @@
+package boltdb
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/gansoi/gansoi/database"
+)
+
+var _ database.ReadWriter = (*LocalStore)(nil)
+
+func TestLocalStore(t *testing.T) {
+	p := path.Join(os.TempDir(), fmt.Sprintf(".gansoi-local-%d.db", rand.Int63()))
+	defer os.Remove(p)
+
+	db, err := NewLocalStore(p)
+	if err != nil {
+		t.Fatalf("NewLocalStore() failed: %s", err.Error())
+	}
+
+	d := data{
+		A: "hello",
+	}
+	d.ID = "local"
+
+	err = db.Save(&d)
+	if err != nil {
+		t.Fatalf("Save() failed: %s", err.Error())
+	}
+
+	var read data
+	err = db.One("ID", "local", &read)
+	if err != nil || read.A != "hello" {
+		t.Fatalf("One() failed to read saved data: %v", err)
+	}
+
+	err = db.Delete(&d)
+	if err != nil {
+		t.Fatalf("Delete() failed: %s", err.Error())
+	}
+
+	err = db.One("ID", "local", &read)
+	if err != database.ErrNotFound {
+		t.Fatalf("One() returned deleted data")
+	}
+
+	db.Close()
+}
+
+func TestLocalStoreFail(t *testing.T) {
+	_, err := NewLocalStore("/dev/null/impossible")
+	if err == nil {
+		t.Fatalf("NewLocalStore() did not fail on invalid path")
+	}
+}
@@ -1,7 +1,9 @@
 package checks
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -11,24 +13,102 @@ import (
 	"github.com/gansoi/gansoi/database"
 	"github.com/gansoi/gansoi/plugins"
 	"github.com/gansoi/gansoi/transports"
+	"github.com/gansoi/gansoi/transports/ssh"
 )
 
 type (
 	// Check defines a check to be conducted by Gansoi.
 	Check struct {
-		database.Object `storm:"inline"`
-		Name            string          `json:"name" validate:"required"`
-		AgentID         string          `json:"agent" validate:"required"`
-		Hosts           []string        `json:"hosts"`
-		Interval        time.Duration   `json:"interval"`
-		Arguments       json.RawMessage `json:"arguments"`
-		Expressions     []string        `json:"expressions"`
-		ContactGroups   []string        `json:"contactgroups"`
+		database.Object     `storm:"inline"`
+		Name                string          `json:"name" validate:"required"`
+		AgentID             string          `json:"agent" validate:"required"`
+		Hosts               []string        `json:"hosts"`
+		HostSelector        string          `json:"hostselector"`
+		Interval            time.Duration   `json:"interval"`
+		Schedule            string          `json:"schedule"`
+		TimeZone            string          `json:"timezone"`
+		Timeout             time.Duration   `json:"timeout"`
+		RetryInterval       time.Duration   `json:"retryinterval"`
+		MaxAttempts         int             `json:"maxattempts" validate:"min=0"`
+		Nodes               int             `json:"nodes" validate:"min=-1"`
+		Quorum              int             `json:"quorum" validate:"min=0"`
+		Arguments           json.RawMessage `json:"arguments"`
+		Expressions         []string        `json:"expressions"`
+		CriticalExpressions []string        `json:"criticalexpressions"`
+		WarningExpressions  []string        `json:"warningexpressions"`
+		ContactGroups       []string        `json:"contactgroups"`
+		DependsOn           []string        `json:"dependson"`
+		FlapDetection       FlapDetection   `json:"flapdetection"`
+		Policy              Policy          `json:"policy"`
 	}
+
+	// Policy describes how the results of a check are reduced to a state.
+	Policy struct {
+		// Window is the number of results considered. If zero, the
+		// evaluator default is used.
+		Window int `json:"window" validate:"min=0"`
+
+		// Mode is one of PolicyMajority, PolicyConsecutive or
+		// PolicyPercentage. If empty, PolicyMajority is used.
+		Mode string `json:"mode" validate:"omitempty,oneof=majority consecutive percentage"`
+
+		// Threshold is the percentage of failing results needed to fail in
+		// PolicyPercentage mode. If zero, DefaultThreshold is used.
+		Threshold float64 `json:"threshold" validate:"min=0,max=100"`
+	}
+
+	// FlapDetection configures flap detection for a check. A check is
+	// flapping when the percentage of evaluations changing state within the
+	// window reaches High, and stops flapping when it drops below Low.
+	FlapDetection struct {
+		// Window is the number of evaluations considered. Zero disables flap
+		// detection.
+		Window int `json:"window" validate:"min=0"`
+
+		// High is the percentage of state changes needed to start flapping.
+		High float64 `json:"high" validate:"min=0,max=100"`
+
+		// Low is the percentage of state changes needed to keep flapping.
+		Low float64 `json:"low" validate:"min=0,max=100"`
+	}
+)
+
+const (
+	// AllNodes can be used as Check.Nodes to run a check from all nodes.
+	AllNodes = -1
+
+	// DefaultTimeout is used for checks with no timeout set.
+	DefaultTimeout = time.Minute
+
+	// PolicyMajority will use the state of the majority of results.
+	PolicyMajority = "majority"
+
+	// PolicyConsecutive will change state when all results in the window
+	// agree.
+	PolicyConsecutive = "consecutive"
+
+	// PolicyPercentage will fail when at least Threshold percent of the
+	// results in the window fail.
+	PolicyPercentage = "percentage"
+
+	// DefaultThreshold is used as Policy.Threshold if not set.
+	DefaultThreshold = 50.0
+
+	// DefaultFlapHigh is used as FlapDetection.High if not set.
+	DefaultFlapHigh = 30.0
+
+	// DefaultFlapLow is used as FlapDetection.Low if not set.
+	DefaultFlapLow = 15.0
 )
 
 // RunCheck will run a check and return a CheckResult.
-func RunCheck(transport transports.Transport, check *Check) (checkResult *CheckResult) {
+func RunCheck(transport transports.Transport, check *Check) *CheckResult {
+	return execute(transport, check, nil)
+}
+
+// execute will run a check and return a CheckResult. previous is the result
+// from the last run, and will be available to expressions.
+func execute(transport transports.Transport, check *Check, previous *CheckResult) (checkResult *CheckResult) {
 	agentResult := plugins.NewAgentResult()
 	checkResult = &CheckResult{
 		TimeStamp: time.Now(),
@@ -36,6 +116,8 @@ func RunCheck(transport transports.Transport, check *Check) (checkResult *CheckR
 	}
 
 	defer func() {
+		checkResult.Duration = time.Since(checkResult.TimeStamp)
+
 		err := recover()
 
 		if err != nil {
@@ -52,30 +134,45 @@ func RunCheck(transport transports.Transport, check *Check) (checkResult *CheckR
 		return checkResult
 	}
 
-	switch a := agent.(type) {
-	case plugins.RemoteAgent:
-		if transport == nil {
-			checkResult.Error = "no host"
+	ctx, cancel := context.WithTimeout(context.Background(), check.timeout())
+	defer cancel()
 
-			return checkResult
-		}
+	remote := plugins.AdaptRemoteAgent(agent)
+	local := plugins.AdaptAgent(agent)
+
+	// Agents implementing both interfaces will run locally when no host is
+	// given.
+	switch {
+	case remote != nil && transport != nil:
+		err = remote.RemoteCheckContext(ctx, transport, agentResult)
+	case local != nil:
+		err = local.CheckContext(ctx, agentResult)
+	case remote != nil:
+		checkResult.Error = "no host"
 
-		err = a.RemoteCheck(transport, agentResult)
-	case plugins.Agent:
-		err = a.Check(agentResult)
+		return checkResult
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		timeouts.Add(1)
+		checkResult.Error = fmt.Sprintf("timeout after %s", check.timeout())
+
+		return checkResult
 	}
 
 	if err != nil {
+		var severityErr *plugins.SeverityError
+		if errors.As(err, &severityErr) {
+			checkResult.Severity = severityErr.Severity
+		}
+
 		checkResult.Error = err.Error()
 
 		return checkResult
 	}
 
 	// If any expressions is defined, we try to evaluate them until one fails.
-	if len(check.Expressions) > 0 {
-		err = check.Evaluate(agentResult)
-	}
-
+	checkResult.Severity, err = check.Evaluate(checkResult, previous)
 	if err != nil {
 		checkResult.Error = err.Error()
 	}
@@ -83,10 +180,37 @@ func RunCheck(transport transports.Transport, check *Check) (checkResult *CheckR
 	return checkResult
 }
 
-// Evaluate will evaluate the CheckResult based on a slice of expressions.
-func (c *Check) Evaluate(result plugins.AgentResult) error {
-	for _, exp := range c.Expressions {
-		e, err := govaluate.NewEvaluableExpression(exp)
+// Evaluate will evaluate the CheckResult based on Expressions,
+// CriticalExpressions and WarningExpressions in that order. previous is the
+// result from the last run of the same check/host pair, and can be nil. If an
+// expression fails, the severity is returned together with the error. A
+// failing expression from Expressions has no severity, and is simply down.
+func (c *Check) Evaluate(current *CheckResult, previous *CheckResult) (string, error) {
+	functions := expressionFunctions(current, previous)
+
+	groups := []struct {
+		severity    string
+		expressions []string
+	}{
+		{"", c.Expressions},
+		{SeverityCritical, c.CriticalExpressions},
+		{SeverityWarning, c.WarningExpressions},
+	}
+
+	for _, group := range groups {
+		err := evaluate(group.expressions, current.Results, functions)
+		if err != nil {
+			return group.severity, err
+		}
+	}
+
+	return "", nil
+}
+
+// evaluate will evaluate expressions until one fails.
+func evaluate(expressions []string, result plugins.AgentResult, functions map[string]govaluate.ExpressionFunction) error {
+	for _, exp := range expressions {
+		e, err := govaluate.NewEvaluableExpressionWithFunctions(exp, functions)
 		if err != nil {
 			return err
 		}
@@ -110,9 +234,215 @@ func (c *Check) Evaluate(result plugins.AgentResult) error {
 	return nil
 }
 
+// WindowSize returns the window size, or def if not set.
+func (p *Policy) WindowSize(def int) int {
+	if p.Window <= 0 {
+		return def
+	}
+
+	return p.Window
+}
+
+// ThresholdPercent returns the threshold with the default applied.
+func (p *Policy) ThresholdPercent() float64 {
+	if p.Threshold <= 0 {
+		return DefaultThreshold
+	}
+
+	return p.Threshold
+}
+
+// Thresholds returns the high and low thresholds in percent, with defaults
+// applied.
+func (f *FlapDetection) Thresholds() (float64, float64) {
+	high, low := f.High, f.Low
+
+	if high <= 0 {
+		high = DefaultFlapHigh
+	}
+
+	if low <= 0 {
+		low = DefaultFlapLow
+	}
+
+	if low > high {
+		low = high
+	}
+
+	return high, low
+}
+
+// timeout returns the timeout for a single run of the check.
+func (c *Check) timeout() time.Duration {
+	if c.Timeout <= 0 {
+		return DefaultTimeout
+	}
+
+	return c.Timeout
+}
+
+// MultiNode returns true if the check should be executed from more than one
+// node.
+func (c *Check) MultiNode() bool {
+	return c.Nodes > 1 || c.Nodes == AllNodes
+}
+
+// Remote returns true if the check should be executed on remote hosts.
+func (c *Check) Remote() bool {
+	return len(c.Hosts) > 0 || c.HostSelector != ""
+}
+
+// HostIDs returns the IDs of the hosts the check should be executed on. Hosts
+// listed in Hosts are always included, and all hosts with labels matching
+// HostSelector are added.
+func (c *Check) HostIDs(db database.Reader) ([]string, error) {
+	hostIDs := append([]string{}, c.Hosts...)
+
+	if c.HostSelector == "" {
+		return hostIDs, nil
+	}
+
+	sel, err := parseSelector(c.HostSelector)
+	if err != nil {
+		return hostIDs, err
+	}
+
+	var hosts []ssh.SSH
+
+	err = db.All(&hosts, -1, 0, false)
+	if err != nil {
+		return hostIDs, err
+	}
+
+	seen := make(map[string]bool)
+	for _, id := range hostIDs {
+		seen[id] = true
+	}
+
+	for _, host := range hosts {
+		if !seen[host.ID] && sel.matches(host.Labels) {
+			seen[host.ID] = true
+			hostIDs = append(hostIDs, host.ID)
+		}
+	}
+
+	return hostIDs, nil
+}
+
 // Validate implements database.Validator.
-func (c *Check) Validate(_ database.Reader) error {
+func (c *Check) Validate(db database.Reader) error {
 	v := validator.New()
 
-	return v.Struct(c)
+	err := v.Struct(c)
+	if err != nil {
+		return err
+	}
+
+	if c.Passive() {
+		err = c.validatePassive()
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.Nodes > 0 && c.Quorum > c.Nodes {
+		return fmt.Errorf("quorum cannot be higher than the number of nodes")
+	}
+
+	// Results from all nodes are evaluated together, there is no single
+	// series of attempts to count.
+	if c.MaxAttempts > 0 && c.MultiNode() {
+		return fmt.Errorf("max attempts cannot be used with multiple nodes")
+	}
+
+	// Attempts are counted result by result, a policy would be ignored.
+	if c.MaxAttempts > 0 && c.Policy != (Policy{}) {
+		return fmt.Errorf("max attempts cannot be used with a policy")
+	}
+
+	if c.FlapDetection.High > 0 && c.FlapDetection.Low > c.FlapDetection.High {
+		return fmt.Errorf("flap detection low threshold cannot be higher than the high threshold")
+	}
+
+	if c.HostSelector != "" {
+		_, err = parseSelector(c.HostSelector)
+		if err != nil {
+			return fmt.Errorf("invalid host selector: %s", err.Error())
+		}
+	}
+
+	functions := expressionFunctions(&CheckResult{}, nil)
+
+	var expressions []string
+	expressions = append(expressions, c.Expressions...)
+	expressions = append(expressions, c.CriticalExpressions...)
+	expressions = append(expressions, c.WarningExpressions...)
+
+	for _, exp := range expressions {
+		_, err = govaluate.NewEvaluableExpressionWithFunctions(exp, functions)
+		if err != nil {
+			return fmt.Errorf("invalid expression '%s': %s", exp, err.Error())
+		}
+	}
+
+	if c.Schedule != "" {
+		_, err = parseSchedule(c.Schedule, c.TimeZone)
+		if err != nil {
+			return fmt.Errorf("invalid schedule: %s", err.Error())
+		}
+	}
+
+	for _, id := range c.DependsOn {
+		if id == c.ID {
+			return fmt.Errorf("check cannot depend on itself")
+		}
+
+		var parent Check
+
+		err = db.One("ID", id, &parent)
+		if err != nil {
+			return fmt.Errorf("dependency %s not found", id)
+		}
+	}
+
+	return c.validateDependencyCycle(db)
+}
+
+// validateDependencyCycle will follow DependsOn through all checks and
+// return an error if it leads back to c.
+func (c *Check) validateDependencyCycle(db database.Reader) error {
+	// A new check cannot be depended on yet.
+	if c.ID == "" {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	queue := append([]string{}, c.DependsOn...)
+
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		var parent Check
+
+		err := db.One("ID", id, &parent)
+		if err != nil {
+			continue
+		}
+
+		for _, parentID := range parent.DependsOn {
+			if parentID == c.ID {
+				return fmt.Errorf("dependency cycle, %s depends on %s", id, c.ID)
+			}
+		}
+
+		queue = append(queue, parent.DependsOn...)
+	}
+
+	return nil
 }
@@ -16,11 +16,27 @@ type (
 		HostID      string              `json:"host_id"`
 		Node        string              `json:"node_id,omitempty"`
 		Error       string              `json:"error"`
+		Severity    string              `json:"severity,omitempty"`
 		TimeStamp   time.Time           `json:"timestamp"`
+		Duration    time.Duration       `json:"duration,omitempty"`
 		Results     plugins.AgentResult `json:"results"`
 	}
 )
 
+const (
+	// SeverityWarning is used as CheckResult.Severity when a warning
+	// expression failed.
+	SeverityWarning = "warning"
+
+	// SeverityCritical is used as CheckResult.Severity when a critical
+	// expression failed.
+	SeverityCritical = "critical"
+
+	// SeverityUnknown is used as CheckResult.Severity when an agent was
+	// unable to determine the state of a service.
+	SeverityUnknown = "unknown"
+)
+
 // CheckHostID returns a compound key constisting of a check id and a host id.
 func CheckHostID(checkID string, hostID string) string {
 	return checkID + "::" + hostID
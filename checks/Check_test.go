@@ -3,12 +3,15 @@ package checks
 import (
 	"encoding/json"
 	"errors"
+	"reflect"
+	"sort"
 	"testing"
 	"time"
 
 	"github.com/gansoi/gansoi/boltdb"
 	"github.com/gansoi/gansoi/plugins"
 	"github.com/gansoi/gansoi/transports"
+	"github.com/gansoi/gansoi/transports/ssh"
 )
 
 type (
@@ -16,9 +19,13 @@ type (
 		ReturnError bool          `json:"return_error"`
 		Panic       bool          `json:"panic"`
 		Delay       time.Duration `json:"delay"`
+		Severity    string        `json:"severity"`
 	}
 
 	mockRemoteAgent struct{}
+
+	// mockDualAgent can run both locally and remotely.
+	mockDualAgent struct{}
 )
 
 func (m *mockAgent) Check(result plugins.AgentResult) error {
@@ -32,6 +39,10 @@ func (m *mockAgent) Check(result plugins.AgentResult) error {
 		panic("panic")
 	}
 
+	if m.Severity != "" {
+		return plugins.NewSeverityError(m.Severity, "severity error")
+	}
+
 	result.AddValue("ran", true)
 
 	return nil
@@ -43,9 +54,22 @@ func (m *mockRemoteAgent) RemoteCheck(transport transports.Transport, result plu
 	return nil
 }
 
+func (m *mockDualAgent) Check(result plugins.AgentResult) error {
+	result.AddValue("where", "local")
+
+	return nil
+}
+
+func (m *mockDualAgent) RemoteCheck(transport transports.Transport, result plugins.AgentResult) error {
+	result.AddValue("where", "remote")
+
+	return nil
+}
+
 func init() {
 	plugins.RegisterAgent("mock", mockAgent{})
 	plugins.RegisterAgent("mockremote", mockRemoteAgent{})
+	plugins.RegisterAgent("mockdual", mockDualAgent{})
 }
 
 func TestCheckJsonInvalid(t *testing.T) {
@@ -128,6 +152,48 @@ func TestRunCheckError(t *testing.T) {
 	}
 }
 
+func TestRunCheckSeverityError(t *testing.T) {
+	check := Check{
+		AgentID:   "mock",
+		Arguments: json.RawMessage(`{"severity": "unknown"}`),
+	}
+
+	result := RunCheck(nil, &check)
+	if result.Error != "severity error" || result.Severity != SeverityUnknown {
+		t.Fatalf("RunCheck() did not respect severity, got '%s' (%s)", result.Error, result.Severity)
+	}
+}
+
+func TestRunCheckRemote(t *testing.T) {
+	cases := []struct {
+		agent     string
+		transport transports.Transport
+		err       bool
+		where     interface{}
+	}{
+		{"mockremote", nil, true, nil},
+		{"mockremote", &ssh.SSH{}, false, nil},
+		{"mockdual", nil, false, "local"},
+		{"mockdual", &ssh.SSH{}, false, "remote"},
+	}
+
+	for i, c := range cases {
+		check := Check{
+			AgentID:   c.agent,
+			Arguments: json.RawMessage("{}"),
+		}
+
+		result := RunCheck(c.transport, &check)
+		if c.err != (result.Error != "") {
+			t.Errorf("%d: RunCheck() returned unexpected error '%s'", i, result.Error)
+		}
+
+		if result.Results["where"] != c.where {
+			t.Errorf("%d: RunCheck() ran %v, expected %v", i, result.Results["where"], c.where)
+		}
+	}
+}
+
 func TestCheckValidate(t *testing.T) {
 	db := boltdb.NewTestStore()
 
@@ -139,6 +205,25 @@ func TestCheckValidate(t *testing.T) {
 		{&Check{Name: "name"}, true},
 		{&Check{AgentID: "agent"}, true},
 		{&Check{Name: "name", AgentID: "agent"}, false},
+		{&Check{Name: "name", AgentID: "agent", Expressions: []string{`rate("a") > 10`}}, false},
+		{&Check{Name: "name", AgentID: "agent", Expressions: []string{`a >`}}, true},
+		{&Check{Name: "name", AgentID: "agent", Expressions: []string{`unknown("a")`}}, true},
+		{&Check{Name: "name", AgentID: "agent", FlapDetection: FlapDetection{Window: 10, High: 40, Low: 20}}, false},
+		{&Check{Name: "name", AgentID: "agent", FlapDetection: FlapDetection{Window: 10, High: 20, Low: 40}}, true},
+		{&Check{Name: "name", AgentID: "agent", FlapDetection: FlapDetection{Window: -1}}, true},
+		{&Check{Name: "name", AgentID: "agent", Policy: Policy{Window: 10, Mode: PolicyPercentage, Threshold: 80}}, false},
+		{&Check{Name: "name", AgentID: "agent", Policy: Policy{Mode: "unanimous"}}, true},
+		{&Check{Name: "name", AgentID: "agent", Policy: Policy{Threshold: 120}}, true},
+		{&Check{Name: "name", AgentID: "agent", Nodes: 3, Quorum: 2}, false},
+		{&Check{Name: "name", AgentID: "agent", Nodes: 2, Quorum: 3}, true},
+		{&Check{Name: "name", AgentID: "agent", Nodes: AllNodes, Quorum: 3}, false},
+		{&Check{Name: "name", AgentID: "agent", Nodes: 1, MaxAttempts: 3}, false},
+		{&Check{Name: "name", AgentID: "agent", Nodes: 2, MaxAttempts: 3}, true},
+		{&Check{Name: "name", AgentID: "agent", Nodes: AllNodes, MaxAttempts: 3}, true},
+		{&Check{Name: "name", AgentID: "agent", MaxAttempts: 3, Policy: Policy{Window: 5}}, true},
+		{&Check{Name: "name", AgentID: "agent", MaxAttempts: 3, Policy: Policy{Mode: PolicyConsecutive}}, true},
+		{&Check{Name: "name", AgentID: "agent", MaxAttempts: 3, Policy: Policy{Threshold: 50}}, true},
+		{&Check{Name: "name", AgentID: "agent", Policy: Policy{Window: 5}}, false},
 	}
 
 	for i, c := range cases {
@@ -155,3 +240,188 @@ func TestCheckValidate(t *testing.T) {
 		}
 	}
 }
+
+func TestCheckMultiNode(t *testing.T) {
+	cases := []struct {
+		nodes    int
+		expected bool
+	}{
+		{0, false},
+		{1, false},
+		{2, true},
+		{AllNodes, true},
+	}
+
+	for _, c := range cases {
+		check := &Check{Nodes: c.nodes}
+		if check.MultiNode() != c.expected {
+			t.Errorf("MultiNode() returned %v for %d nodes", !c.expected, c.nodes)
+		}
+	}
+}
+
+func TestRunCheckTimeout(t *testing.T) {
+	check := &Check{
+		AgentID:   "mock",
+		Timeout:   time.Millisecond * 10,
+		Arguments: json.RawMessage(`{"delay": 1000000000}`),
+	}
+
+	start := time.Now()
+	result := RunCheck(nil, check)
+
+	if time.Since(start) > time.Millisecond*500 {
+		t.Fatalf("RunCheck() did not enforce timeout")
+	}
+
+	if result.Error != "timeout after 10ms" {
+		t.Fatalf("RunCheck() returned wrong error: '%s'", result.Error)
+	}
+}
+
+func TestCheckValidateDependsOn(t *testing.T) {
+	db := boltdb.NewTestStore()
+
+	parent := &Check{Name: "parent", AgentID: "mock"}
+	parent.ID = "parent"
+	db.Save(parent)
+
+	cases := []struct {
+		dependsOn []string
+		err       bool
+	}{
+		{[]string{}, false},
+		{[]string{"parent"}, false},
+		{[]string{"parent", "nonexisting"}, true},
+		{[]string{"child"}, true},
+	}
+
+	for i, c := range cases {
+		check := &Check{Name: "child", AgentID: "mock", DependsOn: c.dependsOn}
+		check.ID = "child"
+
+		err := check.Validate(db)
+		if (err != nil) != c.err {
+			t.Errorf("%d: Validate() returned %v for %v", i, err, c.dependsOn)
+		}
+	}
+}
+
+func TestCheckValidateDependencyCycle(t *testing.T) {
+	db := boltdb.NewTestStore()
+
+	a := &Check{Name: "a", AgentID: "mock"}
+	a.ID = "a"
+	db.Save(a)
+
+	b := &Check{Name: "b", AgentID: "mock", DependsOn: []string{"a"}}
+	b.ID = "b"
+	db.Save(b)
+
+	c := &Check{Name: "c", AgentID: "mock", DependsOn: []string{"b"}}
+	c.ID = "c"
+	db.Save(c)
+
+	cases := []struct {
+		id        string
+		dependsOn []string
+		err       bool
+	}{
+		// a -> b -> a
+		{"a", []string{"b"}, true},
+		// a -> c -> b -> a
+		{"a", []string{"c"}, true},
+		{"b", []string{"a"}, false},
+		{"d", []string{"c"}, false},
+		{"", []string{"c"}, false},
+	}
+
+	for i, cc := range cases {
+		check := &Check{Name: "check", AgentID: "mock", DependsOn: cc.dependsOn}
+		check.ID = cc.id
+
+		err := check.Validate(db)
+		if (err != nil) != cc.err {
+			t.Errorf("%d: Validate() returned %v for %s depending on %v", i, err, cc.id, cc.dependsOn)
+		}
+	}
+}
+
+func TestCheckHostIDs(t *testing.T) {
+	db := boltdb.NewTestStore()
+
+	hosts := map[string]map[string]string{
+		"db1":  {"role": "db", "env": "prod"},
+		"db2":  {"role": "db", "env": "test"},
+		"web1": {"role": "web", "env": "prod"},
+	}
+
+	for id, labels := range hosts {
+		host := &ssh.SSH{Labels: labels}
+		host.ID = id
+		db.Save(host)
+	}
+
+	cases := []struct {
+		hosts    []string
+		selector string
+		expected []string
+	}{
+		{[]string{"a"}, "", []string{"a"}},
+		{nil, "role=db,env=prod", []string{"db1"}},
+		{[]string{"db1"}, "env=prod", []string{"db1", "web1"}},
+		{nil, "role=cache", []string{}},
+	}
+
+	for i, c := range cases {
+		check := &Check{Hosts: c.hosts, HostSelector: c.selector}
+
+		if !check.Remote() {
+			t.Errorf("%d: Remote() returned false", i)
+		}
+
+		hostIDs, err := check.HostIDs(db)
+		if err != nil {
+			t.Fatalf("%d: HostIDs() failed: %s", i, err.Error())
+		}
+
+		sort.Strings(hostIDs)
+
+		if !reflect.DeepEqual(hostIDs, c.expected) {
+			t.Errorf("%d: HostIDs() returned %v, expected %v", i, hostIDs, c.expected)
+		}
+	}
+
+	check := &Check{HostSelector: "invalid"}
+	if check.Validate(db) == nil {
+		t.Errorf("Validate() accepted an invalid host selector")
+	}
+}
+
+func TestCheckEvaluateSeverity(t *testing.T) {
+	check := &Check{
+		Expressions:         []string{`Used < 99`},
+		CriticalExpressions: []string{`Used < 95`},
+		WarningExpressions:  []string{`Used < 85`},
+	}
+
+	cases := []struct {
+		used     int
+		severity string
+		err      bool
+	}{
+		{50, "", false},
+		{90, SeverityWarning, true},
+		{96, SeverityCritical, true},
+		{99, "", true},
+	}
+
+	for _, c := range cases {
+		result := &CheckResult{Results: plugins.AgentResult{"Used": c.used}}
+
+		severity, err := check.Evaluate(result, nil)
+		if severity != c.severity || (err != nil) != c.err {
+			t.Errorf("%d: Evaluate() returned '%s' (%v), expected '%s'", c.used, severity, err, c.severity)
+		}
+	}
+}
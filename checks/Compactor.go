@@ -0,0 +1,259 @@
+package checks
+
+import (
+	"errors"
+	"expvar"
+	"sync/atomic"
+	"time"
+
+	"github.com/gansoi/gansoi/database"
+	"github.com/gansoi/gansoi/logger"
+)
+
+type (
+	// Retention describes for how long check results are kept. Zero means
+	// forever.
+	Retention struct {
+		// Raw is for how long raw results are kept before being rolled up.
+		Raw time.Duration `json:"raw"`
+
+		// Rollups is for how long rollups are kept.
+		Rollups time.Duration `json:"rollups"`
+
+		// Metrics is for how long numeric values are kept in the
+		// time-series store.
+		Metrics time.Duration `json:"metrics"`
+	}
+
+	// Compactor will roll up results in the local result store older than
+	// the retention policy allows. The rollups are saved to the cluster
+	// database. The Compactor should run on all nodes, but only the leader
+	// will delete rollups past retention.
+	Compactor struct {
+		results   database.ReadWriter
+		db        database.ReadWriter
+		node      string
+		retention Retention
+		leader    int32
+		stop      chan struct{}
+	}
+)
+
+const (
+	// compactInterval is how often the compactor will run.
+	compactInterval = 10 * time.Minute
+
+	// compactBatch is the number of records read from the database at once.
+	compactBatch = 500
+
+	// compactMax is the maximum number of records compacted in a single
+	// run. A large backlog will be worked through over multiple runs.
+	compactMax = 10000
+)
+
+var (
+	// DefaultRetention keeps raw results for two days, metrics for 30 days
+	// and rollups for 90 days.
+	DefaultRetention = Retention{
+		Raw:     48 * time.Hour,
+		Rollups: 90 * 24 * time.Hour,
+		Metrics: 30 * 24 * time.Hour,
+	}
+
+	compacted = expvar.NewInt("compactor_compacted")
+	expired   = expvar.NewInt("compactor_expired")
+)
+
+// NewCompactor will instantiate a new Compactor. Results are read from the
+// node-local results, rollups are saved to db. node is the name of the local
+// node.
+func NewCompactor(results database.ReadWriter, db database.ReadWriter, node string, retention Retention) *Compactor {
+	return &Compactor{
+		results:   results,
+		db:        db,
+		node:      node,
+		retention: retention,
+		stop:      make(chan struct{}),
+	}
+}
+
+// SetLeader must be called when the local node gains or loses leadership.
+func (c *Compactor) SetLeader(leader bool) {
+	var l int32
+	if leader {
+		l = 1
+	}
+
+	atomic.StoreInt32(&c.leader, l)
+}
+
+// Run will start compacting in the background.
+func (c *Compactor) Run() {
+	go c.loop()
+}
+
+// Stop will stop the compactor.
+func (c *Compactor) Stop() {
+	c.stop <- struct{}{}
+}
+
+func (c *Compactor) loop() {
+	ticker := time.NewTicker(compactInterval)
+
+	for {
+		select {
+		case t := <-ticker.C:
+			err := c.Compact(t)
+			if err != nil {
+				logger.Info("compactor", "Compaction failed: %s", err.Error())
+			}
+
+		case <-c.stop:
+			ticker.Stop()
+			return
+		}
+	}
+}
+
+// Compact will roll up and delete results older than the raw retention. On
+// the leader, rollups older than the rollup retention are deleted as well.
+func (c *Compactor) Compact(clock time.Time) error {
+	if c.retention.Raw > 0 {
+		err := c.rollup(clock.Add(-c.retention.Raw))
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.retention.Rollups > 0 && atomic.LoadInt32(&c.leader) == 1 {
+		return c.expire(clock.Add(-c.retention.Rollups))
+	}
+
+	return nil
+}
+
+// rollup will roll up and delete results from before cutoff. Results are
+// read in the order they were saved, which is close enough to the order they
+// were executed.
+func (c *Compactor) rollup(cutoff time.Time) error {
+	rollups := make(map[string]*Rollup)
+	var old []CheckResult
+
+	for skip := 0; len(old) < compactMax; skip += compactBatch {
+		var batch []CheckResult
+
+		err := c.results.All(&batch, compactBatch, skip, false)
+		if errors.Is(err, database.ErrNotFound) {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		done := len(batch) < compactBatch
+
+		for i := range batch {
+			result := &batch[i]
+
+			if !result.TimeStamp.Before(cutoff) {
+				done = true
+				break
+			}
+
+			start := result.TimeStamp.Truncate(RollupPeriod)
+			id := rollupID(result.CheckHostID, c.node, start)
+
+			rollup, found := rollups[id]
+			if !found {
+				rollup = newRollup(result, c.node)
+
+				// The period could have been partly rolled up in an
+				// earlier run.
+				_ = c.db.One("ID", id, rollup)
+
+				if rollup.Values == nil {
+					rollup.Values = make(map[string]Aggregate)
+				}
+
+				rollups[id] = rollup
+			}
+
+			rollup.Add(result)
+			old = append(old, *result)
+		}
+
+		if done {
+			break
+		}
+	}
+
+	// Save rollups before deleting anything. If we fail halfway, we would
+	// rather count a result twice than lose it.
+	for _, rollup := range rollups {
+		err := c.db.Save(rollup)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := range old {
+		err := c.results.Delete(&old[i])
+		if err != nil {
+			return err
+		}
+
+		compacted.Add(1)
+	}
+
+	if len(old) > 0 {
+		logger.Debug("compactor", "Rolled up %d result(s) into %d rollup(s)", len(old), len(rollups))
+	}
+
+	return nil
+}
+
+// expire will delete rollups ending before cutoff. Rollup IDs sort by time,
+// so we can stop at the first rollup still relevant.
+func (c *Compactor) expire(cutoff time.Time) error {
+	var old []Rollup
+
+	for skip := 0; len(old) < compactMax; skip += compactBatch {
+		var batch []Rollup
+
+		err := c.db.All(&batch, compactBatch, skip, false)
+		if errors.Is(err, database.ErrNotFound) {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		done := len(batch) < compactBatch
+
+		for i := range batch {
+			if batch[i].End.After(cutoff) {
+				done = true
+				break
+			}
+
+			old = append(old, batch[i])
+		}
+
+		if done {
+			break
+		}
+	}
+
+	for i := range old {
+		err := c.db.Delete(&old[i])
+		if err != nil {
+			return err
+		}
+
+		expired.Add(1)
+	}
+
+	return nil
+}
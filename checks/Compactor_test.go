@@ -0,0 +1,137 @@
+package checks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gansoi/gansoi/boltdb"
+	"github.com/gansoi/gansoi/plugins"
+)
+
+func TestCompactorCompact(t *testing.T) {
+	results := boltdb.NewTestStore()
+	defer results.Close()
+
+	db := boltdb.NewTestStore()
+	defer db.Close()
+
+	clock := time.Date(2020, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	// Two results per hour for the last ten hours.
+	for i := 20; i > 0; i-- {
+		result := &CheckResult{
+			CheckHostID: CheckHostID("check", ""),
+			CheckID:     "check",
+			TimeStamp:   clock.Add(-time.Duration(i) * 30 * time.Minute),
+			Results:     plugins.AgentResult{"value": float64(i)},
+		}
+		results.Save(result)
+	}
+
+	c := NewCompactor(results, db, "node1", Retention{Raw: 5 * time.Hour, Rollups: 7 * time.Hour})
+	c.SetLeader(true)
+
+	err := c.Compact(clock)
+	if err != nil {
+		t.Fatalf("Compact() failed: %s", err.Error())
+	}
+
+	var kept []CheckResult
+	results.All(&kept, -1, 0, false)
+
+	if len(kept) != 10 {
+		t.Fatalf("Expected 10 raw results to be kept, got %d", len(kept))
+	}
+
+	for _, result := range kept {
+		if result.TimeStamp.Before(clock.Add(-5 * time.Hour)) {
+			t.Fatalf("Result from %s survived compaction", result.TimeStamp)
+		}
+	}
+
+	// Ten results from five hours are rolled up, of which the first three
+	// hours are past retention right away.
+	var rollups []Rollup
+	db.All(&rollups, -1, 0, false)
+
+	if len(rollups) != 2 {
+		t.Fatalf("Expected 2 rollups, got %d", len(rollups))
+	}
+
+	for _, rollup := range rollups {
+		if rollup.Node != "node1" {
+			t.Fatalf("Rollup belongs to '%s', expected node1", rollup.Node)
+		}
+
+		if rollup.Runs != 2 {
+			t.Fatalf("Rollup for %s covers %d runs, expected 2", rollup.Start, rollup.Runs)
+		}
+	}
+
+	// An hour later, another hour should be rolled up, and the oldest rollup
+	// should expire.
+	err = c.Compact(clock.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Compact() failed: %s", err.Error())
+	}
+
+	rollups = nil
+	db.All(&rollups, -1, 0, false)
+
+	if len(rollups) != 2 {
+		t.Fatalf("Expected 2 rollups after second compaction, got %d", len(rollups))
+	}
+
+	kept = nil
+	results.All(&kept, -1, 0, false)
+
+	if len(kept) != 8 {
+		t.Fatalf("Expected 8 raw results after second compaction, got %d", len(kept))
+	}
+
+	// Followers should roll up the remaining four hours, but leave old
+	// rollups alone.
+	c.SetLeader(false)
+
+	err = c.Compact(clock.Add(24 * time.Hour))
+	if err != nil {
+		t.Fatalf("Compact() failed: %s", err.Error())
+	}
+
+	rollups = nil
+	db.All(&rollups, -1, 0, false)
+
+	if len(rollups) != 6 {
+		t.Fatalf("Expected 6 rollups on follower, got %d", len(rollups))
+	}
+}
+
+func TestCompactorForever(t *testing.T) {
+	db := boltdb.NewTestStore()
+	defer db.Close()
+
+	db.Save(&CheckResult{CheckHostID: "check::", TimeStamp: time.Unix(0, 0)})
+
+	c := NewCompactor(db, db, "node1", Retention{})
+
+	err := c.Compact(time.Now())
+	if err != nil {
+		t.Fatalf("Compact() failed: %s", err.Error())
+	}
+
+	var results []CheckResult
+	db.All(&results, -1, 0, false)
+
+	if len(results) != 1 {
+		t.Fatalf("Result was deleted without retention")
+	}
+}
+
+func TestCompactorRunStop(t *testing.T) {
+	db := boltdb.NewTestStore()
+	defer db.Close()
+
+	c := NewCompactor(db, db, "node1", DefaultRetention)
+	c.Run()
+	c.Stop()
+}
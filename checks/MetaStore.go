@@ -1,18 +1,35 @@
 package checks
 
 import (
+	"container/heap"
 	"math/rand"
 	"sync"
 	"time"
 
 	"github.com/gansoi/gansoi/database"
+	"github.com/gansoi/gansoi/logger"
+	"github.com/gansoi/gansoi/transports/ssh"
 )
 
 type (
-	// MetaStore will keep a list of checks to execute.
+	// MetaStore will keep a list of checks to execute ordered by the time
+	// they're due. If the MetaStore knows about other live nodes, only
+	// check/host pairs owned by this node will be returned from next().
 	MetaStore struct {
-		sync.RWMutex
-		store map[metaKey]*checkMeta
+		sync.Mutex
+		db       database.Reader
+		queue    metaQueue
+		checks   map[string][]*checkMeta
+		selected map[string]Check
+		waiting  []*checkMeta
+		self     string
+		ring     *ring
+		limiter  *limiter
+
+		// wakeup will receive a value when the first check in the queue may
+		// have changed, or when a check held back by limits may be able to
+		// run.
+		wakeup chan struct{}
 	}
 
 	metaKey struct {
@@ -25,14 +42,28 @@ type (
 		key       *metaKey
 		running   bool
 		runs      int
+		failures  int
 		NextCheck time.Time
 		interval  time.Duration
+		schedule  *schedule
+
+		// previous is the result from the last run on this node.
+		previous *CheckResult
+
+		// index is the position in the queue, or -1 if not queued.
+		index   int
+		removed bool
 	}
 )
 
 func newMetaStore(db database.ReadWriteBroadcaster) (*MetaStore, error) {
 	s := &MetaStore{
-		store: make(map[metaKey]*checkMeta),
+		db:       db,
+		checks:   make(map[string][]*checkMeta),
+		selected: make(map[string]Check),
+		ring:     newRing(nil),
+		limiter:  newLimiter(Limits{}),
+		wakeup:   make(chan struct{}, 1),
 	}
 
 	db.RegisterListener(s)
@@ -42,10 +73,17 @@ func newMetaStore(db database.ReadWriteBroadcaster) (*MetaStore, error) {
 	return s, nil
 }
 
-// PostApply implements database.Listener.
+// PostApply implements database.Listener. Hosts coming and going will be
+// matched against checks using a host selector.
 func (s *MetaStore) PostApply(leader bool, command database.Command, data interface{}) {
 	clock := time.Now()
 
+	if _, isHost := data.(*ssh.SSH); isHost {
+		s.selectHosts(clock)
+
+		return
+	}
+
 	check, isCheck := data.(*Check)
 	if !isCheck {
 		return
@@ -79,97 +117,344 @@ func (s *MetaStore) populate(db database.Reader) error {
 func (s *MetaStore) removeCheck(check *Check) {
 	s.Lock()
 
-	for key := range s.store {
-		if key.checkID == check.ID {
-			delete(s.store, key)
-		}
+	for _, meta := range s.checks[check.ID] {
+		s.dequeue(meta)
 	}
 
+	delete(s.checks, check.ID)
+	delete(s.selected, check.ID)
+
 	s.Unlock()
 }
 
+// dequeue will remove meta from the queue. Must be called with the lock held.
+func (s *MetaStore) dequeue(meta *checkMeta) {
+	if s.queue.contains(meta) {
+		heap.Remove(&s.queue, meta.index)
+	}
+
+	// The check could be waiting for a free slot. We let next() take care
+	// of that.
+	meta.removed = true
+}
+
+// newCheckMeta returns a new checkMeta for check. Checks with a schedule will
+// run at the first matching time, other checks will start at a random time
+// within the first interval.
+func newCheckMeta(clock time.Time, check *Check, key metaKey) *checkMeta {
+	meta := &checkMeta{
+		interval: check.Interval,
+		check:    *check,
+		key:      &key,
+		index:    -1,
+	}
+
+	if check.Schedule != "" {
+		var err error
+
+		meta.schedule, err = parseSchedule(check.Schedule, check.TimeZone)
+		if err != nil {
+			logger.Info("scheduler", "[%s] Ignoring invalid schedule '%s': %s", check.ID, check.Schedule, err.Error())
+		}
+	}
+
+	if meta.schedule != nil {
+		meta.NextCheck = meta.schedule.next(clock)
+	} else {
+		meta.NextCheck = randomStartTime(clock, check.Interval)
+	}
+
+	return meta
+}
+
+// reschedule will compute the next time meta should be executed after clock.
+func (meta *checkMeta) reschedule(clock time.Time) {
+	if meta.schedule != nil {
+		meta.NextCheck = meta.schedule.next(clock)
+
+		// The schedule will never match again. We should never get here,
+		// parseSchedule() makes sure a schedule matches.
+		if meta.NextCheck.IsZero() {
+			meta.NextCheck = clock.AddDate(scheduleHorizon, 0, 0)
+		}
+
+		return
+	}
+
+	meta.NextCheck = clock.Add(meta.interval)
+}
+
 func (s *MetaStore) addCheck(clock time.Time, check *Check) {
-	if len(check.Hosts) == 0 {
-		key := metaKey{
-			checkID: check.ID,
+	keys := []metaKey{{checkID: check.ID}}
+
+	if check.Remote() {
+		hostIDs, err := check.HostIDs(s.db)
+		if err != nil {
+			logger.Info("scheduler", "[%s] Failed to resolve hosts: %s", check.ID, err.Error())
 		}
 
-		meta := &checkMeta{
-			NextCheck: randomStartTime(clock, check.Interval),
-			interval:  check.Interval,
-			check:     *check,
-			key:       &key,
+		keys = keys[:0]
+
+		for _, hostID := range hostIDs {
+			keys = append(keys, metaKey{checkID: check.ID, hostID: hostID})
 		}
+	}
+
+	s.Lock()
+
+	if check.HostSelector != "" {
+		s.selected[check.ID] = *check
+	}
+
+	for _, key := range keys {
+		meta := newCheckMeta(clock, check, key)
+
+		heap.Push(&s.queue, meta)
+		s.checks[check.ID] = append(s.checks[check.ID], meta)
+	}
+
+	s.Unlock()
+
+	s.signal()
+}
 
-		s.Lock()
-		s.store[key] = meta
-		s.Unlock()
+// selectHosts will add and remove check/host pairs for all checks using a
+// host selector. Pairs already known will keep their schedule.
+func (s *MetaStore) selectHosts(clock time.Time) {
+	s.Lock()
+	selected := make([]Check, 0, len(s.selected))
+	for _, check := range s.selected {
+		selected = append(selected, check)
 	}
+	s.Unlock()
+
+	for _, check := range selected {
+		check := check // pin
 
-	for _, hostID := range check.Hosts {
-		key := metaKey{
-			checkID: check.ID,
-			hostID:  hostID,
+		hostIDs, err := check.HostIDs(s.db)
+		if err != nil {
+			logger.Info("scheduler", "[%s] Failed to resolve hosts: %s", check.ID, err.Error())
+			continue
 		}
 
-		meta := &checkMeta{
-			NextCheck: randomStartTime(clock, check.Interval),
-			interval:  check.Interval,
-			check:     *check,
-			key:       &key,
+		s.setHosts(clock, &check, hostIDs)
+	}
+}
+
+// setHosts will make sure check is scheduled on exactly the hosts in hostIDs.
+func (s *MetaStore) setHosts(clock time.Time, check *Check, hostIDs []string) {
+	s.Lock()
+	defer s.Unlock()
+
+	// The check could have been removed while we resolved hosts.
+	if _, found := s.selected[check.ID]; !found {
+		return
+	}
+
+	wanted := make(map[string]bool)
+	for _, hostID := range hostIDs {
+		wanted[hostID] = true
+	}
+
+	var metas []*checkMeta
+	known := make(map[string]bool)
+
+	for _, meta := range s.checks[check.ID] {
+		if !wanted[meta.key.hostID] {
+			s.dequeue(meta)
+			continue
+		}
+
+		known[meta.key.hostID] = true
+		metas = append(metas, meta)
+	}
+
+	for _, hostID := range hostIDs {
+		if known[hostID] {
+			continue
 		}
 
-		s.Lock()
-		s.store[key] = meta
-		s.Unlock()
+		meta := newCheckMeta(clock, check, metaKey{checkID: check.ID, hostID: hostID})
+
+		heap.Push(&s.queue, meta)
+		metas = append(metas, meta)
+	}
+
+	s.checks[check.ID] = metas
+
+	s.signal()
+}
+
+// signal will wake up anyone waiting on s.wakeup.
+func (s *MetaStore) signal() {
+	select {
+	case s.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// nextCheck returns the time of the first check in the queue. If the queue is
+// empty, false is returned.
+func (s *MetaStore) nextCheck() (time.Time, bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	if len(s.queue) == 0 {
+		return time.Time{}, false
 	}
+
+	return s.queue[0].NextCheck, true
+}
+
+// setNodes will update the list of live nodes sharing the checks. self is the
+// name of the local node. setNodes returns true if the list of nodes changed.
+func (s *MetaStore) setNodes(self string, nodes []string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.self == self && s.ring.equal(nodes) {
+		return false
+	}
+
+	s.self = self
+	s.ring = newRing(nodes)
+
+	return true
+}
+
+// owns returns true if the local node is responsible for executing the
+// check/host pair in meta. If we know of no other nodes, we own everything.
+// Must be called with the lock held.
+func (s *MetaStore) owns(meta *checkMeta) bool {
+	count := meta.check.Nodes
+	if count == 0 {
+		count = 1
+	}
+
+	owners := s.ring.owners(CheckHostID(meta.key.checkID, meta.key.hostID), count)
+	if len(owners) == 0 {
+		return true
+	}
+
+	for _, owner := range owners {
+		if owner == s.self {
+			return true
+		}
+	}
+
+	return false
 }
 
 // next returns the next check to execute. Done() must be called when the check
-// is done executing.
+// is done executing. Checks held back by limits are kept waiting, and will be
+// returned before any other check when a slot is available.
 func (s *MetaStore) next(clock time.Time) *checkMeta {
-	var winner *checkMeta
-
-	s.RLock()
-	for _, meta := range s.store {
-		// Calculate how much we should wait before executing the check. If
-		// the value is positive, it's in the future.
-		wait := meta.NextCheck.Sub(clock)
+	s.Lock()
+	defer s.Unlock()
 
-		// ... and if the value is negative, we better get on with it :)
-		if wait < 0 {
-			if meta.running {
-				inflightOverrun.Add(1)
-				continue
-			}
+	var winner *checkMeta
 
+	// Checks waiting for a slot goes first, they have been due the longest.
+	waiting := s.waiting[:0]
+	for _, meta := range s.waiting {
+		switch {
+		case meta.removed:
+		case winner == nil && s.limiter.acquire(meta):
 			winner = meta
+		default:
+			waiting = append(waiting, meta)
+		}
+	}
+	s.waiting = waiting
+
+	for winner == nil && len(s.queue) > 0 {
+		meta := s.queue[0]
 
+		// The first check is not due yet, and neither are the rest.
+		if !meta.NextCheck.Before(clock) {
 			break
 		}
+
+		switch {
+		case !s.owns(meta):
+			// Another node is responsible for this check. We look again
+			// when it's due next time, the ring could have changed.
+			meta.reschedule(clock)
+			heap.Fix(&s.queue, 0)
+
+		case meta.running:
+			inflightOverrun.Add(1)
+			meta.reschedule(clock)
+			heap.Fix(&s.queue, 0)
+
+		case !s.limiter.acquire(meta):
+			heap.Pop(&s.queue)
+			s.waiting = append(s.waiting, meta)
+
+		default:
+			winner = meta
+		}
+	}
+
+	queued.Set(int64(len(s.waiting)))
+
+	if winner == nil {
+		return nil
 	}
 
-	s.RUnlock()
+	winner.runs++
+	winner.running = true
+	winner.reschedule(clock)
 
-	if winner != nil {
-		// We lock the MetaStore just to change a single checkMeta. That is a
-		// bit excessive, but it'll do for now.
-		s.Lock()
-		winner.runs++
-		winner.running = true
-		winner.NextCheck = clock.Add(winner.interval)
-		s.Unlock()
+	if s.queue.contains(winner) {
+		heap.Fix(&s.queue, winner.index)
+	} else {
+		heap.Push(&s.queue, winner)
 	}
 
 	return winner
 }
 
 // Done must be called to signal that a check is done. After Done() the
-// checkMeta can again be returned from Next().
-func (s *MetaStore) Done(meta *checkMeta) {
+// checkMeta can again be returned from Next(). If the check failed, and the
+// check has attempts left, it will be re-scheduled after RetryInterval.
+func (s *MetaStore) Done(clock time.Time, meta *checkMeta, result *CheckResult) {
 	s.Lock()
+	defer s.Unlock()
+
+	if meta.running {
+		s.limiter.release(meta)
+
+		// A waiting check may be able to run now.
+		if len(s.waiting) > 0 {
+			s.signal()
+		}
+	}
+
 	meta.running = false
-	s.Unlock()
+
+	if result == nil || result.Error == "" {
+		meta.failures = 0
+
+		return
+	}
+
+	meta.failures++
+
+	retry := meta.check.RetryInterval
+	if retry <= 0 || meta.failures >= meta.check.MaxAttempts {
+		return
+	}
+
+	next := clock.Add(retry)
+	if next.Before(meta.NextCheck) {
+		meta.NextCheck = next
+
+		if s.queue.contains(meta) {
+			heap.Fix(&s.queue, meta.index)
+			s.signal()
+		}
+	}
 }
 
 // randomStartTime will try to find a random start time for a check. It should
@@ -1,11 +1,13 @@
 package checks
 
 import (
+	"container/heap"
 	"testing"
 	"time"
 
 	"github.com/gansoi/gansoi/boltdb"
 	"github.com/gansoi/gansoi/database"
+	"github.com/gansoi/gansoi/transports/ssh"
 )
 
 func TestPostApply(t *testing.T) {
@@ -57,3 +59,296 @@ func TestRemoteCheck(t *testing.T) {
 }
 
 var _ database.Listener = (*MetaStore)(nil)
+
+func TestMetaStoreOwns(t *testing.T) {
+	db := boltdb.NewTestStore()
+
+	s, _ := newMetaStore(db)
+
+	c := &Check{
+		Hosts:    []string{"host1", "host2", "host3", "host4", "host5", "host6"},
+		Interval: time.Second * 61,
+	}
+	c.ID = "check"
+	s.addCheck(time.Now().Add(-time.Hour), c)
+
+	if s.setNodes("node1", nil) != true {
+		t.Fatalf("setNodes() did not report a change")
+	}
+
+	if s.setNodes("node1", nil) != false {
+		t.Fatalf("setNodes() reported a change for an unchanged list")
+	}
+
+	s.setNodes("node1", []string{"node1", "node2"})
+
+	clock := time.Now()
+	owned := 0
+	for meta := s.next(clock); meta != nil; meta = s.next(clock) {
+		owned++
+		s.Done(clock, meta, nil)
+	}
+
+	if owned == 0 || owned == len(c.Hosts) {
+		t.Fatalf("node1 should own some but not all pairs, owns %d", owned)
+	}
+
+	// If node2 leaves, node1 should take over the remaining pairs.
+	s.setNodes("node1", []string{"node1"})
+
+	clock = clock.Add(time.Hour)
+	owned = 0
+	for meta := s.next(clock); meta != nil; meta = s.next(clock) {
+		owned++
+	}
+
+	if owned != len(c.Hosts) {
+		t.Fatalf("node1 did not take over all pairs, owns %d", owned)
+	}
+}
+
+func TestMetaStoreOwnsMultiNode(t *testing.T) {
+	db := boltdb.NewTestStore()
+
+	s, _ := newMetaStore(db)
+
+	for _, self := range []string{"node1", "node2", "node3"} {
+		s.setNodes(self, []string{"node1", "node2", "node3"})
+
+		meta := &checkMeta{
+			check: Check{Nodes: AllNodes},
+			key:   &metaKey{checkID: "check"},
+		}
+
+		if !s.owns(meta) {
+			t.Fatalf("%s does not own a check running on all nodes", self)
+		}
+	}
+}
+
+func TestMetaStoreDoneRetry(t *testing.T) {
+	db := boltdb.NewTestStore()
+
+	s, _ := newMetaStore(db)
+
+	clock := time.Now()
+	failed := &CheckResult{Error: "error"}
+
+	meta := &checkMeta{
+		check: Check{
+			Interval:      time.Minute,
+			RetryInterval: time.Second * 5,
+			MaxAttempts:   3,
+		},
+		key:       &metaKey{},
+		NextCheck: clock.Add(time.Minute),
+	}
+
+	// First and second failure should be retried quickly.
+	for i := 0; i < 2; i++ {
+		meta.NextCheck = clock.Add(time.Minute)
+		s.Done(clock, meta, failed)
+
+		if meta.NextCheck != clock.Add(time.Second*5) {
+			t.Fatalf("%d: Done() did not schedule a retry, next check at %s", i, meta.NextCheck)
+		}
+	}
+
+	// Attempts are used up, back to normal interval.
+	meta.NextCheck = clock.Add(time.Minute)
+	s.Done(clock, meta, failed)
+	if meta.NextCheck != clock.Add(time.Minute) {
+		t.Fatalf("Done() scheduled a retry after all attempts was used")
+	}
+
+	// A success should reset the failure count.
+	s.Done(clock, meta, &CheckResult{})
+	if meta.failures != 0 {
+		t.Fatalf("Done() did not reset failures")
+	}
+
+	// No retry interval, no retries.
+	meta.check.RetryInterval = 0
+	s.Done(clock, meta, failed)
+	if meta.NextCheck != clock.Add(time.Minute) {
+		t.Fatalf("Done() scheduled a retry without a retry interval")
+	}
+}
+
+func TestMetaStoreSchedule(t *testing.T) {
+	clock := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	check := &Check{
+		Name:     "nightly",
+		AgentID:  "mock",
+		Schedule: "30 2 * * *",
+		TimeZone: "UTC",
+	}
+	check.ID = "nightly"
+
+	s, _ := newMetaStore(boltdb.NewTestStore())
+	s.addCheck(clock, check)
+
+	expected := time.Date(2020, 1, 2, 2, 30, 0, 0, time.UTC)
+
+	if s.next(clock) != nil {
+		t.Fatalf("next() returned a check before schedule")
+	}
+
+	meta := s.next(expected.Add(time.Second))
+	if meta == nil {
+		t.Fatalf("next() did not return scheduled check")
+	}
+
+	if !meta.NextCheck.Equal(expected.Add(24 * time.Hour)) {
+		t.Fatalf("Check rescheduled at %s, expected %s", meta.NextCheck, expected.Add(24*time.Hour))
+	}
+}
+
+func TestMetaStoreLimits(t *testing.T) {
+	db := boltdb.NewTestStore()
+	s, _ := newMetaStore(db)
+	s.limiter.setLimits(Limits{Global: 1})
+
+	for _, id := range []string{"a", "b"} {
+		check := &Check{Name: id, AgentID: "mock", Interval: time.Second}
+		check.ID = id
+		s.addCheck(time.Now(), check)
+	}
+
+	clock := time.Now().Add(time.Hour)
+
+	first := s.next(clock)
+	if first == nil {
+		t.Fatalf("next() returned nothing")
+	}
+
+	if s.next(clock) != nil {
+		t.Fatalf("next() returned a check with global limit reached")
+	}
+
+	if queued.Value() != 1 {
+		t.Fatalf("Queue depth is %d, expected 1", queued.Value())
+	}
+
+	s.Done(clock, first, nil)
+
+	second := s.next(clock)
+	if second == nil || second == first {
+		t.Fatalf("next() did not return the delayed check")
+	}
+}
+
+func TestMetaStoreQueueOrder(t *testing.T) {
+	s, _ := newMetaStore(boltdb.NewTestStore())
+
+	clock := time.Now()
+
+	for i, interval := range []time.Duration{time.Minute, time.Second, time.Hour} {
+		check := &Check{Interval: interval}
+		check.ID = string(rune('a' + i))
+		s.addCheck(clock, check)
+	}
+
+	// Force a known order.
+	for _, metas := range s.checks {
+		metas[0].NextCheck = clock.Add(metas[0].interval)
+		heap.Fix(&s.queue, metas[0].index)
+	}
+
+	next, found := s.nextCheck()
+	if !found || !next.Equal(clock.Add(time.Second)) {
+		t.Fatalf("nextCheck() returned %s, expected %s", next, clock.Add(time.Second))
+	}
+
+	var order []string
+	later := clock.Add(2 * time.Hour)
+	for meta := s.next(later); meta != nil; meta = s.next(later) {
+		order = append(order, meta.key.checkID)
+	}
+
+	if len(order) != 3 || order[0] != "b" || order[1] != "a" || order[2] != "c" {
+		t.Fatalf("next() returned checks in wrong order: %v", order)
+	}
+
+	s.removeCheck(&Check{Object: database.Object{ID: "a"}})
+
+	if len(s.queue) != 2 || len(s.checks) != 2 {
+		t.Fatalf("removeCheck() did not remove the check, %d queued", len(s.queue))
+	}
+
+	for i, meta := range s.queue {
+		if meta.index != i {
+			t.Fatalf("Queue index out of sync at %d", i)
+		}
+	}
+}
+
+func TestMetaStoreWakeup(t *testing.T) {
+	s, _ := newMetaStore(boltdb.NewTestStore())
+
+	check := &Check{Interval: time.Second}
+	check.ID = "wakeup"
+	s.addCheck(time.Now(), check)
+
+	select {
+	case <-s.wakeup:
+	default:
+		t.Fatalf("addCheck() did not wake the scheduler")
+	}
+
+	s.removeCheck(check)
+
+	if _, found := s.nextCheck(); found {
+		t.Fatalf("nextCheck() found a removed check")
+	}
+}
+
+func TestMetaStoreHostSelector(t *testing.T) {
+	db := boltdb.NewTestStore()
+	s, _ := newMetaStore(db)
+
+	host := &ssh.SSH{Labels: map[string]string{"role": "db"}}
+	host.ID = "db1"
+	db.Save(host)
+
+	check := &Check{HostSelector: "role=db", Interval: time.Minute}
+	check.ID = "selector"
+	s.addCheck(time.Now(), check)
+
+	if len(s.checks[check.ID]) != 1 {
+		t.Fatalf("addCheck() did not expand selector")
+	}
+
+	first := s.checks[check.ID][0]
+
+	// A new host should be picked up, keeping the existing pair.
+	host2 := &ssh.SSH{Labels: map[string]string{"role": "db"}}
+	host2.ID = "db2"
+	db.Save(host2)
+	s.PostApply(false, database.CommandSave, host2)
+
+	if len(s.checks[check.ID]) != 2 || s.checks[check.ID][0] != first {
+		t.Fatalf("PostApply() did not add new host")
+	}
+
+	// Changing labels should remove the host.
+	host.Labels = map[string]string{"role": "web"}
+	db.Save(host)
+	s.PostApply(false, database.CommandSave, host)
+
+	if len(s.checks[check.ID]) != 1 || s.checks[check.ID][0].key.hostID != "db2" {
+		t.Fatalf("PostApply() did not remove host")
+	}
+
+	if !first.removed || s.queue.contains(first) {
+		t.Fatalf("Removed pair is still queued")
+	}
+
+	db.Delete(host2)
+	s.PostApply(false, database.CommandDelete, host2)
+
+	if len(s.checks[check.ID]) != 0 || len(s.queue) != 0 {
+		t.Fatalf("PostApply() did not remove deleted host")
+	}
+}
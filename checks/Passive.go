@@ -0,0 +1,184 @@
+package checks
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gansoi/gansoi/database"
+	"github.com/gansoi/gansoi/plugins"
+)
+
+type (
+	// Passive is the agent used by passive checks. Passive checks are never
+	// executed, results are pushed by external jobs using Token instead. If
+	// no result is pushed within the check interval - plus a grace period of
+	// half an interval - the check is down.
+	Passive struct {
+		Token string `json:"token" description:"Secret token used for pushing results"`
+	}
+
+	// Push is a result pushed to a passive check.
+	Push struct {
+		// Status is one of "up", "warning", "critical" or "down". If empty,
+		// "up" is assumed.
+		Status string `json:"status"`
+
+		// Message is an optional description of the status.
+		Message string `json:"message"`
+
+		// Values are optional values like the ones returned by agents.
+		Values plugins.AgentResult `json:"values"`
+	}
+
+	// Heartbeat records the latest push to a passive check. It's replicated
+	// to all nodes, the node running the dead man's switch could be another
+	// node than the one receiving the push.
+	Heartbeat struct {
+		ID        string    `json:"id" storm:"id"`
+		Node      string    `json:"node_id"`
+		TimeStamp time.Time `json:"timestamp"`
+	}
+)
+
+const (
+	// PassiveAgent is the agent ID of passive checks.
+	PassiveAgent = "passive"
+
+	// passiveGrace is the part of the interval a push can be late. Jobs
+	// pushing once per interval will drift, and the dead man's switch runs at
+	// a random phase.
+	passiveGrace = 0.5
+
+	// minTokenLength is the minimum length of a passive check token. It
+	// should be hard to guess.
+	minTokenLength = 16
+)
+
+var (
+	// ErrUnknownToken is returned if no passive check is using a token.
+	ErrUnknownToken = errors.New("unknown token")
+
+	// ErrInvalidPush is returned if a pushed result is invalid.
+	ErrInvalidPush = errors.New("invalid push")
+)
+
+func init() {
+	plugins.RegisterAgent(PassiveAgent, Passive{})
+	database.RegisterType(Heartbeat{})
+}
+
+// Check implements plugins.Agent. Passive checks cannot be executed.
+func (p *Passive) Check(_ plugins.AgentResult) error {
+	return errors.New("passive checks cannot be executed, results must be pushed")
+}
+
+// Passive returns true if c is a passive check.
+func (c *Check) Passive() bool {
+	return c.AgentID == PassiveAgent
+}
+
+// token returns the token of a passive check.
+func (c *Check) token() (string, error) {
+	var p Passive
+
+	err := json.Unmarshal(c.Arguments, &p)
+	if err != nil {
+		return "", err
+	}
+
+	return p.Token, nil
+}
+
+// validatePassive will validate the passive specific parts of c.
+func (c *Check) validatePassive() error {
+	token, err := c.token()
+	if err != nil {
+		return fmt.Errorf("invalid arguments: %s", err.Error())
+	}
+
+	if len(token) < minTokenLength {
+		return fmt.Errorf("token must be at least %d characters", minTokenLength)
+	}
+
+	if c.Interval <= 0 {
+		return fmt.Errorf("passive checks must have an interval")
+	}
+
+	if len(c.Hosts) > 0 || c.HostSelector != "" {
+		return fmt.Errorf("passive checks cannot run on hosts")
+	}
+
+	if c.MultiNode() {
+		return fmt.Errorf("passive checks cannot run from multiple nodes")
+	}
+
+	return nil
+}
+
+// FindPassive returns the passive check using token.
+func FindPassive(db database.Reader, token string) (*Check, error) {
+	var all []Check
+
+	err := db.All(&all, -1, 0, false)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		return nil, err
+	}
+
+	for i := range all {
+		if !all[i].Passive() {
+			continue
+		}
+
+		t, err := all[i].token()
+		if err != nil || t == "" {
+			continue
+		}
+
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return &all[i], nil
+		}
+	}
+
+	return nil, ErrUnknownToken
+}
+
+// result converts p to a CheckResult for check.
+func (p *Push) result(clock time.Time, check *Check) (*CheckResult, error) {
+	result := &CheckResult{
+		CheckHostID: CheckHostID(check.ID, ""),
+		CheckID:     check.ID,
+		TimeStamp:   clock,
+		Results:     plugins.NewAgentResult(),
+	}
+
+	for key, value := range p.Values {
+		for _, r := range key {
+			if !plugins.ValidateResultKeyRune(r) {
+				return nil, fmt.Errorf("%w: invalid key '%s'", ErrInvalidPush, key)
+			}
+		}
+
+		result.Results[key] = value
+	}
+
+	message := p.Message
+	if message == "" {
+		message = "pushed status " + p.Status
+	}
+
+	switch p.Status {
+	case "", "up":
+	case SeverityWarning, SeverityCritical:
+		result.Error = message
+		result.Severity = p.Status
+	case "down":
+		result.Error = message
+	default:
+		return nil, fmt.Errorf("%w: invalid status '%s'", ErrInvalidPush, p.Status)
+	}
+
+	return result, nil
+}
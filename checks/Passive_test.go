@@ -0,0 +1,227 @@
+package checks
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gansoi/gansoi/boltdb"
+	"github.com/gansoi/gansoi/plugins"
+)
+
+const (
+	testToken = "0123456789abcdef"
+)
+
+func newPassive(id string) *Check {
+	c := &Check{
+		Name:      id,
+		AgentID:   PassiveAgent,
+		Interval:  time.Hour,
+		Arguments: []byte(`{"token": "` + testToken + `"}`),
+	}
+	c.ID = id
+
+	return c
+}
+
+func TestPassiveCheck(t *testing.T) {
+	result := RunCheck(nil, newPassive("passive"))
+	if result.Error == "" {
+		t.Fatalf("RunCheck() executed a passive check")
+	}
+}
+
+func TestCheckValidatePassive(t *testing.T) {
+	db := boltdb.NewTestStore()
+
+	short := newPassive("short")
+	short.Arguments = []byte(`{"token": "short"}`)
+
+	broken := newPassive("broken")
+	broken.Arguments = []byte(`{"token": 12}`)
+
+	noInterval := newPassive("nointerval")
+	noInterval.Interval = 0
+
+	hosts := newPassive("hosts")
+	hosts.Hosts = []string{"host"}
+
+	multi := newPassive("multi")
+	multi.Nodes = AllNodes
+
+	cases := []struct {
+		in  *Check
+		err bool
+	}{
+		{newPassive("passive"), false},
+		{short, true},
+		{broken, true},
+		{noInterval, true},
+		{hosts, true},
+		{multi, true},
+	}
+
+	for _, c := range cases {
+		err := c.in.Validate(db)
+		if (err != nil) != c.err {
+			t.Errorf("%s: Validate() returned %v", c.in.ID, err)
+		}
+	}
+}
+
+func TestFindPassive(t *testing.T) {
+	db := boltdb.NewTestStore()
+
+	_, err := FindPassive(db, testToken)
+	if err != ErrUnknownToken {
+		t.Fatalf("FindPassive() found a check in empty database")
+	}
+
+	db.Save(&Check{Name: "active", AgentID: "mock", Arguments: []byte(`{"token": "` + testToken + `"}`)})
+	db.Save(newPassive("passive"))
+
+	check, err := FindPassive(db, testToken)
+	if err != nil {
+		t.Fatalf("FindPassive() failed: %s", err.Error())
+	}
+
+	if check.ID != "passive" {
+		t.Fatalf("FindPassive() returned wrong check: %s", check.ID)
+	}
+
+	_, err = FindPassive(db, "wrong")
+	if err != ErrUnknownToken {
+		t.Fatalf("FindPassive() accepted wrong token")
+	}
+}
+
+func TestPushResult(t *testing.T) {
+	check := newPassive("passive")
+
+	cases := []struct {
+		push     Push
+		err      bool
+		failed   bool
+		severity string
+	}{
+		{Push{}, false, false, ""},
+		{Push{Status: "up", Values: plugins.AgentResult{"Files": 12.0}}, false, false, ""},
+		{Push{Status: "down", Message: "backup failed"}, false, true, ""},
+		{Push{Status: "warning"}, false, true, SeverityWarning},
+		{Push{Status: "critical"}, false, true, SeverityCritical},
+		{Push{Status: "sideways"}, true, false, ""},
+		{Push{Values: plugins.AgentResult{"no spaces": 1.0}}, true, false, ""},
+	}
+
+	for i, c := range cases {
+		result, err := c.push.result(time.Now(), check)
+		if (err != nil) != c.err {
+			t.Fatalf("%d: result() returned %v", i, err)
+		}
+
+		if err != nil {
+			if !errors.Is(err, ErrInvalidPush) {
+				t.Fatalf("%d: result() did not return ErrInvalidPush", i)
+			}
+
+			continue
+		}
+
+		if (result.Error != "") != c.failed || result.Severity != c.severity {
+			t.Fatalf("%d: result() returned wrong result: %+v", i, result)
+		}
+
+		if result.CheckHostID != CheckHostID(check.ID, "") {
+			t.Fatalf("%d: result() returned wrong CheckHostID: %s", i, result.CheckHostID)
+		}
+	}
+}
+
+func TestSchedulerPush(t *testing.T) {
+	db := boltdb.NewTestStore()
+
+	check := newPassive("passive")
+	check.Expressions = []string{"Files > 10"}
+	db.Save(check)
+
+	s := NewScheduler(db, "test")
+	clock := time.Now()
+
+	if s.deadman(clock, check) == nil {
+		t.Fatalf("deadman() accepted a check never pushed")
+	}
+
+	result, err := s.Push(check, &Push{Values: plugins.AgentResult{"Files": 12.0}})
+	if err != nil {
+		t.Fatalf("Push() failed: %s", err.Error())
+	}
+
+	if result.Error != "" || result.Node != "test" {
+		t.Fatalf("Push() returned wrong result: %+v", result)
+	}
+
+	result, _ = s.Push(check, &Push{Values: plugins.AgentResult{"Files": 2.0}})
+	if result.Error == "" {
+		t.Fatalf("Push() did not evaluate expressions")
+	}
+
+	_, err = s.Push(check, &Push{Status: "sideways"})
+	if !errors.Is(err, ErrInvalidPush) {
+		t.Fatalf("Push() accepted invalid status")
+	}
+
+	var saved []CheckResult
+	db.All(&saved, -1, 0, false)
+	if len(saved) != 2 {
+		t.Fatalf("Push() saved %d results, expected 2", len(saved))
+	}
+
+	if s.deadman(clock, check) != nil {
+		t.Fatalf("deadman() failed right after a push")
+	}
+
+	if s.deadman(clock.Add(2*time.Hour), check) == nil {
+		t.Fatalf("deadman() did not detect a missing push")
+	}
+
+	meta := &checkMeta{
+		check: *check,
+		key:   &metaKey{checkID: check.ID},
+	}
+
+	if s.runCheck(clock, meta) != nil {
+		t.Fatalf("runCheck() reported a passive check with a recent push")
+	}
+}
+
+func TestSchedulerDeadmanGrace(t *testing.T) {
+	db := boltdb.NewTestStore()
+	defer db.Close()
+
+	check := newPassive("grace")
+	db.Save(check)
+
+	s := NewScheduler(db, "test")
+
+	pushed := time.Now()
+	db.Save(&Heartbeat{ID: check.ID, TimeStamp: pushed})
+
+	cases := []struct {
+		since  time.Duration
+		missed bool
+	}{
+		{check.Interval, false},
+		// A job pushing once per interval drifts a few seconds.
+		{check.Interval + 5*time.Second, false},
+		{check.Interval + check.Interval/2, false},
+		{check.Interval + check.Interval/2 + time.Second, true},
+	}
+
+	for _, c := range cases {
+		result := s.deadman(pushed.Add(c.since), check)
+		if c.missed != (result != nil) {
+			t.Errorf("deadman() %s after the push returned %+v", c.since, result)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package checks
+
+import (
+	"sync"
+
+	"github.com/gansoi/gansoi/database"
+	"github.com/gansoi/gansoi/transports"
+	"github.com/gansoi/gansoi/transports/ssh"
+)
+
+type (
+	// ResultMatrix holds results by node and host. Results from checks not
+	// running on a host will use an empty host ID.
+	ResultMatrix map[string]map[string]*CheckResult
+)
+
+const (
+	// runAllConcurrency is the maximum number of hosts checked concurrently
+	// by RunAll.
+	runAllConcurrency = 10
+)
+
+// Add will add result to m.
+func (m ResultMatrix) Add(result *CheckResult) {
+	hosts, found := m[result.Node]
+	if !found {
+		hosts = make(map[string]*CheckResult)
+		m[result.Node] = hosts
+	}
+
+	hosts[result.HostID] = result
+}
+
+// Merge will add all results from other to m.
+func (m ResultMatrix) Merge(other ResultMatrix) {
+	for _, hosts := range other {
+		for _, result := range hosts {
+			m.Add(result)
+		}
+	}
+}
+
+// RunAll will run check once on all hosts, or once locally if check is not
+// running on any hosts. node is the name of the local node.
+func RunAll(db database.Reader, check *Check, node string) (ResultMatrix, error) {
+	hostIDs, err := check.HostIDs(db)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(hostIDs) == 0 {
+		hostIDs = []string{""}
+	}
+
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+
+	matrix := make(ResultMatrix)
+	slots := make(chan struct{}, runAllConcurrency)
+
+	for _, hostID := range hostIDs {
+		wg.Add(1)
+		slots <- struct{}{}
+
+		go func(hostID string) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			var transport transports.Transport
+
+			if hostID != "" {
+				host := &ssh.SSH{}
+
+				err := db.One("ID", hostID, host)
+				if err == nil {
+					transport = host
+				}
+			}
+
+			result := RunCheck(transport, check)
+			result.CheckHostID = CheckHostID(check.ID, hostID)
+			result.CheckID = check.ID
+			result.HostID = hostID
+			result.Node = node
+
+			lock.Lock()
+			matrix.Add(result)
+			lock.Unlock()
+		}(hostID)
+	}
+
+	wg.Wait()
+
+	return matrix, nil
+}
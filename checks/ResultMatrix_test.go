@@ -0,0 +1,73 @@
+package checks
+
+import (
+	"testing"
+
+	"github.com/gansoi/gansoi/boltdb"
+	"github.com/gansoi/gansoi/transports/ssh"
+)
+
+func TestResultMatrixMerge(t *testing.T) {
+	a := make(ResultMatrix)
+	a.Add(&CheckResult{Node: "a", HostID: "h1"})
+	a.Add(&CheckResult{Node: "a", HostID: "h2"})
+
+	b := make(ResultMatrix)
+	b.Add(&CheckResult{Node: "b", HostID: "h1"})
+
+	a.Merge(b)
+
+	if len(a) != 2 || len(a["a"]) != 2 || len(a["b"]) != 1 {
+		t.Fatalf("Merge() resulted in wrong matrix: %+v", a)
+	}
+}
+
+func TestRunAll(t *testing.T) {
+	db := boltdb.NewTestStore()
+
+	local := &Check{AgentID: "mock", Arguments: []byte("{}")}
+	local.ID = "local"
+
+	matrix, err := RunAll(db, local, "node")
+	if err != nil {
+		t.Fatalf("RunAll() failed: %s", err.Error())
+	}
+
+	result := matrix["node"][""]
+	if result == nil || result.Error != "" || result.CheckHostID != CheckHostID("local", "") {
+		t.Fatalf("RunAll() returned wrong result for local check: %+v", result)
+	}
+
+	for _, id := range []string{"h1", "h2"} {
+		host := &ssh.SSH{}
+		host.ID = id
+		db.Save(host)
+	}
+
+	remote := &Check{AgentID: "mockremote", Hosts: []string{"h1", "h2", "unknown"}, Arguments: []byte("{}")}
+	remote.ID = "remote"
+
+	matrix, err = RunAll(db, remote, "node")
+	if err != nil {
+		t.Fatalf("RunAll() failed: %s", err.Error())
+	}
+
+	if len(matrix["node"]) != 3 {
+		t.Fatalf("RunAll() returned %d results, expected 3", len(matrix["node"]))
+	}
+
+	for _, id := range []string{"h1", "h2"} {
+		if matrix["node"][id].Error != "" {
+			t.Errorf("RunAll() failed on %s: %s", id, matrix["node"][id].Error)
+		}
+	}
+
+	if matrix["node"]["unknown"].Error == "" {
+		t.Errorf("RunAll() did not fail for unknown host")
+	}
+
+	_, err = RunAll(db, &Check{HostSelector: "broken"}, "node")
+	if err == nil {
+		t.Errorf("RunAll() did not fail on broken host selector")
+	}
+}
@@ -0,0 +1,91 @@
+package checks
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gansoi/gansoi/database"
+)
+
+type (
+	// Rollup is a summary of all results for a check/host pair executed by a
+	// single node in a single period.
+	Rollup struct {
+		ID          string               `json:"id" storm:"id"`
+		CheckHostID string               `json:"check_host_id" storm:"index"`
+		CheckID     string               `json:"check_id"`
+		HostID      string               `json:"host_id"`
+		Node        string               `json:"node_id"`
+		Start       time.Time            `json:"start"`
+		End         time.Time            `json:"end"`
+		Runs        int                  `json:"runs"`
+		Failures    int                  `json:"failures"`
+		Values      map[string]Aggregate `json:"values"`
+	}
+
+	// Aggregate is the aggregate of a single numeric value.
+	Aggregate struct {
+		Min   float64 `json:"min"`
+		Max   float64 `json:"max"`
+		Avg   float64 `json:"avg"`
+		Sum   float64 `json:"sum"`
+		Count int     `json:"count"`
+	}
+)
+
+const (
+	// RollupPeriod is the period covered by a single rollup.
+	RollupPeriod = time.Hour
+)
+
+func init() {
+	database.RegisterType(Rollup{})
+}
+
+// rollupID returns the ID of the rollup for checkHostID executed by node
+// covering start. IDs will sort by time.
+func rollupID(checkHostID string, node string, start time.Time) string {
+	return fmt.Sprintf("%020d-%s-%s", start.Unix(), node, checkHostID)
+}
+
+// newRollup returns a new empty rollup for the period result belongs to.
+func newRollup(result *CheckResult, node string) *Rollup {
+	start := result.TimeStamp.Truncate(RollupPeriod)
+
+	return &Rollup{
+		ID:          rollupID(result.CheckHostID, node, start),
+		CheckHostID: result.CheckHostID,
+		CheckID:     result.CheckID,
+		HostID:      result.HostID,
+		Node:        node,
+		Start:       start,
+		End:         start.Add(RollupPeriod),
+		Values:      make(map[string]Aggregate),
+	}
+}
+
+// Add will add result to r. Non-numeric values are ignored.
+func (r *Rollup) Add(result *CheckResult) {
+	r.Runs++
+
+	if result.Error != "" {
+		r.Failures++
+	}
+
+	for key, f := range result.Results.Numeric() {
+		a, found := r.Values[key]
+		if !found || f < a.Min {
+			a.Min = f
+		}
+
+		if !found || f > a.Max {
+			a.Max = f
+		}
+
+		a.Sum += f
+		a.Count++
+		a.Avg = a.Sum / float64(a.Count)
+
+		r.Values[key] = a
+	}
+}
@@ -0,0 +1,50 @@
+package checks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gansoi/gansoi/plugins"
+)
+
+func TestRollupID(t *testing.T) {
+	early := rollupID("b::", "node2", time.Unix(3600, 0))
+	late := rollupID("a::", "node1", time.Unix(7200, 0))
+
+	if early >= late {
+		t.Fatalf("Rollup IDs does not sort by time: %s >= %s", early, late)
+	}
+}
+
+func TestRollupAdd(t *testing.T) {
+	clock := time.Date(2020, 1, 1, 12, 30, 0, 0, time.UTC)
+
+	results := []*CheckResult{
+		{CheckHostID: "a::", TimeStamp: clock, Results: plugins.AgentResult{"value": 2.0, "text": "hello"}},
+		{CheckHostID: "a::", TimeStamp: clock, Results: plugins.AgentResult{"value": int64(4)}},
+		{CheckHostID: "a::", TimeStamp: clock, Results: plugins.AgentResult{"value": 6}, Error: "failed"},
+	}
+
+	r := newRollup(results[0], "node1")
+
+	if !r.Start.Equal(time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Rollup starts at %s", r.Start)
+	}
+
+	for _, result := range results {
+		r.Add(result)
+	}
+
+	if r.Runs != 3 || r.Failures != 1 {
+		t.Fatalf("Wrong counts, runs: %d, failures: %d", r.Runs, r.Failures)
+	}
+
+	if _, found := r.Values["text"]; found {
+		t.Fatalf("Non-numeric value was rolled up")
+	}
+
+	expected := Aggregate{Min: 2, Max: 6, Avg: 4, Sum: 12, Count: 3}
+	if r.Values["value"] != expected {
+		t.Fatalf("Wrong aggregate %+v, expected %+v", r.Values["value"], expected)
+	}
+}
@@ -2,31 +2,66 @@ package checks
 
 import (
 	"expvar"
+	"fmt"
 	"time"
 
 	"github.com/gansoi/gansoi/database"
 	"github.com/gansoi/gansoi/logger"
+	"github.com/gansoi/gansoi/plugins"
 	"github.com/gansoi/gansoi/transports"
 	"github.com/gansoi/gansoi/transports/ssh"
 )
 
 type (
-	// Scheduler takes care of scheduling checks on the local node. For now
-	// it will spin four times each second.
+	// Scheduler takes care of scheduling checks on the local node. It will
+	// wake up when the next check is due. If the database implements
+	// Forwarder, results are forwarded to the leader for evaluation. If not,
+	// results are saved to the database.
 	Scheduler struct {
-		Results  chan *CheckResult
-		nodeName string
-		stop     chan struct{}
-		db       database.ReadWriter
-		store    *MetaStore
+		nodeName  string
+		stop      chan struct{}
+		db        database.ReadWriter
+		nodes     NodeLister
+		forwarder Forwarder
+		results   database.ReadWriter
+		recorder  Recorder
+		store     *MetaStore
+	}
+
+	// NodeLister can be implemented by databases aware of other nodes in
+	// the cluster. If the database passed to NewScheduler implements
+	// NodeLister, checks will be distributed across all live nodes.
+	NodeLister interface {
+		// LiveNodes should return the names of all live nodes.
+		LiveNodes() []string
+	}
+
+	// Forwarder can be implemented by databases able to deliver data to the
+	// leader without committing it to the Raft log.
+	Forwarder interface {
+		// Forward should deliver data to the leader.
+		Forward(data interface{}) error
+	}
+
+	// Recorder can record numeric values from results produced on the local
+	// node.
+	Recorder interface {
+		// Record should record the numeric values of result.
+		Record(result *CheckResult) error
 	}
 )
 
 var (
 	inflight        = expvar.NewInt("scheduler_inflight")
 	inflightOverrun = expvar.NewInt("scheduler_inflight_overrun")
+	queued          = expvar.NewInt("scheduler_queued")
 	started         = expvar.NewInt("scheduler_started")
 	failed          = expvar.NewInt("scheduler_failed")
+	rebalanced      = expvar.NewInt("scheduler_rebalanced")
+	timeouts        = expvar.NewInt("scheduler_timeouts")
+	forwardFailed   = expvar.NewInt("scheduler_forward_failed")
+	pushed          = expvar.NewInt("scheduler_pushed")
+	missed          = expvar.NewInt("scheduler_push_missed")
 )
 
 // NewScheduler instantiates a new scheduler.
@@ -34,16 +69,38 @@ func NewScheduler(db database.ReadWriteBroadcaster, nodeName string) *Scheduler
 	store, _ := newMetaStore(db)
 
 	s := &Scheduler{
-		Results:  make(chan *CheckResult, 1000),
 		nodeName: nodeName,
 		stop:     make(chan struct{}),
 		db:       db,
 		store:    store,
 	}
 
+	s.nodes, _ = db.(NodeLister)
+	s.forwarder, _ = db.(Forwarder)
+
 	return s
 }
 
+// SetLimits will limit the number of checks running concurrently on this
+// node. Checks exceeding the limits will be delayed until a slot is available.
+func (s *Scheduler) SetLimits(limits Limits) {
+	s.store.limiter.setLimits(limits)
+}
+
+// SetResultStore will make the scheduler save a copy of all results to rw.
+// This is only used if the database implements Forwarder, otherwise results
+// are saved to the database. The latest result is read back from rw when a
+// check is run for the first time after a restart or a change.
+func (s *Scheduler) SetResultStore(rw database.ReadWriter) {
+	s.results = rw
+}
+
+// SetRecorder will make the scheduler record all results produced on this
+// node to r.
+func (s *Scheduler) SetRecorder(r Recorder) {
+	s.recorder = r
+}
+
 // Run will start the event loop.
 func (s *Scheduler) Run() {
 	go s.loop()
@@ -55,17 +112,59 @@ func (s *Scheduler) Stop() {
 }
 
 func (s *Scheduler) loop() {
-	ticker := time.NewTicker(time.Millisecond * 250)
+	timer := time.NewTimer(0)
+	balance := time.NewTicker(time.Second * 2)
+
+	s.rebalance()
 
 	for {
 		select {
-		case t := <-ticker.C:
+		case t := <-timer.C:
 			s.spin(t)
 
+		case <-s.store.wakeup:
+			s.spin(time.Now())
+
+		case <-balance.C:
+			s.rebalance()
+
 		case <-s.stop:
-			ticker.Stop()
+			timer.Stop()
+			balance.Stop()
 			return
 		}
+
+		s.arm(timer)
+	}
+}
+
+// arm will reset timer to fire when the next check is due. If no checks are
+// queued, the timer is stopped, and we will wait for the MetaStore to wake us.
+func (s *Scheduler) arm(timer *time.Timer) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+
+	next, found := s.store.nextCheck()
+	if found {
+		timer.Reset(time.Until(next))
+	}
+}
+
+// rebalance will update the list of live nodes in the MetaStore. Check/host
+// pairs will move to other nodes as nodes join or leave the cluster.
+func (s *Scheduler) rebalance() {
+	if s.nodes == nil {
+		return
+	}
+
+	nodes := s.nodes.LiveNodes()
+	if s.store.setNodes(s.nodeName, nodes) {
+		rebalanced.Add(1)
+		logger.Info("scheduler", "Distributing checks across %d node(s): %v", len(nodes), nodes)
 	}
 }
 
@@ -85,16 +184,33 @@ func (s *Scheduler) runCheck(clock time.Time, meta *checkMeta) *CheckResult {
 	var checkResult *CheckResult
 	var transport transports.Transport
 
-	if meta.key.hostID != "" {
-		remote := ssh.SSH{}
+	if meta.check.Passive() {
+		checkResult = s.deadman(clock, &meta.check)
+		if checkResult == nil {
+			inflight.Add(-1)
+			s.store.Done(time.Now(), meta, nil)
+
+			return nil
+		}
+	} else {
+		if meta.key.hostID != "" {
+			remote := ssh.SSH{}
+
+			err := s.db.One("ID", meta.key.hostID, &remote)
+			if err == nil {
+				transport = &remote
+			}
+		}
 
-		err := s.db.One("ID", meta.key.hostID, &remote)
-		if err == nil {
-			transport = &remote
+		// The previous result is lost when the check is changed or moved
+		// between nodes, and on restart.
+		if meta.previous == nil {
+			meta.previous = s.lastResult(CheckHostID(meta.key.checkID, meta.key.hostID))
 		}
+
+		checkResult = execute(transport, &meta.check, meta.previous)
 	}
 
-	checkResult = RunCheck(transport, &meta.check)
 	checkResult.CheckHostID = CheckHostID(meta.key.checkID, meta.key.hostID)
 	checkResult.CheckID = meta.key.checkID
 	checkResult.HostID = meta.key.hostID
@@ -110,10 +226,128 @@ func (s *Scheduler) runCheck(clock time.Time, meta *checkMeta) *CheckResult {
 		logger.Debug("scheduler", "%s ran in %s: %+v", meta.check.ID, time.Since(start), checkResult.Results)
 	}
 
-	s.db.Save(checkResult)
-	s.Results <- checkResult
+	s.save(checkResult)
+
+	meta.previous = checkResult
 
-	s.store.Done(meta)
+	s.store.Done(time.Now(), meta, checkResult)
 
 	return checkResult
 }
+
+// lastResult returns the latest result saved on this node for checkHostID,
+// or nil if none is found.
+func (s *Scheduler) lastResult(checkHostID string) *CheckResult {
+	var db database.Reader = s.db
+
+	if s.forwarder != nil {
+		if s.results == nil {
+			return nil
+		}
+
+		db = s.results
+	}
+
+	var results []CheckResult
+
+	err := db.Find("CheckHostID", checkHostID, &results, 1, 0, true)
+	if err != nil || len(results) != 1 {
+		return nil
+	}
+
+	return &results[0]
+}
+
+// deadman returns a failed result if no result has been pushed to the
+// passive check within its interval and grace period. If a result has been
+// pushed, nil is returned. The pushed result speaks for itself.
+func (s *Scheduler) deadman(clock time.Time, check *Check) *CheckResult {
+	var heartbeat Heartbeat
+
+	deadline := check.Interval + time.Duration(float64(check.Interval)*passiveGrace)
+
+	err := s.db.One("ID", check.ID, &heartbeat)
+	if err == nil && clock.Sub(heartbeat.TimeStamp) <= deadline {
+		return nil
+	}
+
+	missed.Add(1)
+
+	message := "no result pushed"
+	if err == nil {
+		message = fmt.Sprintf("no result pushed since %s", heartbeat.TimeStamp.Format(time.RFC3339))
+	}
+
+	return &CheckResult{
+		TimeStamp: clock,
+		Error:     message,
+		Results:   plugins.NewAgentResult(),
+	}
+}
+
+// Push will accept a result pushed to a passive check. The result is treated
+// like any other result, and is forwarded to the leader for evaluation.
+func (s *Scheduler) Push(check *Check, push *Push) (*CheckResult, error) {
+	clock := time.Now()
+
+	checkResult, err := push.result(clock, check)
+	if err != nil {
+		return nil, err
+	}
+
+	checkResult.Node = s.nodeName
+
+	// Pushed values can be evaluated like values from agents.
+	if checkResult.Error == "" {
+		checkResult.Severity, err = check.Evaluate(checkResult, nil)
+		if err != nil {
+			checkResult.Error = err.Error()
+		}
+	}
+
+	err = s.db.Save(&Heartbeat{
+		ID:        check.ID,
+		Node:      s.nodeName,
+		TimeStamp: clock,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pushed.Add(1)
+
+	s.save(checkResult)
+
+	return checkResult, nil
+}
+
+// save will record checkResult, save it to the local result store and forward
+// it to the leader for evaluation. If the database can't forward, checkResult
+// is saved to the database.
+func (s *Scheduler) save(checkResult *CheckResult) {
+	if s.recorder != nil {
+		err := s.recorder.Record(checkResult)
+		if err != nil {
+			logger.Info("scheduler", "[%s] Failed to record result: %s", checkResult.CheckHostID, err.Error())
+		}
+	}
+
+	if s.forwarder == nil {
+		s.db.Save(checkResult)
+
+		return
+	}
+
+	if s.results != nil {
+		err := s.results.Save(checkResult)
+		if err != nil {
+			logger.Info("scheduler", "[%s] Failed to save result: %s", checkResult.CheckHostID, err.Error())
+		}
+	}
+
+	err := s.forwarder.Forward(checkResult)
+	if err != nil {
+		forwardFailed.Add(1)
+		logger.Info("scheduler", "[%s] Failed to forward result: %s", checkResult.CheckHostID, err.Error())
+	}
+}
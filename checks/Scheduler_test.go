@@ -8,6 +8,7 @@ import (
 
 	"github.com/gansoi/gansoi/boltdb"
 	"github.com/gansoi/gansoi/database"
+	"github.com/gansoi/gansoi/plugins"
 	"github.com/gansoi/gansoi/transports/ssh"
 )
 
@@ -165,6 +166,91 @@ func TestSchedulerRunCheck(t *testing.T) {
 	s.runCheck(clock, meta)
 }
 
+func TestSchedulerRunCheckPrevious(t *testing.T) {
+	c := Check{
+		AgentID:     "mock",
+		Arguments:   []byte("{}"),
+		Expressions: []string{`!changed("ran")`},
+	}
+
+	db := boltdb.NewTestStore()
+	s := NewScheduler(db, "test")
+
+	meta := &checkMeta{
+		check: c,
+		key:   &metaKey{},
+	}
+
+	result := s.runCheck(time.Now(), meta)
+	if result.Error != "" {
+		t.Fatalf("runCheck() failed: %s", result.Error)
+	}
+
+	if meta.previous != result {
+		t.Fatalf("runCheck() did not keep the result for the next run")
+	}
+}
+
+func TestSchedulerRunCheckPreviousUpdate(t *testing.T) {
+	forward := &forwardDB{TestStore: boltdb.NewTestStore()}
+	defer forward.Close()
+
+	results := boltdb.NewTestStore()
+	defer results.Close()
+
+	local := boltdb.NewTestStore()
+	defer local.Close()
+
+	cases := []struct {
+		db      database.ReadWriteBroadcaster
+		results database.ReadWriter
+	}{
+		{forward, results},
+		{local, nil},
+	}
+
+	for i, c := range cases {
+		check := &Check{
+			Interval:  time.Hour,
+			AgentID:   "mock",
+			Arguments: []byte("{}"),
+		}
+		check.ID = "previous"
+
+		c.db.Save(check)
+
+		s := NewScheduler(c.db, "test")
+		if c.results != nil {
+			s.SetResultStore(c.results)
+		}
+
+		// The mock agent always reports true, make the earlier run stand out.
+		earlier := &CheckResult{
+			CheckHostID: CheckHostID(check.ID, ""),
+			CheckID:     check.ID,
+			TimeStamp:   time.Now(),
+			Results:     plugins.NewAgentResult(),
+		}
+		earlier.Results.AddValue("ran", false)
+		s.save(earlier)
+
+		// Updating the check will replace the metadata.
+		check.Expressions = []string{`prev("ran") == false`}
+		c.db.Save(check)
+		s.store.PostApply(true, database.CommandSave, check)
+
+		meta := s.store.checks[check.ID][0]
+		if meta.previous != nil {
+			t.Fatalf("%d: Check update did not replace the metadata", i)
+		}
+
+		result := s.runCheck(time.Now(), meta)
+		if result.Error != "" {
+			t.Fatalf("%d: Previous result was lost on check update: %s", i, result.Error)
+		}
+	}
+}
+
 func TestSchedulerRunRemoteCheck(t *testing.T) {
 	c := Check{
 		Interval:  time.Millisecond * 100,
@@ -211,3 +297,124 @@ func TestSpinFail(t *testing.T) {
 	s := NewScheduler(db, "test")
 	s.spin(time.Now())
 }
+
+type (
+	clusterDB struct {
+		*boltdb.TestStore
+		nodes []string
+	}
+)
+
+func (c *clusterDB) LiveNodes() []string {
+	return c.nodes
+}
+
+func TestSchedulerRebalance(t *testing.T) {
+	db := &clusterDB{
+		TestStore: boltdb.NewTestStore(),
+		nodes:     []string{"test", "other"},
+	}
+
+	s := NewScheduler(db, "test")
+	if s.nodes == nil {
+		t.Fatalf("NewScheduler() did not detect NodeLister")
+	}
+
+	rebalanced.Set(0)
+	s.rebalance()
+	s.rebalance()
+
+	if rebalanced.Value() != 1 {
+		t.Fatalf("rebalance() did not detect node changes correctly, got %d", rebalanced.Value())
+	}
+
+	db.nodes = []string{"test"}
+	s.rebalance()
+
+	if rebalanced.Value() != 2 {
+		t.Fatalf("rebalance() did not detect a leaving node")
+	}
+}
+
+type (
+	forwardDB struct {
+		*boltdb.TestStore
+		forwarded []interface{}
+	}
+)
+
+func (f *forwardDB) Forward(data interface{}) error {
+	f.forwarded = append(f.forwarded, data)
+
+	return nil
+}
+
+func TestSchedulerForward(t *testing.T) {
+	db := &forwardDB{TestStore: boltdb.NewTestStore()}
+	defer db.Close()
+
+	results := boltdb.NewTestStore()
+	defer results.Close()
+
+	s := NewScheduler(db, "test")
+	s.SetResultStore(results)
+
+	meta := &checkMeta{
+		check: Check{AgentID: "mock", Arguments: []byte("{}")},
+		key:   &metaKey{checkID: "forward"},
+	}
+	s.runCheck(time.Now(), meta)
+
+	if len(db.forwarded) != 1 {
+		t.Fatalf("Result was not forwarded")
+	}
+
+	var saved []CheckResult
+
+	db.All(&saved, -1, 0, false)
+	if len(saved) != 0 {
+		t.Fatalf("Forwarded result was saved to the cluster database")
+	}
+
+	results.All(&saved, -1, 0, false)
+	if len(saved) != 1 {
+		t.Fatalf("Result was not saved to the local result store")
+	}
+}
+
+type (
+	mockRecorder struct {
+		recorded []*CheckResult
+	}
+)
+
+func (m *mockRecorder) Record(result *CheckResult) error {
+	m.recorded = append(m.recorded, result)
+
+	return nil
+}
+
+func TestSchedulerRecord(t *testing.T) {
+	forward := &forwardDB{TestStore: boltdb.NewTestStore()}
+	defer forward.Close()
+
+	local := boltdb.NewTestStore()
+	defer local.Close()
+
+	for _, db := range []database.ReadWriteBroadcaster{forward, local} {
+		recorder := &mockRecorder{}
+
+		s := NewScheduler(db, "test")
+		s.SetRecorder(recorder)
+
+		meta := &checkMeta{
+			check: Check{AgentID: "mock", Arguments: []byte("{}")},
+			key:   &metaKey{checkID: "record"},
+		}
+		s.runCheck(time.Now(), meta)
+
+		if len(recorder.recorded) != 1 {
+			t.Fatalf("Result was not recorded, got %d results", len(recorder.recorded))
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package checks
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Knetic/govaluate"
+
+	"github.com/gansoi/gansoi/plugins"
+)
+
+// expressionFunctions returns the functions available to expressions.
+// current is the result being evaluated, previous is the result from the
+// last run of the same check/host pair. previous can be nil.
+//
+// When a key has no previous value, prev() will return the current value,
+// delta() and rate() will return 0 and changed() will return false. That
+// way the first run after a restart will not fail.
+func expressionFunctions(current *CheckResult, previous *CheckResult) map[string]govaluate.ExpressionFunction {
+	// Expression parameters are numeric as float64, so we do the same for
+	// consistent comparisons.
+	normalize := func(value interface{}) interface{} {
+		f, ok := plugins.ToFloat(value)
+		if ok {
+			return f
+		}
+
+		return value
+	}
+
+	// values returns the current and previous value of key. found is false
+	// if no previous value is known.
+	values := func(args []interface{}) (interface{}, interface{}, bool, error) {
+		if len(args) != 1 {
+			return nil, nil, false, fmt.Errorf("expected a single key, got %d arguments", len(args))
+		}
+
+		key, ok := args[0].(string)
+		if !ok {
+			return nil, nil, false, fmt.Errorf("key must be a string")
+		}
+
+		cur, found := current.Results[key]
+		if !found {
+			return nil, nil, false, fmt.Errorf("unknown key '%s'", key)
+		}
+
+		cur = normalize(cur)
+
+		if previous == nil {
+			return cur, cur, false, nil
+		}
+
+		prev, found := previous.Results[key]
+		if !found {
+			return cur, cur, false, nil
+		}
+
+		return cur, normalize(prev), true, nil
+	}
+
+	// numbers returns the current and previous value of key as float64.
+	numbers := func(args []interface{}) (float64, float64, bool, error) {
+		cur, prev, found, err := values(args)
+		if err != nil {
+			return 0, 0, false, err
+		}
+
+		c, ok := plugins.ToFloat(cur)
+		if !ok {
+			return 0, 0, false, fmt.Errorf("'%v' is not numeric", args[0])
+		}
+
+		p, ok := plugins.ToFloat(prev)
+		if !ok {
+			// The previous value was not numeric. Act as if we have
+			// nothing to compare against.
+			return c, c, false, nil
+		}
+
+		return c, p, found, nil
+	}
+
+	return map[string]govaluate.ExpressionFunction{
+		// prev("Key") returns the value of Key from the previous run.
+		"prev": func(args ...interface{}) (interface{}, error) {
+			_, prev, _, err := values(args)
+
+			return prev, err
+		},
+
+		// delta("Key") returns the change in Key since the previous run.
+		"delta": func(args ...interface{}) (interface{}, error) {
+			cur, prev, _, err := numbers(args)
+
+			return cur - prev, err
+		},
+
+		// rate("Key") returns the change in Key per second since the
+		// previous run.
+		"rate": func(args ...interface{}) (interface{}, error) {
+			cur, prev, found, err := numbers(args)
+			if err != nil || !found {
+				return 0.0, err
+			}
+
+			seconds := current.TimeStamp.Sub(previous.TimeStamp).Seconds()
+			if seconds <= 0 {
+				return 0.0, nil
+			}
+
+			return (cur - prev) / seconds, nil
+		},
+
+		// changed("Key") returns true if Key differs from the previous run.
+		"changed": func(args ...interface{}) (interface{}, error) {
+			cur, prev, _, err := values(args)
+
+			return !reflect.DeepEqual(cur, prev), err
+		},
+
+		// match("pattern", value) returns true if the regular expression
+		// pattern matches value.
+		"match": func(args ...interface{}) (interface{}, error) {
+			if len(args) != 2 {
+				return nil, fmt.Errorf("match() expects a pattern and a value")
+			}
+
+			pattern, ok := args[0].(string)
+			if !ok {
+				return nil, fmt.Errorf("pattern must be a string")
+			}
+
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, err
+			}
+
+			return re.MatchString(fmt.Sprintf("%v", args[1])), nil
+		},
+
+		// contains(value, "substring") returns true if value contains
+		// substring.
+		"contains": func(args ...interface{}) (interface{}, error) {
+			if len(args) != 2 {
+				return nil, fmt.Errorf("contains() expects a value and a substring")
+			}
+
+			return strings.Contains(fmt.Sprintf("%v", args[0]), fmt.Sprintf("%v", args[1])), nil
+		},
+
+		// duration("1m30s") returns the duration in milliseconds, the unit
+		// agents use for durations.
+		"duration": func(args ...interface{}) (interface{}, error) {
+			if len(args) != 1 {
+				return nil, fmt.Errorf("duration() expects a single argument")
+			}
+
+			s, ok := args[0].(string)
+			if !ok {
+				return nil, fmt.Errorf("duration must be a string")
+			}
+
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return nil, err
+			}
+
+			return float64(d) / float64(time.Millisecond), nil
+		},
+	}
+}
@@ -0,0 +1,78 @@
+package checks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gansoi/gansoi/plugins"
+)
+
+func TestExpressionFunctions(t *testing.T) {
+	clock := time.Now()
+
+	previous := &CheckResult{
+		TimeStamp: clock.Add(-10 * time.Second),
+		Results: plugins.AgentResult{
+			"Queries":     1000,
+			"Fingerprint": "aa:bb",
+			"Status":      "ok",
+		},
+	}
+
+	current := &CheckResult{
+		TimeStamp: clock,
+		Results: plugins.AgentResult{
+			"Queries":     int64(6000),
+			"Fingerprint": "cc:dd",
+			"Status":      "ok",
+			"Version":     "5.7.31-log",
+			"Time":        1500.0,
+			"New":         1,
+		},
+	}
+
+	cases := []struct {
+		expression string
+		previous   *CheckResult
+		err        bool
+	}{
+		{`prev("Queries") == 1000`, previous, false},
+		{`delta("Queries") == 5000`, previous, false},
+		{`rate("Queries") == 500`, previous, false},
+		{`rate("Queries") > 1000`, previous, true},
+		{`changed("Fingerprint")`, previous, false},
+		{`!changed("Status")`, previous, false},
+		{`prev("Queries") == Queries`, nil, false},
+		{`delta("Queries") == 0`, nil, false},
+		{`rate("Queries") == 0`, nil, false},
+		{`!changed("Fingerprint")`, nil, false},
+		{`prev("New") == 1 && delta("New") == 0 && rate("New") == 0`, previous, false},
+		{`delta("Status") == 0`, previous, true},
+		{`prev("Unknown") == 0`, previous, true},
+		{`prev(1) == 0`, previous, true},
+		{`prev() == 0`, previous, true},
+		{`match("^5\\.7\\.", Version)`, nil, false},
+		{`match("^8\\.", Version)`, nil, true},
+		{`match("(", Version)`, nil, true},
+		{`match(Version)`, nil, true},
+		{`contains(Version, "log")`, nil, false},
+		{`contains(Version)`, nil, true},
+		{`Time < duration("2s")`, nil, false},
+		{`Time < duration("1s")`, nil, true},
+		{`Time < duration("forever")`, nil, true},
+		{`Time < duration(2)`, nil, true},
+	}
+
+	for _, c := range cases {
+		check := &Check{Expressions: []string{c.expression}}
+
+		_, err := check.Evaluate(current, c.previous)
+		if err != nil && !c.err {
+			t.Errorf("%s: Evaluate() failed: %s", c.expression, err.Error())
+		}
+
+		if err == nil && c.err {
+			t.Errorf("%s: Evaluate() did not fail", c.expression)
+		}
+	}
+}
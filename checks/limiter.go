@@ -0,0 +1,93 @@
+package checks
+
+import (
+	"sync"
+)
+
+type (
+	// Limits describes how many checks can be running at the same time on a
+	// single node. Zero means no limit.
+	Limits struct {
+		// Global is the maximum number of checks in flight.
+		Global int `json:"global"`
+
+		// PerHost is the maximum number of checks in flight against a
+		// single host.
+		PerHost int `json:"host"`
+
+		// PerAgent is the maximum number of checks in flight using the same
+		// agent.
+		PerAgent int `json:"agent"`
+	}
+
+	// limiter keeps track of checks in flight, and will refuse to start new
+	// checks when a limit is reached.
+	limiter struct {
+		sync.Mutex
+		limits   Limits
+		inflight int
+		hosts    map[string]int
+		agents   map[string]int
+	}
+)
+
+func newLimiter(limits Limits) *limiter {
+	return &limiter{
+		limits: limits,
+		hosts:  make(map[string]int),
+		agents: make(map[string]int),
+	}
+}
+
+// setLimits will change the limits. Checks already in flight are unaffected.
+func (l *limiter) setLimits(limits Limits) {
+	l.Lock()
+	l.limits = limits
+	l.Unlock()
+}
+
+// acquire will try to reserve a slot for meta. If any limit is reached, false
+// is returned, and the check should be tried again later.
+func (l *limiter) acquire(meta *checkMeta) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	hostID := meta.key.hostID
+	agentID := meta.check.AgentID
+
+	switch {
+	case l.limits.Global > 0 && l.inflight >= l.limits.Global:
+		return false
+	case l.limits.PerHost > 0 && hostID != "" && l.hosts[hostID] >= l.limits.PerHost:
+		return false
+	case l.limits.PerAgent > 0 && l.agents[agentID] >= l.limits.PerAgent:
+		return false
+	}
+
+	l.inflight++
+	l.hosts[hostID]++
+	l.agents[agentID]++
+
+	return true
+}
+
+// release will release the slot reserved by acquire().
+func (l *limiter) release(meta *checkMeta) {
+	l.Lock()
+	defer l.Unlock()
+
+	hostID := meta.key.hostID
+	agentID := meta.check.AgentID
+
+	l.inflight--
+
+	l.hosts[hostID]--
+	if l.hosts[hostID] <= 0 {
+		delete(l.hosts, hostID)
+	}
+
+	l.agents[agentID]--
+	if l.agents[agentID] <= 0 {
+		delete(l.agents, agentID)
+	}
+}
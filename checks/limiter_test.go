@@ -0,0 +1,68 @@
+package checks
+
+import (
+	"testing"
+)
+
+func TestLimiter(t *testing.T) {
+	meta := func(agentID string, hostID string) *checkMeta {
+		return &checkMeta{
+			check: Check{AgentID: agentID},
+			key:   &metaKey{checkID: agentID + hostID, hostID: hostID},
+		}
+	}
+
+	cases := []struct {
+		limits   Limits
+		metas    []*checkMeta
+		expected []bool
+	}{
+		{
+			Limits{},
+			[]*checkMeta{meta("a", ""), meta("a", ""), meta("a", "h")},
+			[]bool{true, true, true},
+		},
+		{
+			Limits{Global: 2},
+			[]*checkMeta{meta("a", ""), meta("b", ""), meta("c", "")},
+			[]bool{true, true, false},
+		},
+		{
+			Limits{PerHost: 1},
+			[]*checkMeta{meta("a", "h1"), meta("b", "h1"), meta("c", "h2"), meta("d", ""), meta("e", "")},
+			[]bool{true, false, true, true, true},
+		},
+		{
+			Limits{PerAgent: 1},
+			[]*checkMeta{meta("a", "h1"), meta("a", "h2"), meta("b", "h1")},
+			[]bool{true, false, true},
+		},
+	}
+
+	for i, c := range cases {
+		l := newLimiter(c.limits)
+
+		for j, m := range c.metas {
+			if l.acquire(m) != c.expected[j] {
+				t.Fatalf("%d.%d: acquire() returned %v", i, j, !c.expected[j])
+			}
+		}
+	}
+
+	l := newLimiter(Limits{Global: 1, PerHost: 1, PerAgent: 1})
+	m := meta("a", "h")
+
+	if !l.acquire(m) {
+		t.Fatalf("acquire() failed on empty limiter")
+	}
+
+	if l.acquire(m) {
+		t.Fatalf("acquire() succeeded with limits reached")
+	}
+
+	l.release(m)
+
+	if !l.acquire(m) {
+		t.Fatalf("acquire() failed after release()")
+	}
+}
@@ -0,0 +1,52 @@
+package checks
+
+type (
+	// metaQueue is a min-heap of checkMeta ordered by NextCheck. It
+	// implements heap.Interface, and should only be manipulated through
+	// container/heap.
+	metaQueue []*checkMeta
+)
+
+// Len implements sort.Interface.
+func (q metaQueue) Len() int {
+	return len(q)
+}
+
+// Less implements sort.Interface.
+func (q metaQueue) Less(i, j int) bool {
+	return q[i].NextCheck.Before(q[j].NextCheck)
+}
+
+// Swap implements sort.Interface.
+func (q metaQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+// Push implements heap.Interface.
+func (q *metaQueue) Push(x interface{}) {
+	meta := x.(*checkMeta)
+	meta.index = len(*q)
+
+	*q = append(*q, meta)
+}
+
+// Pop implements heap.Interface.
+func (q *metaQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+
+	meta := old[n-1]
+	old[n-1] = nil
+	meta.index = -1
+
+	*q = old[:n-1]
+
+	return meta
+}
+
+// contains returns true if meta is currently in the queue.
+func (q metaQueue) contains(meta *checkMeta) bool {
+	return meta.index >= 0 && meta.index < len(q) && q[meta.index] == meta
+}
@@ -0,0 +1,121 @@
+package checks
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+type (
+	// ring is a simple consistent hash ring used for distributing check/host
+	// pairs across cluster nodes. When a node leaves the ring, only the pairs
+	// owned by that node will move.
+	ring struct {
+		hashes []uint32
+		points map[uint32]string
+		nodes  []string
+	}
+)
+
+const (
+	// ringReplicas is the number of virtual nodes placed on the ring for
+	// each real node. More replicas gives a more even distribution.
+	ringReplicas = 64
+)
+
+// newRing returns a new ring populated with nodes.
+func newRing(nodes []string) *ring {
+	r := &ring{
+		points: make(map[uint32]string),
+	}
+
+	seen := make(map[string]bool)
+
+	for _, node := range nodes {
+		if seen[node] {
+			continue
+		}
+
+		seen[node] = true
+		r.nodes = append(r.nodes, node)
+
+		for i := 0; i < ringReplicas; i++ {
+			hash := crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + node))
+
+			r.hashes = append(r.hashes, hash)
+			r.points[hash] = node
+		}
+	}
+
+	sort.Strings(r.nodes)
+
+	sort.Slice(r.hashes, func(i, j int) bool {
+		return r.hashes[i] < r.hashes[j]
+	})
+
+	return r
+}
+
+// owner returns the node responsible for key. If the ring is empty, an empty
+// string is returned.
+func (r *ring) owner(key string) string {
+	owners := r.owners(key, 1)
+	if len(owners) == 0 {
+		return ""
+	}
+
+	return owners[0]
+}
+
+// owners returns n distinct nodes responsible for key. If n is negative or
+// larger than the number of nodes, all nodes are returned. The first node
+// will always be the same as returned by owner().
+func (r *ring) owners(key string, n int) []string {
+	if len(r.hashes) == 0 {
+		return nil
+	}
+
+	if n < 0 || n > len(r.nodes) {
+		n = len(r.nodes)
+	}
+
+	hash := crc32.ChecksumIEEE([]byte(key))
+
+	i := sort.Search(len(r.hashes), func(i int) bool {
+		return r.hashes[i] >= hash
+	})
+
+	owners := make([]string, 0, n)
+	seen := make(map[string]bool)
+
+	// Walk the ring - wrapping around to the beginning - until we have
+	// found enough distinct nodes.
+	for j := 0; len(owners) < n && j < len(r.hashes); j++ {
+		node := r.points[r.hashes[(i+j)%len(r.hashes)]]
+
+		if !seen[node] {
+			seen[node] = true
+			owners = append(owners, node)
+		}
+	}
+
+	return owners
+}
+
+// equal returns true if r consists of the same nodes as nodes.
+func (r *ring) equal(nodes []string) bool {
+	if len(r.nodes) != len(nodes) {
+		return false
+	}
+
+	sorted := append([]string{}, nodes...)
+	sort.Strings(sorted)
+
+	for i, node := range sorted {
+		if r.nodes[i] != node {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,99 @@
+package checks
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRingEmpty(t *testing.T) {
+	r := newRing(nil)
+
+	if r.owner("hello") != "" {
+		t.Fatalf("owner() returned an owner for an empty ring")
+	}
+}
+
+func TestRingDistribution(t *testing.T) {
+	nodes := []string{"node1", "node2", "node3"}
+	r := newRing(nodes)
+
+	count := make(map[string]int)
+	for i := 0; i < 3000; i++ {
+		count[r.owner(fmt.Sprintf("check%d::", i))]++
+	}
+
+	for _, node := range nodes {
+		if count[node] < 500 {
+			t.Errorf("%s only owns %d of 3000 keys", node, count[node])
+		}
+	}
+}
+
+func TestRingNodeLeave(t *testing.T) {
+	before := newRing([]string{"node1", "node2", "node3"})
+	after := newRing([]string{"node1", "node3"})
+
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("check%d::host", i)
+
+		owner := before.owner(key)
+		if owner != "node2" && after.owner(key) != owner {
+			t.Fatalf("%s moved from %s to %s", key, owner, after.owner(key))
+		}
+
+		if after.owner(key) == "node2" {
+			t.Fatalf("%s is owned by a node no longer in the ring", key)
+		}
+	}
+}
+
+func TestRingEqual(t *testing.T) {
+	r := newRing([]string{"b", "a"})
+
+	cases := []struct {
+		nodes    []string
+		expected bool
+	}{
+		{[]string{"a", "b"}, true},
+		{[]string{"b", "a"}, true},
+		{[]string{"a"}, false},
+		{[]string{"a", "c"}, false},
+		{nil, false},
+	}
+
+	for i, c := range cases {
+		if r.equal(c.nodes) != c.expected {
+			t.Errorf("%d: equal(%v) returned %v", i, c.nodes, !c.expected)
+		}
+	}
+}
+
+func TestRingOwners(t *testing.T) {
+	r := newRing([]string{"node1", "node2", "node3", "node1"})
+
+	cases := []struct {
+		n        int
+		expected int
+	}{
+		{1, 1},
+		{2, 2},
+		{3, 3},
+		{4, 3},
+		{-1, 3},
+	}
+
+	for i, c := range cases {
+		owners := r.owners("check::host", c.n)
+		if len(owners) != c.expected {
+			t.Fatalf("%d: owners() returned %d nodes, expected %d", i, len(owners), c.expected)
+		}
+
+		if owners[0] != r.owner("check::host") {
+			t.Fatalf("%d: owners() does not agree with owner()", i)
+		}
+	}
+
+	if newRing(nil).owners("check::host", 2) != nil {
+		t.Fatalf("owners() returned nodes for an empty ring")
+	}
+}
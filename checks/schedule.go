@@ -0,0 +1,307 @@
+package checks
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// schedule is a parsed cron expression in the classic five field format:
+	// minute, hour, day of month, month and day of week. Each field is stored
+	// as a bitset of allowed values.
+	schedule struct {
+		minute   uint64
+		hour     uint64
+		dom      uint64
+		month    uint64
+		dow      uint64
+		location *time.Location
+
+		// If both day of month and day of week are restricted, a day matches
+		// if either matches - just like Vixie cron. A field starting with
+		// "*" is unrestricted, even with a step.
+		domStar bool
+		dowStar bool
+
+		// hourStar is true if the hour field starts with "*". Like Vixie
+		// cron, such schedules run at every matching time as it passes,
+		// others are matched against the wall clock to survive daylight
+		// saving time transitions.
+		hourStar bool
+	}
+
+	// field describes the valid range and names for a single cron field.
+	field struct {
+		min   int
+		max   int
+		names map[string]int
+	}
+)
+
+var (
+	minutes = field{0, 59, nil}
+	hours   = field{0, 23, nil}
+	doms    = field{1, 31, nil}
+	months  = field{1, 12, map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+
+	// Day of week allows 7 as an alias for sunday.
+	dows = field{0, 7, map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+const (
+	// scheduleHorizon is how far into the future we will look for the next
+	// matching time. An expression like "0 0 30 2 *" will never match.
+	scheduleHorizon = 5
+)
+
+// parseSchedule will parse the cron expression expr. Times will be matched in
+// the time zone named by tz - or in UTC if tz is empty.
+func parseSchedule(expr string, tz string) (*schedule, error) {
+	location, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields in schedule, got %d", len(fields))
+	}
+
+	s := &schedule{
+		location: location,
+		hourStar: strings.HasPrefix(fields[1], "*"),
+		domStar:  strings.HasPrefix(fields[2], "*"),
+		dowStar:  strings.HasPrefix(fields[4], "*"),
+	}
+
+	targets := []struct {
+		bits *uint64
+		f    field
+	}{
+		{&s.minute, minutes},
+		{&s.hour, hours},
+		{&s.dom, doms},
+		{&s.month, months},
+		{&s.dow, dows},
+	}
+
+	for i, target := range targets {
+		*target.bits, err = target.f.parse(fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", fields[i], err.Error())
+		}
+	}
+
+	// Sunday can be both 0 and 7.
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+
+	if s.next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("schedule '%s' never matches", expr)
+	}
+
+	return s, nil
+}
+
+// parse will parse a comma separated list of values, ranges and steps.
+func (f field) parse(expr string) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(expr, ",") {
+		step := 1
+
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			var err error
+
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step '%s'", part[i+1:])
+			}
+
+			part = part[:i]
+		}
+
+		low, high := f.min, f.max
+
+		switch i := strings.IndexByte(part, '-'); {
+		case part == "*":
+		case i >= 0:
+			var err error
+
+			low, err = f.value(part[:i])
+			if err != nil {
+				return 0, err
+			}
+
+			high, err = f.value(part[i+1:])
+			if err != nil {
+				return 0, err
+			}
+
+			if low > high {
+				return 0, fmt.Errorf("invalid range '%s'", part)
+			}
+		default:
+			var err error
+
+			low, err = f.value(part)
+			if err != nil {
+				return 0, err
+			}
+
+			// "5/10" means from 5 to the end in steps of 10.
+			if step == 1 {
+				high = low
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+// value will parse a single value or name.
+func (f field) value(s string) (int, error) {
+	if v, found := f.names[strings.ToLower(s)]; found {
+		return v, nil
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value '%s'", s)
+	}
+
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("%d out of range [%d-%d]", v, f.min, f.max)
+	}
+
+	return v, nil
+}
+
+// dayMatches returns true if the day t is allowed by s.
+func (s *schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+
+	return dom || dow
+}
+
+// next returns the first time after clock matching s. If no such time exists
+// within a few years, the zero time is returned.
+//
+// Daylight saving time is handled like cron does. If the hour is restricted,
+// a time skipped when the clock springs forward will match once right after
+// the gap, and a time repeated when the clock falls back will only match the
+// first time. Schedules running every hour simply match times as they pass.
+func (s *schedule) next(clock time.Time) time.Time {
+	if s.hourStar {
+		start := clock.In(s.location).Truncate(time.Minute).Add(time.Minute)
+
+		return s.match(start)
+	}
+
+	// The wall clock is represented in UTC, where every day has 24 hours.
+	w := wallClock(clock.In(s.location)).Add(time.Minute)
+
+	for {
+		w = s.match(w)
+		if w.IsZero() {
+			return w
+		}
+
+		t := s.resolve(w)
+		if t.After(clock) {
+			return t
+		}
+
+		// We're in the repeated hour, and the first occurrence has passed.
+		w = w.Add(time.Minute)
+	}
+}
+
+// match returns the first time from t matching s in the location of t. If no
+// such time exists within a few years, the zero time is returned.
+func (s *schedule) match(t time.Time) time.Time {
+	location := t.Location()
+	horizon := t.Year() + scheduleHorizon
+
+	for t.Year() <= horizon {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, location)
+			continue
+		}
+
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, location)
+			continue
+		}
+
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, location)
+			continue
+		}
+
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+// resolve returns the first time the wall clock in s.location shows w. If
+// the wall clock skipped w, the time right after the gap is returned.
+func (s *schedule) resolve(w time.Time) time.Time {
+	t := time.Date(w.Year(), w.Month(), w.Day(), w.Hour(), w.Minute(), 0, 0, s.location)
+
+	// The offsets in effect around t are the only candidates.
+	var first time.Time
+
+	for _, probe := range []time.Duration{-12 * time.Hour, 0, 12 * time.Hour} {
+		_, offset := t.Add(probe).Zone()
+
+		candidate := w.Add(-time.Duration(offset) * time.Second).In(s.location)
+		if wallClock(candidate).Equal(w) && (first.IsZero() || candidate.Before(first)) {
+			first = candidate
+		}
+	}
+
+	if !first.IsZero() {
+		return first
+	}
+
+	// Find the first minute after the gap.
+	for !wallClock(t).After(w) {
+		t = t.Add(time.Minute)
+	}
+
+	for wallClock(t.Add(-time.Minute)).After(w) {
+		t = t.Add(-time.Minute)
+	}
+
+	return t
+}
+
+// wallClock returns the wall clock of t truncated to the minute, as if it
+// was in UTC.
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+}
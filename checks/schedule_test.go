@@ -0,0 +1,153 @@
+package checks
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	cases := []struct {
+		expr string
+		tz   string
+		err  bool
+	}{
+		{"* * * * *", "", false},
+		{"30 2 * * *", "UTC", false},
+		{"*/5 9-17 * * mon-fri", "", false},
+		{"0 0 1,15 jan,jul sun", "", false},
+		{"0 0 * * 7", "", false},
+		{"5/10 * * * *", "", false},
+		{"* * * *", "", true},
+		{"60 * * * *", "", true},
+		{"* 24 * * *", "", true},
+		{"* * 0 * *", "", true},
+		{"* * * 13 *", "", true},
+		{"* * * * 8", "", true},
+		{"*/0 * * * *", "", true},
+		{"10-5 * * * *", "", true},
+		{"a * * * *", "", true},
+		{"0 0 30 feb *", "", true},
+		{"* * * * *", "Nowhere/Nothing", true},
+	}
+
+	for i, c := range cases {
+		_, err := parseSchedule(c.expr, c.tz)
+		if (err != nil) != c.err {
+			t.Errorf("%d: parseSchedule(%s) returned %v", i, c.expr, err)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	// 2020-01-01 is a Wednesday.
+	clock := time.Date(2020, 1, 1, 12, 0, 30, 0, time.UTC)
+
+	cases := []struct {
+		expr     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2020, 1, 1, 12, 1, 0, 0, time.UTC)},
+		{"30 2 * * *", time.Date(2020, 1, 2, 2, 30, 0, 0, time.UTC)},
+		{"*/5 9-17 * * mon-fri", time.Date(2020, 1, 1, 12, 5, 0, 0, time.UTC)},
+		{"0 9 * * sat", time.Date(2020, 1, 4, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 7", time.Date(2020, 1, 5, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)},
+
+		// Day of month OR day of week when both are restricted.
+		{"0 0 15 * fri", time.Date(2020, 1, 3, 0, 0, 0, 0, time.UTC)},
+
+		// A step is not a restriction, both must match. 2020-01-04 is a
+		// saturday on an even day.
+		{"0 0 */2 * sat", time.Date(2020, 1, 11, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for i, c := range cases {
+		s, err := parseSchedule(c.expr, "UTC")
+		if err != nil {
+			t.Fatalf("%d: parseSchedule(%s) failed: %s", i, c.expr, err.Error())
+		}
+
+		next := s.next(clock)
+		if !next.Equal(c.expected) {
+			t.Errorf("%d: next() for '%s' returned %s, expected %s", i, c.expr, next, c.expected)
+		}
+	}
+}
+
+func TestScheduleNextTimeZone(t *testing.T) {
+	s, err := parseSchedule("30 2 * * *", "America/New_York")
+	if err != nil {
+		t.Skipf("Time zone not available: %s", err.Error())
+	}
+
+	clock := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	expected := time.Date(2020, 1, 1, 7, 30, 0, 0, time.UTC)
+
+	next := s.next(clock)
+	if !next.Equal(expected) {
+		t.Fatalf("next() returned %s, expected %s", next, expected)
+	}
+}
+
+func TestScheduleNextDST(t *testing.T) {
+	_, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("Time zone not available: %s", err.Error())
+	}
+
+	// In 2020 New York springs forward from 02:00 EST to 03:00 EDT on March
+	// 8th, and falls back from 02:00 EDT to 01:00 EST on November 1st.
+	cases := []struct {
+		expr     string
+		clock    time.Time
+		expected []time.Time
+	}{
+		// Skipped times run once right after the gap.
+		{"30 2 * * *", time.Date(2020, 3, 8, 5, 0, 0, 0, time.UTC), []time.Time{
+			time.Date(2020, 3, 8, 7, 0, 0, 0, time.UTC),
+			time.Date(2020, 3, 9, 6, 30, 0, 0, time.UTC),
+		}},
+		{"0,30 2 * * *", time.Date(2020, 3, 8, 5, 0, 0, 0, time.UTC), []time.Time{
+			time.Date(2020, 3, 8, 7, 0, 0, 0, time.UTC),
+			time.Date(2020, 3, 9, 6, 0, 0, 0, time.UTC),
+		}},
+
+		// Repeated times run once.
+		{"30 1 * * *", time.Date(2020, 11, 1, 4, 0, 0, 0, time.UTC), []time.Time{
+			time.Date(2020, 11, 1, 5, 30, 0, 0, time.UTC),
+			time.Date(2020, 11, 2, 6, 30, 0, 0, time.UTC),
+		}},
+		{"45 1 * * *", time.Date(2020, 11, 1, 6, 10, 0, 0, time.UTC), []time.Time{
+			time.Date(2020, 11, 2, 6, 45, 0, 0, time.UTC),
+		}},
+
+		// Hourly schedules run as time passes.
+		{"30 * * * *", time.Date(2020, 3, 8, 6, 30, 0, 0, time.UTC), []time.Time{
+			time.Date(2020, 3, 8, 7, 30, 0, 0, time.UTC),
+		}},
+		{"30 * * * *", time.Date(2020, 11, 1, 5, 0, 0, 0, time.UTC), []time.Time{
+			time.Date(2020, 11, 1, 5, 30, 0, 0, time.UTC),
+			time.Date(2020, 11, 1, 6, 30, 0, 0, time.UTC),
+			time.Date(2020, 11, 1, 7, 30, 0, 0, time.UTC),
+		}},
+	}
+
+	for i, c := range cases {
+		s, err := parseSchedule(c.expr, "America/New_York")
+		if err != nil {
+			t.Fatalf("%d: parseSchedule(%s) failed: %s", i, c.expr, err.Error())
+		}
+
+		clock := c.clock
+		for _, expected := range c.expected {
+			next := s.next(clock)
+			if !next.Equal(expected) {
+				t.Errorf("%d: next(%s) for '%s' returned %s, expected %s", i, clock, c.expr, next.UTC(), expected)
+			}
+
+			clock = next
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package checks
+
+import (
+	"fmt"
+	"strings"
+)
+
+type (
+	// selector is a parsed label selector like "role=db,env!=test". A set of
+	// labels matches if all requirements are met.
+	selector []requirement
+
+	// requirement is a single requirement in a selector.
+	requirement struct {
+		key    string
+		value  string
+		negate bool
+	}
+)
+
+// parseSelector will parse a comma separated list of key=value and
+// key!=value requirements.
+func parseSelector(expr string) (selector, error) {
+	var sel selector
+
+	for _, part := range strings.Split(expr, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		var req requirement
+
+		i := strings.Index(part, "=")
+		if i < 1 {
+			return nil, fmt.Errorf("invalid requirement '%s', expected key=value", part)
+		}
+
+		req.key = part[:i]
+		req.value = strings.TrimSpace(part[i+1:])
+
+		if strings.HasSuffix(req.key, "!") {
+			req.key = req.key[:len(req.key)-1]
+			req.negate = true
+		}
+
+		req.key = strings.TrimSpace(req.key)
+		if req.key == "" {
+			return nil, fmt.Errorf("invalid requirement '%s', missing key", part)
+		}
+
+		sel = append(sel, req)
+	}
+
+	if len(sel) == 0 {
+		return nil, fmt.Errorf("empty selector")
+	}
+
+	return sel, nil
+}
+
+// matches returns true if labels satisfies all requirements in s.
+func (s selector) matches(labels map[string]string) bool {
+	for _, req := range s {
+		value, found := labels[req.key]
+
+		if req.negate == (found && value == req.value) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,63 @@
+package checks
+
+import (
+	"testing"
+)
+
+func TestParseSelector(t *testing.T) {
+	cases := []struct {
+		expr string
+		err  bool
+		len  int
+	}{
+		{"role=db", false, 1},
+		{"role=db,env=prod", false, 2},
+		{" role = db , env != test ", false, 2},
+		{"role=", false, 1},
+		{"", true, 0},
+		{",", true, 0},
+		{"role", true, 0},
+		{"=db", true, 0},
+		{"!=db", true, 0},
+	}
+
+	for i, c := range cases {
+		sel, err := parseSelector(c.expr)
+		if (err != nil) != c.err {
+			t.Errorf("%d: parseSelector(%s) returned %v", i, c.expr, err)
+		}
+
+		if len(sel) != c.len {
+			t.Errorf("%d: parseSelector(%s) returned %d requirements, expected %d", i, c.expr, len(sel), c.len)
+		}
+	}
+}
+
+func TestSelectorMatches(t *testing.T) {
+	labels := map[string]string{
+		"role": "db",
+		"env":  "prod",
+	}
+
+	cases := []struct {
+		expr    string
+		matches bool
+	}{
+		{"role=db", true},
+		{"role=db,env=prod", true},
+		{"role=db,env=test", false},
+		{"role=web", false},
+		{"env!=test", true},
+		{"env!=prod", false},
+		{"dc!=eu", true},
+		{"dc=eu", false},
+	}
+
+	for i, c := range cases {
+		sel, _ := parseSelector(c.expr)
+
+		if sel.matches(labels) != c.matches {
+			t.Errorf("%d: %s returned %v", i, c.expr, !c.matches)
+		}
+	}
+}
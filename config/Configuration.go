@@ -25,6 +25,8 @@ type (
 		Checks           map[string]*checks.Check        `json:"checks"`
 		ContactGroups    map[string]*notify.ContactGroup `json:"contactgroups"`
 		Contacts         map[string]*notify.Contact      `json:"contacts"`
+		Limits           checks.Limits                   `json:"limits"`
+		Retention        checks.Retention                `json:"retention"`
 
 		knownChecks        map[string]bool
 		knownHosts         map[string]bool
@@ -56,6 +58,9 @@ func NewConfiguration() *Configuration {
 
 	c.Bind = ":4934"
 
+	// Keep raw results for a few days, and rollups for a few months.
+	c.Retention = checks.DefaultRetention
+
 	// This makes sense on a unix system.
 	c.DataDir = "/var/lib/gansoi"
 
@@ -12,6 +12,10 @@ const (
 
 	// CommandDelete will delete an object in the local database.
 	CommandDelete
+
+	// CommandForward is used for objects forwarded to the leader without
+	// being committed to the Raft log. Nothing is saved.
+	CommandForward
 )
 
 // String implements Stringer.
@@ -23,6 +27,9 @@ func (c Command) String() string {
 	case CommandDelete:
 		return "delete"
 
+	case CommandForward:
+		return "forward"
+
 	default:
 		return "n/a"
 	}
@@ -11,6 +11,7 @@ func TestCommandString(t *testing.T) {
 	}{
 		{CommandSave, "save"},
 		{CommandDelete, "delete"},
+		{CommandForward, "forward"},
 		{Command(200), "n/a"},
 	}
 
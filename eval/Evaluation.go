@@ -17,9 +17,16 @@ type (
 		HostID      string               `json:"host_id"`
 		History     States               `json:"history"`
 		State       State                `json:"state"`
+		Soft        bool                 `json:"soft"`
+		Attempts    int                  `json:"attempts"`
+		Maintenance bool                 `json:"maintenance"`
+		Flapping    bool                 `json:"flapping"`
+		FlapScore   float64              `json:"flapscore"`
+		Recent      States               `json:"recent,omitempty"`
 		Start       time.Time            `json:"start"`
 		End         time.Time            `json:"end"`
 		Hosts       map[string]State     `json:"hosts"`
+		Nodes       map[string]State     `json:"nodes,omitempty"`
 		Results     []checks.CheckResult `json:"-"`
 	}
 )
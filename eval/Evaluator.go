@@ -1,16 +1,20 @@
 package eval
 
 import (
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/gansoi/gansoi/checks"
 	"github.com/gansoi/gansoi/database"
 	"github.com/gansoi/gansoi/logger"
+	"github.com/gansoi/gansoi/transports/ssh"
 )
 
 type (
 	// Evaluator will evaluate check results from all nodes on the leader node.
 	Evaluator struct {
+		sync.Mutex
 		db            database.ReadWriter
 		historyLength int
 	}
@@ -27,25 +31,63 @@ func NewEvaluator(db database.ReadWriter) *Evaluator {
 	return e
 }
 
-func statesFromHistory(history []checks.CheckResult) States {
-	var states States
+// PostApply implements database.Listener. Check results from all nodes will
+// be forwarded to the leader, and will be evaluated here. Results saved to
+// the database are evaluated as well.
+func (e *Evaluator) PostApply(leader bool, command database.Command, data interface{}) {
+	if !leader {
+		return
+	}
 
-	for _, result := range history {
-		state := StateDown
+	if command != database.CommandSave && command != database.CommandForward {
+		return
+	}
 
-		if result.Error == "" {
-			state = StateUp
-		}
+	checkResult, isCheckResult := data.(*checks.CheckResult)
+	if !isCheckResult {
+		return
+	}
+
+	_, err := e.Evaluate(checkResult)
+	if err != nil {
+		logger.Info("eval", "[%s] Evaluation failed: %s", checkResult.CheckHostID, err.Error())
+	}
+}
 
-		states = append(states, state)
+func statesFromHistory(history []checks.CheckResult) States {
+	var states States
+
+	for i := range history {
+		states = append(states, stateFromResult(&history[i]))
 	}
 
 	return states
 }
 
+// stateFromResult returns the state of a single result.
+func stateFromResult(result *checks.CheckResult) State {
+	switch {
+	case result.Error == "":
+		return StateUp
+	case result.Severity == checks.SeverityWarning:
+		return StateWarning
+	case result.Severity == checks.SeverityCritical:
+		return StateCritical
+	case result.Severity == checks.SeverityUnknown:
+		return StateUnknown
+	default:
+		return StateDown
+	}
+}
+
 // Evaluate will evaluate a CheckResult and return an Evaluation including
 // current state.
 func (e *Evaluator) Evaluate(checkResult *checks.CheckResult) (*Evaluation, error) {
+	// PostApply is called from multiple goroutines, make sure we evaluate
+	// one result at a time.
+	e.Lock()
+	defer e.Unlock()
+
 	clock := time.Now()
 
 	// Get latest evaluation.
@@ -56,16 +98,59 @@ func (e *Evaluator) Evaluate(checkResult *checks.CheckResult) (*Evaluation, erro
 
 	eval.End = clock
 
-	if len(eval.Results) >= e.historyLength {
-		eval.Results = eval.Results[len(eval.Results)-e.historyLength+1 : 5]
-	}
+	var check checks.Check
+	found := e.db.One("ID", checkResult.CheckID, &check) == nil
 
-	results := append(eval.Results, *checkResult)
-	history := statesFromHistory(results)
+	var nodes map[string]State
+	var results []checks.CheckResult
+	var history States
 
 	state := StateUnknown
-	if len(results) == e.historyLength {
-		state = history.Reduce()
+	soft := false
+	attempts := 0
+
+	// If the check is not found, check is empty and the defaults apply.
+	window := check.Policy.WindowSize(e.historyLength)
+
+	// Every result pushed to a passive check counts. They are too rare to
+	// wait for more.
+	if check.Passive() {
+		window = check.Policy.WindowSize(1)
+	}
+
+	switch {
+	case found && check.MultiNode():
+		// Checks executed from multiple nodes are evaluated per node.
+		results, nodes = e.evaluateNodes(clock, &check, append(eval.Results, *checkResult), eval.Nodes)
+		history = statesFromHistory(results)
+		state = reduceNodes(nodes, check.Quorum)
+
+	case found && check.MaxAttempts > 0:
+		results = trimResults(append(eval.Results, *checkResult), window)
+		history = statesFromHistory(results)
+		state, soft, attempts = evaluateAttempts(eval, &check, checkResult)
+
+	default:
+		results = trimResults(append(eval.Results, *checkResult), window)
+		history = statesFromHistory(results)
+		state = reduceHistory(history, &check.Policy, window, eval.State)
+	}
+
+	// If something we depend on is down, we're expected to be down.
+	if found && (state == StateDown || state == StateCritical) && e.unreachable(&check, checkResult.HostID) {
+		state = StateUnreachable
+	}
+
+	// Flap detection will look at evaluated states, they could be soft or
+	// the result of multiple nodes agreeing.
+	recent := append(States{}, eval.Recent...)
+	flapping, flapScore := false, 0.0
+
+	if found && check.FlapDetection.Window > 0 {
+		recent.Add(state, check.FlapDetection.Window)
+		flapping, flapScore = detectFlapping(recent, eval.Flapping, &check.FlapDetection)
+	} else {
+		recent = nil
 	}
 
 	// If the state has changed, we allocate a new evaluation and end the old.
@@ -78,8 +163,15 @@ func (e *Evaluator) Evaluate(checkResult *checks.CheckResult) (*Evaluation, erro
 		eval = nextEval
 	}
 
+	eval.Recent = recent
+	eval.Flapping = flapping
+	eval.FlapScore = flapScore
 	eval.History = history
 	eval.Results = results
+	eval.Nodes = nodes
+	eval.Soft = soft
+	eval.Attempts = attempts
+	eval.Maintenance = InMaintenance(e.db, clock, checkResult.CheckID, checkResult.HostID)
 
 	logger.Debug("eval", "%s: %s (%s) %s", eval.CheckHostID, eval.History.Reduce().ColorString(), eval.End.Sub(eval.Start).String(), eval.History.ColorString())
 
@@ -90,6 +182,146 @@ func (e *Evaluator) Evaluate(checkResult *checks.CheckResult) (*Evaluation, erro
 	return eval, eval.Save(e.db)
 }
 
+// trimResults returns the latest window results.
+func trimResults(results []checks.CheckResult, window int) []checks.CheckResult {
+	if len(results) > window {
+		return results[len(results)-window:]
+	}
+
+	return results
+}
+
+// evaluateNodes will evaluate results from each node individually. The results
+// still relevant are returned together with the state as seen from each node.
+// Nodes not reporting for three intervals is ignored, they have most likely
+// left the cluster. previous is the state as seen from each node at the last
+// evaluation.
+func (e *Evaluator) evaluateNodes(clock time.Time, check *checks.Check, results []checks.CheckResult, previous map[string]State) ([]checks.CheckResult, map[string]State) {
+	window := check.Policy.WindowSize(e.historyLength)
+
+	perNode := make(map[string][]checks.CheckResult)
+
+	for _, result := range results {
+		perNode[result.Node] = append(perNode[result.Node], result)
+	}
+
+	var kept []checks.CheckResult
+	states := make(map[string]State)
+
+	for node, nodeResults := range perNode {
+		nodeResults = trimResults(nodeResults, window)
+
+		last := nodeResults[len(nodeResults)-1]
+		if check.Interval > 0 && clock.Sub(last.TimeStamp) > check.Interval*3 {
+			continue
+		}
+
+		kept = append(kept, nodeResults...)
+
+		history := statesFromHistory(nodeResults)
+		states[node] = reduceHistory(history, &check.Policy, window, previous[node])
+	}
+
+	sort.SliceStable(kept, func(i, j int) bool {
+		return kept[i].TimeStamp.Before(kept[j].TimeStamp)
+	})
+
+	return kept, states
+}
+
+// unreachable returns true if any check that check - or the host it runs on -
+// depends on, is down or unreachable itself.
+func (e *Evaluator) unreachable(check *checks.Check, hostID string) bool {
+	parents := append([]string{}, check.DependsOn...)
+
+	if hostID != "" {
+		var host ssh.SSH
+
+		if e.db.One("ID", hostID, &host) == nil {
+			parents = append(parents, host.DependsOn...)
+		}
+	}
+
+	for _, parentID := range parents {
+		// A check cannot depend on itself.
+		if parentID == check.ID {
+			continue
+		}
+
+		result := &checks.CheckResult{
+			CheckID:     parentID,
+			CheckHostID: checks.CheckHostID(parentID, ""),
+		}
+
+		parent, err := LatestEvaluation(e.db, result)
+		if err != nil {
+			continue
+		}
+
+		if parent.State == StateDown || parent.State == StateCritical || parent.State == StateUnreachable {
+			return true
+		}
+	}
+
+	return false
+}
+
+// evaluateAttempts will evaluate checkResult Nagios-style. A failing check will
+// go to a soft problem state right away, and will only become a hard problem
+// after MaxAttempts consecutive failures. A single success is enough to go up.
+func evaluateAttempts(eval *Evaluation, check *checks.Check, checkResult *checks.CheckResult) (State, bool, int) {
+	state := stateFromResult(checkResult)
+	if state == StateUp {
+		return StateUp, false, 0
+	}
+
+	attempts := eval.Attempts + 1
+
+	return state, attempts < check.MaxAttempts, attempts
+}
+
+// reduceNodes will reduce the states seen from multiple nodes to a single
+// state. If at least quorum nodes sees the check as down, the check is down.
+// Likewise for critical and warning, where nodes seeing a more severe state
+// count as well. If quorum is zero, a simple majority is used. If fewer than
+// quorum nodes report, the state is unknown.
+func reduceNodes(nodes map[string]State, quorum int) State {
+	if len(nodes) == 0 {
+		return StateUnknown
+	}
+
+	if quorum <= 0 {
+		quorum = len(nodes)/2 + 1
+	}
+
+	// Without enough nodes, we can't tell either way.
+	if quorum > len(nodes) {
+		return StateUnknown
+	}
+
+	states := make(map[State]int)
+
+	for _, state := range nodes {
+		states[state]++
+	}
+
+	problems := 0
+	for _, state := range severity {
+		problems += states[state]
+
+		if problems >= quorum {
+			return state
+		}
+	}
+
+	// We could still go either way.
+	if problems+states[StateUnknown] >= quorum {
+		return StateUnknown
+	}
+
+	return StateUp
+}
+
 func (e *Evaluator) evaluteHost(hostEval *Evaluation) (*Evaluation, error) {
 	result := &checks.CheckResult{
 		CheckID:     hostEval.CheckID,
@@ -111,22 +343,56 @@ func (e *Evaluator) evaluteHost(hostEval *Evaluation) (*Evaluation, error) {
 		return nil, err
 	}
 
-	eval.Hosts[hostEval.HostID] = hostEval.State
+	// Soft states are not confirmed yet, and should not affect the check as
+	// a whole.
+	if !hostEval.Soft {
+		eval.Hosts[hostEval.HostID] = hostEval.State
+	}
+
+	hostIDs, err := check.HostIDs(e.db)
+	if err != nil {
+		return nil, err
+	}
+
+	// Hosts in maintenance are left out, they are expected to fail. If all
+	// hosts are in maintenance, so is the check as a whole.
+	var counted []string
+
+	for _, hostID := range hostIDs {
+		if !InMaintenance(e.db, hostEval.End, check.ID, hostID) {
+			counted = append(counted, hostID)
+		}
+	}
+
+	allMaintained := len(hostIDs) > 0 && len(counted) == 0
+	if allMaintained {
+		counted = hostIDs
+	}
 
 	states := make(map[State]int)
 
-	for _, key := range check.Hosts {
+	for _, key := range counted {
 		states[eval.Hosts[key]]++
 	}
 
 	var state State
 
-	switch {
-	case states[StateUnknown] > 0:
-		state = StateUnknown
-	case states[StateDown] > 0:
-		state = StateDown
-	case states[StateUp] == len(check.Hosts):
+	// Problems are ranked like reduceNodes does. Unreachable hosts are
+	// expected to fail, and a host we know nothing about - or a plugin
+	// reporting unknown - should not hide real problems on other hosts.
+	ranking := append(append([]State{}, severity...), StateUnreachable, StateUnknown)
+
+	ranked := false
+	for _, s := range ranking {
+		if states[s] > 0 {
+			state = s
+			ranked = true
+
+			break
+		}
+	}
+
+	if !ranked && states[StateUp] == len(counted) {
 		state = StateUp
 	}
 
@@ -142,5 +408,7 @@ func (e *Evaluator) evaluteHost(hostEval *Evaluation) (*Evaluation, error) {
 		eval = nextEval
 	}
 
+	eval.Maintenance = allMaintained || InMaintenance(e.db, hostEval.End, eval.CheckID, "")
+
 	return eval, eval.Save(e.db)
 }
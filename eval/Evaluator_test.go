@@ -8,7 +8,9 @@ import (
 
 	"github.com/gansoi/gansoi/boltdb"
 	"github.com/gansoi/gansoi/checks"
+	"github.com/gansoi/gansoi/database"
 	"github.com/gansoi/gansoi/plugins"
+	"github.com/gansoi/gansoi/transports/ssh"
 )
 
 type (
@@ -217,3 +219,529 @@ func TestEvaluatorEvaluateHost(t *testing.T) {
 		}
 	}
 }
+
+func TestEvaluatorEvaluateHostUnknown(t *testing.T) {
+	db, e := newE(t)
+	defer db.Close()
+
+	c := &checks.Check{
+		Hosts: []string{"hostid1", "hostid2"},
+	}
+	c.ID = "cid-unknown"
+	db.Save(c)
+
+	clock := time.Now()
+
+	cases := []struct {
+		hostID   string
+		state    State
+		expected State
+	}{
+		{"hostid1", StateUnknown, StateUnknown},
+		{"hostid2", StateUp, StateUnknown},
+		// A plugin reporting unknown on one host should not hide a problem
+		// on another.
+		{"hostid2", StateDown, StateDown},
+		{"hostid2", StateWarning, StateWarning},
+		{"hostid2", StateUp, StateUnknown},
+		{"hostid1", StateUp, StateUp},
+	}
+
+	for i, cc := range cases {
+		hostEval := &Evaluation{
+			CheckID:     c.ID,
+			HostID:      cc.hostID,
+			CheckHostID: checks.CheckHostID(c.ID, cc.hostID),
+			State:       cc.state,
+			Start:       clock,
+			End:         clock,
+		}
+
+		aggregate, err := e.evaluteHost(hostEval)
+		if err != nil {
+			t.Fatalf("evaluteHost() [%d] failed: %s", i, err.Error())
+		}
+
+		if aggregate.State != cc.expected {
+			t.Errorf("evaluteHost() [%d] concluded wrong state. Got %s, expected %s", i, aggregate.State.ColorString(), cc.expected.ColorString())
+		}
+	}
+}
+
+func TestEvaluatorEvaluateHostUnreachable(t *testing.T) {
+	db, e := newE(t)
+	defer db.Close()
+
+	c := &checks.Check{
+		Hosts: []string{"hostid1", "hostid2"},
+	}
+	c.ID = "cid-unreachable"
+	db.Save(c)
+
+	clock := time.Now()
+
+	cases := []struct {
+		hostID   string
+		state    State
+		expected State
+	}{
+		{"hostid1", StateUp, StateUnknown},
+		{"hostid2", StateUnreachable, StateUnreachable},
+		// An unreachable host should not hide a real problem on another.
+		{"hostid1", StateCritical, StateCritical},
+		{"hostid1", StateWarning, StateWarning},
+		{"hostid1", StateDown, StateDown},
+		{"hostid1", StateUnknown, StateUnreachable},
+		{"hostid1", StateUp, StateUnreachable},
+	}
+
+	for i, cc := range cases {
+		hostEval := &Evaluation{
+			CheckID:     c.ID,
+			HostID:      cc.hostID,
+			CheckHostID: checks.CheckHostID(c.ID, cc.hostID),
+			State:       cc.state,
+			Start:       clock,
+			End:         clock,
+		}
+
+		aggregate, err := e.evaluteHost(hostEval)
+		if err != nil {
+			t.Fatalf("evaluteHost() [%d] failed: %s", i, err.Error())
+		}
+
+		if aggregate.State != cc.expected {
+			t.Errorf("evaluteHost() [%d] concluded wrong state. Got %s, expected %s", i, aggregate.State.ColorString(), cc.expected.ColorString())
+		}
+	}
+}
+
+func TestEvaluatorEvaluateHostMaintenance(t *testing.T) {
+	db, e := newE(t)
+	defer db.Close()
+
+	c := &checks.Check{
+		Hosts: []string{"hostid1", "hostid2"},
+	}
+	c.ID = "cid-maintenance"
+	db.Save(c)
+
+	clock := time.Now()
+
+	m := &Maintenance{Name: "m", Start: clock.Add(-time.Hour), End: clock.Add(time.Hour), Hosts: []string{"hostid1"}}
+	db.Save(m)
+
+	hostEval := func(hostID string, state State) *Evaluation {
+		return &Evaluation{
+			CheckID:     c.ID,
+			HostID:      hostID,
+			CheckHostID: checks.CheckHostID(c.ID, hostID),
+			State:       state,
+			Start:       clock,
+			End:         clock,
+		}
+	}
+
+	e.evaluteHost(hostEval("hostid2", StateUp))
+
+	// hostid1 is down, but in maintenance. The check as a whole should
+	// stay quiet.
+	aggregate, err := e.evaluteHost(hostEval("hostid1", StateDown))
+	if err != nil {
+		t.Fatalf("evaluteHost() failed: %s", err.Error())
+	}
+
+	if aggregate.State != StateUp {
+		t.Fatalf("evaluteHost() concluded %s for a host down in maintenance, expected %s", aggregate.State, StateUp)
+	}
+
+	// Problems on other hosts still count.
+	aggregate, _ = e.evaluteHost(hostEval("hostid2", StateDown))
+	if aggregate.State != StateDown || aggregate.Maintenance {
+		t.Fatalf("evaluteHost() concluded %s (maintenance: %v), expected %s", aggregate.State, aggregate.Maintenance, StateDown)
+	}
+
+	// With all hosts in maintenance, the check is in maintenance.
+	m.Hosts = append(m.Hosts, "hostid2")
+	db.Save(m)
+
+	aggregate, _ = e.evaluteHost(hostEval("hostid2", StateDown))
+	if !aggregate.Maintenance {
+		t.Fatalf("evaluteHost() did not mark the check in maintenance when all hosts are")
+	}
+
+	// A window for other checks on the hosts should not count.
+	m.Checks = []string{"other"}
+	db.Save(m)
+
+	aggregate, _ = e.evaluteHost(hostEval("hostid2", StateDown))
+	if aggregate.State != StateDown || aggregate.Maintenance {
+		t.Fatalf("evaluteHost() used a window for another check, concluded %s (maintenance: %v)", aggregate.State, aggregate.Maintenance)
+	}
+
+	// A window for this check on hostid1 only.
+	m.Checks = []string{c.ID}
+	m.Hosts = []string{"hostid1"}
+	db.Save(m)
+
+	e.evaluteHost(hostEval("hostid2", StateUp))
+
+	aggregate, _ = e.evaluteHost(hostEval("hostid1", StateDown))
+	if aggregate.State != StateUp || aggregate.Maintenance {
+		t.Fatalf("evaluteHost() concluded %s (maintenance: %v) for the check on a host in maintenance, expected %s", aggregate.State, aggregate.Maintenance, StateUp)
+	}
+}
+
+func TestEvaluatorPostApply(t *testing.T) {
+	db, e := newE(t)
+	defer db.Close()
+
+	result := &checks.CheckResult{CheckHostID: "postapply::"}
+
+	// Only the leader should evaluate.
+	e.PostApply(false, database.CommandSave, result)
+	_, err := LatestEvaluation(db, result)
+	if err == nil {
+		t.Fatalf("PostApply() evaluated on a non-leader")
+	}
+
+	e.PostApply(true, database.CommandDelete, result)
+	e.PostApply(true, database.CommandSave, &checks.Check{})
+	e.PostApply(true, database.CommandSave, nil)
+
+	e.PostApply(true, database.CommandSave, result)
+	_, err = LatestEvaluation(db, result)
+	if err != nil {
+		t.Fatalf("PostApply() did not evaluate result: %s", err.Error())
+	}
+}
+
+var _ database.Listener = (*Evaluator)(nil)
+
+func TestReduceNodes(t *testing.T) {
+	cases := []struct {
+		nodes    map[string]State
+		quorum   int
+		expected State
+	}{
+		{map[string]State{}, 0, StateUnknown},
+		{map[string]State{"a": StateUp, "b": StateUp, "c": StateDown}, 2, StateUp},
+		{map[string]State{"a": StateUp, "b": StateDown, "c": StateDown}, 2, StateDown},
+		{map[string]State{"a": StateUp, "b": StateUnknown, "c": StateDown}, 2, StateUnknown},
+		{map[string]State{"a": StateUp, "b": StateUp, "c": StateDown}, 1, StateDown},
+		{map[string]State{"a": StateUp, "b": StateDown, "c": StateDown}, 3, StateUp},
+		{map[string]State{"a": StateUp, "b": StateDown, "c": StateDown}, 0, StateDown},
+		{map[string]State{"a": StateUp, "b": StateDown}, 0, StateUp},
+		{map[string]State{"a": StateDown}, 2, StateUnknown},
+		{map[string]State{"a": StateUp}, 2, StateUnknown},
+		{map[string]State{"a": StateUp, "b": StateWarning, "c": StateCritical}, 2, StateWarning},
+		{map[string]State{"a": StateDown, "b": StateCritical, "c": StateCritical}, 0, StateCritical},
+		{map[string]State{"a": StateUp, "b": StateWarning, "c": StateUnknown}, 2, StateUnknown},
+		{map[string]State{"a": StateUp, "b": StateUp, "c": StateWarning}, 2, StateUp},
+	}
+
+	for i, c := range cases {
+		state := reduceNodes(c.nodes, c.quorum)
+		if state != c.expected {
+			t.Errorf("%d: reduceNodes() returned %s, expected %s", i, state, c.expected)
+		}
+	}
+}
+
+func TestEvaluatorEvaluateNodes(t *testing.T) {
+	db, e := newE(t)
+	defer db.Close()
+
+	c := &checks.Check{
+		Nodes:    3,
+		Quorum:   2,
+		Interval: time.Minute,
+	}
+	c.ID = "multi"
+	db.Save(c)
+
+	evaluate := func(node string, err string) *Evaluation {
+		result := &checks.CheckResult{
+			CheckID:     "multi",
+			CheckHostID: checks.CheckHostID("multi", ""),
+			Node:        node,
+			Error:       err,
+			TimeStamp:   time.Now(),
+		}
+
+		evaluation, _ := e.Evaluate(result)
+
+		return evaluation
+	}
+
+	var evaluation *Evaluation
+
+	// Let all nodes see the check as up.
+	for i := 0; i < e.historyLength; i++ {
+		evaluate("a", "")
+		evaluate("b", "")
+		evaluation = evaluate("c", "")
+	}
+
+	if evaluation.State != StateUp {
+		t.Fatalf("Evaluate() concluded %s, expected %s", evaluation.State, StateUp)
+	}
+
+	// A single node seeing the check as down should not change state.
+	for i := 0; i < e.historyLength; i++ {
+		evaluation = evaluate("a", "error")
+	}
+
+	if evaluation.State != StateUp || evaluation.Nodes["a"] != StateDown {
+		t.Fatalf("Evaluate() concluded %s, expected %s", evaluation.State, StateUp)
+	}
+
+	// A second node agreeing should bring the check down.
+	for i := 0; i < e.historyLength; i++ {
+		evaluation = evaluate("b", "error")
+	}
+
+	if evaluation.State != StateDown {
+		t.Fatalf("Evaluate() concluded %s, expected %s", evaluation.State, StateDown)
+	}
+
+	if len(evaluation.Nodes) != 3 {
+		t.Fatalf("Evaluate() did not record state for all nodes: %v", evaluation.Nodes)
+	}
+}
+
+func TestEvaluatorEvaluateAttempts(t *testing.T) {
+	db, e := newE(t)
+	defer db.Close()
+
+	c := &checks.Check{
+		MaxAttempts: 3,
+	}
+	c.ID = "attempts"
+	db.Save(c)
+
+	cases := []struct {
+		err      string
+		severity string
+		state    State
+		soft     bool
+		attempts int
+	}{
+		{"", "", StateUp, false, 0},
+		{"error", "", StateDown, true, 1},
+		{"", "", StateUp, false, 0},
+		{"error", "", StateDown, true, 1},
+		{"error", "", StateDown, true, 2},
+		{"error", "", StateDown, false, 3},
+		{"error", "", StateDown, false, 4},
+		{"", "", StateUp, false, 0},
+		{"error", checks.SeverityWarning, StateWarning, true, 1},
+		{"error", checks.SeverityCritical, StateCritical, true, 2},
+		{"error", checks.SeverityCritical, StateCritical, false, 3},
+		{"error", checks.SeverityUnknown, StateUnknown, false, 4},
+		{"", "", StateUp, false, 0},
+	}
+
+	for i, c := range cases {
+		result := &checks.CheckResult{
+			CheckID:     "attempts",
+			CheckHostID: checks.CheckHostID("attempts", ""),
+			Error:       c.err,
+			Severity:    c.severity,
+		}
+
+		evaluation, _ := e.Evaluate(result)
+		if evaluation.State != c.state || evaluation.Soft != c.soft || evaluation.Attempts != c.attempts {
+			t.Fatalf("%d: Evaluate() concluded %s (soft: %v, attempts: %d), expected %s (soft: %v, attempts: %d)",
+				i, evaluation.State, evaluation.Soft, evaluation.Attempts, c.state, c.soft, c.attempts)
+		}
+	}
+}
+
+func TestEvaluatorEvaluateDependsOn(t *testing.T) {
+	db, e := newE(t)
+	defer db.Close()
+
+	parent := &checks.Check{}
+	parent.ID = "parent"
+	db.Save(parent)
+
+	child := &checks.Check{DependsOn: []string{"parent", "child"}}
+	child.ID = "child"
+	db.Save(child)
+
+	host := &ssh.SSH{DependsOn: []string{"parent"}}
+	host.ID = "host"
+	db.Save(host)
+
+	hostChild := &checks.Check{Hosts: []string{"host"}}
+	hostChild.ID = "hostchild"
+	db.Save(hostChild)
+
+	evaluate := func(checkID string, hostID string, err string) *Evaluation {
+		var evaluation *Evaluation
+
+		for i := 0; i < e.historyLength; i++ {
+			evaluation, _ = e.Evaluate(&checks.CheckResult{
+				CheckID:     checkID,
+				HostID:      hostID,
+				CheckHostID: checks.CheckHostID(checkID, hostID),
+				Error:       err,
+			})
+		}
+
+		return evaluation
+	}
+
+	// With the parent up, the child should be down.
+	evaluate("parent", "", "")
+	if state := evaluate("child", "", "error").State; state != StateDown {
+		t.Fatalf("Child is %s, expected %s", state, StateDown)
+	}
+
+	// With the parent down, the child is unreachable.
+	evaluate("parent", "", "error")
+	if state := evaluate("child", "", "error").State; state != StateUnreachable {
+		t.Fatalf("Child is %s, expected %s", state, StateUnreachable)
+	}
+
+	if state := evaluate("hostchild", "host", "error").State; state != StateUnreachable {
+		t.Fatalf("Host child is %s, expected %s", state, StateUnreachable)
+	}
+
+	// An unreachable child is still up when it's up.
+	if state := evaluate("child", "", "").State; state != StateUp {
+		t.Fatalf("Child is %s, expected %s", state, StateUp)
+	}
+}
+
+func TestEvaluatorEvaluateMaintenance(t *testing.T) {
+	db, e := newE(t)
+	defer db.Close()
+
+	result := &checks.CheckResult{
+		CheckID:     "maintained",
+		CheckHostID: checks.CheckHostID("maintained", ""),
+	}
+
+	evaluation, _ := e.Evaluate(result)
+	if evaluation.Maintenance {
+		t.Fatalf("Evaluation marked as in maintenance without a window")
+	}
+
+	m := &Maintenance{Name: "m", Start: time.Now().Add(-time.Hour), End: time.Now().Add(time.Hour), Checks: []string{"maintained"}}
+	db.Save(m)
+
+	evaluation, _ = e.Evaluate(result)
+	if !evaluation.Maintenance {
+		t.Fatalf("Evaluation not marked as in maintenance")
+	}
+}
+
+func TestEvaluatorEvaluateFlapping(t *testing.T) {
+	db, e := newE(t)
+	defer db.Close()
+
+	c := &checks.Check{
+		MaxAttempts:   1,
+		FlapDetection: checks.FlapDetection{Window: 4, High: 60, Low: 30},
+	}
+	c.ID = "flapping"
+	db.Save(c)
+
+	cases := []struct {
+		err      string
+		flapping bool
+	}{
+		{"", false},
+		{"error", false},
+		{"", false},
+		{"error", true},
+		{"", true},
+		{"", true},
+		{"", true},
+		{"", false},
+	}
+
+	for i, c := range cases {
+		result := &checks.CheckResult{
+			CheckID:     "flapping",
+			CheckHostID: checks.CheckHostID("flapping", ""),
+			Error:       c.err,
+		}
+
+		evaluation, _ := e.Evaluate(result)
+		if evaluation.Flapping != c.flapping {
+			t.Fatalf("%d: Evaluate() concluded flapping: %v (%.0f%%), expected %v", i, evaluation.Flapping, evaluation.FlapScore, c.flapping)
+		}
+	}
+}
+
+func TestEvaluatorEvaluatePolicy(t *testing.T) {
+	db, e := newE(t)
+	defer db.Close()
+
+	c := &checks.Check{
+		Policy: checks.Policy{Window: 2, Mode: checks.PolicyConsecutive},
+	}
+	c.ID = "policy"
+	db.Save(c)
+
+	cases := []struct {
+		err   string
+		state State
+	}{
+		{"", StateUnknown},
+		{"", StateUp},
+		{"error", StateUp},
+		{"", StateUp},
+		{"error", StateUp},
+		{"error", StateDown},
+		{"", StateDown},
+		{"", StateUp},
+	}
+
+	for i, c := range cases {
+		result := &checks.CheckResult{
+			CheckID:     "policy",
+			CheckHostID: checks.CheckHostID("policy", ""),
+			Error:       c.err,
+		}
+
+		evaluation, _ := e.Evaluate(result)
+		if evaluation.State != c.state {
+			t.Fatalf("%d: Evaluate() concluded %s, expected %s", i, evaluation.State, c.state)
+		}
+
+		if len(evaluation.Results) > 2 {
+			t.Fatalf("%d: Evaluate() kept %d results, expected at most 2", i, len(evaluation.Results))
+		}
+	}
+}
+
+func TestEvaluatorEvaluatePassive(t *testing.T) {
+	db, e := newE(t)
+	defer db.Close()
+
+	c := &checks.Check{AgentID: checks.PassiveAgent}
+	c.ID = "passive"
+	db.Save(c)
+
+	for i, err := range []string{"", "no result pushed", ""} {
+		result := &checks.CheckResult{
+			CheckID:     "passive",
+			CheckHostID: checks.CheckHostID("passive", ""),
+			Error:       err,
+		}
+
+		expected := StateUp
+		if err != "" {
+			expected = StateDown
+		}
+
+		evaluation, _ := e.Evaluate(result)
+		if evaluation.State != expected {
+			t.Fatalf("%d: Evaluate() concluded %s, expected %s", i, evaluation.State, expected)
+		}
+	}
+}
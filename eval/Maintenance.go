@@ -0,0 +1,119 @@
+package eval
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+
+	"github.com/gansoi/gansoi/database"
+)
+
+type (
+	// Maintenance is a scheduled maintenance window. While a window is
+	// active, evaluations of targeted checks and hosts will be marked as in
+	// maintenance, and no notifications will be sent. If only Checks is set,
+	// the checks are targeted on all hosts. If only Hosts is set, all checks
+	// on the hosts are targeted. If both are set, only the checks on the
+	// hosts are targeted.
+	Maintenance struct {
+		database.Object `storm:"inline"`
+		Name            string        `json:"name" validate:"required"`
+		Start           time.Time     `json:"start" validate:"required"`
+		End             time.Time     `json:"end" validate:"required"`
+		Repeat          time.Duration `json:"repeat" validate:"min=0"`
+		Checks          []string      `json:"checks"`
+		Hosts           []string      `json:"hosts"`
+	}
+)
+
+func init() {
+	database.RegisterType(Maintenance{})
+}
+
+// Validate implements database.Validator.
+func (m *Maintenance) Validate(db database.Reader) error {
+	v := validator.New()
+
+	err := v.Struct(m)
+	if err != nil {
+		return err
+	}
+
+	if !m.End.After(m.Start) {
+		return errors.New("end must be after start")
+	}
+
+	if m.Repeat > 0 && m.Repeat < m.End.Sub(m.Start) {
+		return errors.New("repeat cannot be shorter than the window")
+	}
+
+	if len(m.Checks) == 0 && len(m.Hosts) == 0 {
+		return errors.New("no checks or hosts targeted")
+	}
+
+	return nil
+}
+
+// Active returns true if the window is active at clock. If Repeat is set, the
+// window will repeat every Repeat starting from Start.
+func (m *Maintenance) Active(clock time.Time) bool {
+	if clock.Before(m.Start) {
+		return false
+	}
+
+	if m.Repeat <= 0 {
+		return clock.Before(m.End)
+	}
+
+	offset := clock.Sub(m.Start) % m.Repeat
+
+	return offset < m.End.Sub(m.Start)
+}
+
+// Covers returns true if the window targets checkID on hostID. An empty
+// hostID is the check as a whole, it's only covered by windows not limited to
+// hosts.
+func (m *Maintenance) Covers(checkID string, hostID string) bool {
+	checkCovered := contains(m.Checks, checkID)
+	hostCovered := hostID != "" && contains(m.Hosts, hostID)
+
+	switch {
+	case len(m.Checks) > 0 && len(m.Hosts) > 0:
+		return checkCovered && hostCovered
+	case len(m.Checks) > 0:
+		return checkCovered
+	default:
+		return hostCovered
+	}
+}
+
+// contains returns true if ids contains id.
+func contains(ids []string, id string) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// InMaintenance returns true if any active maintenance window in db covers
+// checkID on hostID at clock.
+func InMaintenance(db database.Reader, clock time.Time, checkID string, hostID string) bool {
+	var windows []Maintenance
+
+	err := db.All(&windows, -1, 0, false)
+	if err != nil {
+		return false
+	}
+
+	for _, m := range windows {
+		if m.Active(clock) && m.Covers(checkID, hostID) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,123 @@
+package eval
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gansoi/gansoi/boltdb"
+)
+
+func TestMaintenanceValidate(t *testing.T) {
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		m   Maintenance
+		err bool
+	}{
+		{Maintenance{}, true},
+		{Maintenance{Name: "a", Start: start, End: start.Add(time.Hour), Checks: []string{"c"}}, false},
+		{Maintenance{Name: "a", Start: start, End: start.Add(time.Hour), Hosts: []string{"h"}}, false},
+		{Maintenance{Name: "a", Start: start, End: start.Add(time.Hour)}, true},
+		{Maintenance{Name: "a", Start: start, End: start, Checks: []string{"c"}}, true},
+		{Maintenance{Name: "a", Start: start, End: start.Add(time.Hour), Repeat: time.Minute, Checks: []string{"c"}}, true},
+		{Maintenance{Name: "a", Start: start, End: start.Add(time.Hour), Repeat: 24 * time.Hour, Checks: []string{"c"}}, false},
+	}
+
+	for i, c := range cases {
+		err := c.m.Validate(nil)
+		if (err != nil) != c.err {
+			t.Errorf("%d: Validate() returned %v", i, err)
+		}
+	}
+}
+
+func TestMaintenanceActive(t *testing.T) {
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	once := Maintenance{Start: start, End: start.Add(time.Hour)}
+	daily := Maintenance{Start: start, End: start.Add(time.Hour), Repeat: 24 * time.Hour}
+
+	cases := []struct {
+		m      Maintenance
+		clock  time.Time
+		active bool
+	}{
+		{once, start.Add(-time.Second), false},
+		{once, start, true},
+		{once, start.Add(59 * time.Minute), true},
+		{once, start.Add(time.Hour), false},
+		{once, start.Add(24 * time.Hour), false},
+		{daily, start.Add(-time.Second), false},
+		{daily, start.Add(30 * time.Minute), true},
+		{daily, start.Add(2 * time.Hour), false},
+		{daily, start.Add(24*time.Hour + 30*time.Minute), true},
+		{daily, start.Add(48*time.Hour + time.Hour), false},
+	}
+
+	for i, c := range cases {
+		if c.m.Active(c.clock) != c.active {
+			t.Errorf("%d: Active(%s) returned %v", i, c.clock, !c.active)
+		}
+	}
+}
+
+func TestMaintenanceCovers(t *testing.T) {
+	checksOnly := Maintenance{Checks: []string{"check"}}
+	hostsOnly := Maintenance{Hosts: []string{"host"}}
+	both := Maintenance{Checks: []string{"check"}, Hosts: []string{"host"}}
+
+	cases := []struct {
+		m       Maintenance
+		checkID string
+		hostID  string
+		covers  bool
+	}{
+		{checksOnly, "check", "", true},
+		{checksOnly, "check", "host", true},
+		{checksOnly, "other", "host", false},
+		{hostsOnly, "check", "host", true},
+		{hostsOnly, "other", "host", true},
+		{hostsOnly, "check", "", false},
+		{hostsOnly, "check", "other", false},
+
+		// Both set means the checks on the hosts.
+		{both, "check", "host", true},
+		{both, "check", "", false},
+		{both, "check", "other", false},
+		{both, "other", "host", false},
+		{both, "other", "", false},
+		{both, "other", "other", false},
+	}
+
+	for i, c := range cases {
+		if c.m.Covers(c.checkID, c.hostID) != c.covers {
+			t.Errorf("%d: Covers(%s, %s) returned %v", i, c.checkID, c.hostID, !c.covers)
+		}
+	}
+}
+
+func TestInMaintenance(t *testing.T) {
+	db := boltdb.NewTestStore()
+	defer db.Close()
+
+	clock := time.Now()
+
+	if InMaintenance(db, clock, "check", "") {
+		t.Fatalf("InMaintenance() returned true without windows")
+	}
+
+	m := &Maintenance{Name: "m", Start: clock.Add(-time.Hour), End: clock.Add(time.Hour), Checks: []string{"check"}}
+	db.Save(m)
+
+	if !InMaintenance(db, clock, "check", "") {
+		t.Fatalf("InMaintenance() failed to find active window")
+	}
+
+	if InMaintenance(db, clock.Add(2*time.Hour), "check", "") {
+		t.Fatalf("InMaintenance() returned true after window ended")
+	}
+
+	if InMaintenance(db, clock, "other", "") {
+		t.Fatalf("InMaintenance() returned true for untargeted check")
+	}
+}
@@ -21,6 +21,16 @@ const (
 	// StateDown is a Check with failed conditions.
 	StateDown State = iota
 
+	// StateUnreachable is a Check with failed conditions, while a Check it
+	// depends on is also failing.
+	StateUnreachable State = iota
+
+	// StateWarning is a Check where one or more warning expressions failed.
+	StateWarning State = iota
+
+	// StateCritical is a Check where one or more critical expressions failed.
+	StateCritical State = iota
+
 	// stateMax can be used like 'if state >= stateMax' to check for a valid
 	// state.
 	stateMax State = iota
@@ -28,42 +38,63 @@ const (
 
 var (
 	stateToText = map[State]string{
-		StateUnknown: "unknown",
-		StateUp:      "up",
-		StateDown:    "down",
+		StateUnknown:     "unknown",
+		StateUp:          "up",
+		StateDown:        "down",
+		StateUnreachable: "unreachable",
+		StateWarning:     "warning",
+		StateCritical:    "critical",
 	}
 
 	textToState = map[string]State{
-		"":        StateUnknown,
-		"unknown": StateUnknown,
-		"up":      StateUp,
-		"down":    StateDown,
+		"":            StateUnknown,
+		"unknown":     StateUnknown,
+		"up":          StateUp,
+		"down":        StateDown,
+		"unreachable": StateUnreachable,
+		"warning":     StateWarning,
+		"critical":    StateCritical,
 	}
 
 	stateToJSON = map[State]string{
-		StateUnknown: `""`,
-		StateUp:      `"up"`,
-		StateDown:    `"down"`,
+		StateUnknown:     `""`,
+		StateUp:          `"up"`,
+		StateDown:        `"down"`,
+		StateUnreachable: `"unreachable"`,
+		StateWarning:     `"warning"`,
+		StateCritical:    `"critical"`,
 	}
 
 	jsonToState = map[string]State{
-		`""`:        StateUnknown,
-		`"unknown"`: StateUnknown,
-		`"up"`:      StateUp,
-		`"down"`:    StateDown,
+		`""`:            StateUnknown,
+		`"unknown"`:     StateUnknown,
+		`"up"`:          StateUp,
+		`"down"`:        StateDown,
+		`"unreachable"`: StateUnreachable,
+		`"warning"`:     StateWarning,
+		`"critical"`:    StateCritical,
 	}
 
 	stateToHuman = map[State]string{
-		StateUnknown: "Unknown",
-		StateUp:      "Up",
-		StateDown:    "Down",
+		StateUnknown:     "Unknown",
+		StateUp:          "Up",
+		StateDown:        "Down",
+		StateUnreachable: "Unreachable",
+		StateWarning:     "Warning",
+		StateCritical:    "Critical",
 	}
 
 	stateToColor = map[State]string{
-		StateUnknown: logger.Blue,
-		StateUp:      logger.Green,
-		StateDown:    logger.Red,
+		StateUnknown:     logger.Blue,
+		StateUp:          logger.Green,
+		StateDown:        logger.Red,
+		StateUnreachable: logger.Purple,
+		StateWarning:     logger.Yellow,
+		StateCritical:    logger.Red,
 	}
+
+	// severity lists the problem states, most severe first.
+	severity = []State{StateDown, StateCritical, StateWarning}
 )
 
 // String implements GoStringer.
@@ -82,6 +113,11 @@ func (s State) Valid() bool {
 	return s < stateMax
 }
 
+// Problem returns true if s is a state worth telling someone about.
+func (s State) Problem() bool {
+	return s == StateDown || s == StateCritical || s == StateWarning
+}
+
 // MarshalJSON implements json.Marshaler.
 func (s State) MarshalJSON() ([]byte, error) {
 	name, found := stateToJSON[s]
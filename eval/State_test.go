@@ -15,6 +15,9 @@ func TestValid(t *testing.T) {
 		{StateUnknown, true},
 		{StateUp, true},
 		{StateDown, true},
+		{StateUnreachable, true},
+		{StateWarning, true},
+		{StateCritical, true},
 		{State(39), false},
 	}
 
@@ -35,6 +38,9 @@ func TestMarshalJSON(t *testing.T) {
 		{StateUnknown, `""`},
 		{StateUp, `"up"`},
 		{StateDown, `"down"`},
+		{StateUnreachable, `"unreachable"`},
+		{StateWarning, `"warning"`},
+		{StateCritical, `"critical"`},
 		{State(39), `""`},
 	}
 
@@ -58,6 +64,9 @@ func TestColorString(t *testing.T) {
 		{StateUnknown, logger.Blue + "Unknown" + logger.Reset},
 		{StateUp, logger.Green + "Up" + logger.Reset},
 		{StateDown, logger.Red + "Down" + logger.Reset},
+		{StateUnreachable, logger.Purple + "Unreachable" + logger.Reset},
+		{StateWarning, logger.Yellow + "Warning" + logger.Reset},
+		{StateCritical, logger.Red + "Critical" + logger.Reset},
 		{State(39), "" + logger.Reset},
 	}
 
@@ -74,7 +83,9 @@ func TestUnmarshalJSON(t *testing.T) {
 	j := `{
         "s1": "up",
         "s2": "down",
-        "s3": ""
+        "s3": "",
+        "s4": "warning",
+        "s5": "critical"
         }`
 
 	out := make(map[string]State)
@@ -84,7 +95,7 @@ func TestUnmarshalJSON(t *testing.T) {
 		t.Fatalf("JSON unmarshal failed: %s", err.Error())
 	}
 
-	if out["s1"] != StateUp || out["s2"] != StateDown || out["s3"] != StateUnknown {
+	if out["s1"] != StateUp || out["s2"] != StateDown || out["s3"] != StateUnknown || out["s4"] != StateWarning || out["s5"] != StateCritical {
 		t.Fatalf("Failed to decode JSON properly")
 	}
 
@@ -126,6 +137,9 @@ func TestMarshalText(t *testing.T) {
 		{StateUnknown, "unknown"},
 		{StateUp, "up"},
 		{StateDown, "down"},
+		{StateUnreachable, "unreachable"},
+		{StateWarning, "warning"},
+		{StateCritical, "critical"},
 		{State(39), "unknown"},
 	}
 
@@ -149,6 +163,9 @@ func TestUnmarshalText(t *testing.T) {
 		{[]byte("up"), StateUp},
 		{[]byte("unknown"), StateUnknown},
 		{[]byte("down"), StateDown},
+		{[]byte("unreachable"), StateUnreachable},
+		{[]byte("warning"), StateWarning},
+		{[]byte("critical"), StateCritical},
 	}
 
 	var s State
@@ -164,3 +181,23 @@ func TestUnmarshalText(t *testing.T) {
 		t.Fatalf("Failed to catch invalid input")
 	}
 }
+
+func TestProblem(t *testing.T) {
+	cases := []struct {
+		input    State
+		expected bool
+	}{
+		{StateUnknown, false},
+		{StateUp, false},
+		{StateDown, true},
+		{StateUnreachable, false},
+		{StateWarning, true},
+		{StateCritical, true},
+	}
+
+	for _, c := range cases {
+		if c.input.Problem() != c.expected {
+			t.Errorf("Problem() returned %v for %s, expected %v", c.input.Problem(), c.input, c.expected)
+		}
+	}
+}
@@ -54,7 +54,10 @@ func (s *States) Last(n int) *States {
 // 2) If any state is StateUnknown, return StateUnknown.
 // 3) If no state is StateUnknown, and there is a majority that majority is
 //    returned.
-// 4) If none of 1-3 is satisfied, StateUnknown will we returned.
+// 4) If a majority of states are problems, the most severe state the
+//    majority is at least as bad as is returned. Warning, Critical, Down
+//    will reduce to Critical.
+// 5) If none of 1-4 is satisfied, StateUnknown will we returned.
 func (s *States) Reduce() State {
 	l := len(*s)
 
@@ -74,9 +77,74 @@ func (s *States) Reduce() State {
 		}
 	}
 
+	count := 0
+	for _, state := range severity {
+		count += hist[state]
+
+		if count > l/2 {
+			return state
+		}
+	}
+
 	return StateUnknown
 }
 
+// ReduceConsecutive reduces the slice of states to a single state if all
+// states agree. If all states are problems of different severity, the least
+// severe is returned. If the states disagree, current is returned.
+func (s *States) ReduceConsecutive(current State) State {
+	if len(*s) == 0 {
+		return current
+	}
+
+	hist := s.Histogram()
+
+	if len(hist) == 1 {
+		return (*s)[0]
+	}
+
+	count := 0
+	for _, state := range severity {
+		count += hist[state]
+
+		if count == len(*s) {
+			return state
+		}
+	}
+
+	return current
+}
+
+// ReducePercentage reduces the slice of states to a problem state if at
+// least threshold percent of the states are problems. The most severe state
+// at least threshold percent of the states are as bad as is returned. If any
+// state is StateUnknown, StateUnknown is returned. Otherwise StateUp is
+// returned.
+func (s *States) ReducePercentage(threshold float64) State {
+	l := len(*s)
+
+	if l == 0 {
+		return StateUnknown
+	}
+
+	hist := s.Histogram()
+
+	if hist[StateUnknown] > 0 {
+		return StateUnknown
+	}
+
+	count := 0
+	for _, state := range severity {
+		count += hist[state]
+
+		if float64(count)*100.0/float64(l) >= threshold {
+			return state
+		}
+	}
+
+	return StateUp
+}
+
 // ColorString will return a nicely colored array.
 func (s *States) ColorString() string {
 	ret := "\033[0m["
@@ -103,6 +103,10 @@ func TestReduce(t *testing.T) {
 		{States{StateUnknown, StateDown, StateUp}, StateUnknown},
 		{States{State(34)}, State(34)},
 		{States{StateUp, StateUp, StateUp, State(35)}, StateUp},
+		{States{StateWarning, StateCritical, StateCritical}, StateCritical},
+		{States{StateWarning, StateCritical, StateDown}, StateCritical},
+		{States{StateWarning, StateCritical, StateUp, StateWarning, StateUp}, StateWarning},
+		{States{StateWarning, StateCritical, StateUp, StateUp}, StateUnknown},
 	}
 
 	for _, dat := range cases {
@@ -114,6 +118,58 @@ func TestReduce(t *testing.T) {
 	}
 }
 
+func TestReduceConsecutive(t *testing.T) {
+	cases := []struct {
+		input    States
+		current  State
+		expected State
+	}{
+		{States{}, StateUp, StateUp},
+		{States{StateDown, StateDown}, StateUp, StateDown},
+		{States{StateUp, StateDown}, StateUp, StateUp},
+		{States{StateUp, StateDown}, StateDown, StateDown},
+		{States{StateUp, StateUp}, StateDown, StateUp},
+		{States{StateWarning, StateCritical, StateDown}, StateUp, StateWarning},
+		{States{StateCritical, StateDown}, StateUp, StateCritical},
+		{States{StateUnknown, StateDown}, StateUp, StateUp},
+	}
+
+	for i, c := range cases {
+		result := c.input.ReduceConsecutive(c.current)
+
+		if result != c.expected {
+			t.Errorf("%d: ReduceConsecutive() returned %s, expected %s", i, result, c.expected)
+		}
+	}
+}
+
+func TestReducePercentage(t *testing.T) {
+	eightOfTen := States{StateDown, StateDown, StateDown, StateUp, StateDown, StateDown, StateUp, StateDown, StateDown, StateDown}
+	sevenOfTen := States{StateDown, StateDown, StateUp, StateUp, StateDown, StateDown, StateUp, StateDown, StateDown, StateDown}
+
+	cases := []struct {
+		input     States
+		threshold float64
+		expected  State
+	}{
+		{States{}, 50, StateUnknown},
+		{eightOfTen, 80, StateDown},
+		{sevenOfTen, 80, StateUp},
+		{States{StateUp, StateUnknown}, 50, StateUnknown},
+		{States{StateUp, StateWarning, StateCritical, StateUp}, 50, StateWarning},
+		{States{StateUp, StateCritical, StateCritical, StateUp}, 50, StateCritical},
+		{States{StateUp, StateUp, StateUp, StateWarning}, 50, StateUp},
+	}
+
+	for i, c := range cases {
+		result := c.input.ReducePercentage(c.threshold)
+
+		if result != c.expected {
+			t.Errorf("%d: ReducePercentage() returned %s, expected %s", i, result, c.expected)
+		}
+	}
+}
+
 func TestStatesJSON(t *testing.T) {
 	cases := []struct {
 		input    States
@@ -91,6 +91,9 @@ func (s *Summary) summarize() {
 	s.States[StateUnknown] = 0
 	s.States[StateUp] = 0
 	s.States[StateDown] = 0
+	s.States[StateUnreachable] = 0
+	s.States[StateWarning] = 0
+	s.States[StateCritical] = 0
 
 	for _, state := range s.checks {
 		s.Checks++
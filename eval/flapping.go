@@ -0,0 +1,45 @@
+package eval
+
+import (
+	"github.com/gansoi/gansoi/checks"
+)
+
+// flapScore returns the percentage of states in s differing from the state
+// before.
+func flapScore(s States) float64 {
+	if len(s) < 2 {
+		return 0.0
+	}
+
+	changes := 0
+	for i := 1; i < len(s); i++ {
+		if s[i] != s[i-1] {
+			changes++
+		}
+	}
+
+	return float64(changes) * 100.0 / float64(len(s)-1)
+}
+
+// detectFlapping returns true if a check with the recent states should be
+// considered flapping. flapping is the current flapping status, used to apply
+// hysteresis. The score is returned as well.
+func detectFlapping(recent States, flapping bool, f *checks.FlapDetection) (bool, float64) {
+	if f.Window <= 0 {
+		return false, 0.0
+	}
+
+	score := flapScore(recent)
+	high, low := f.Thresholds()
+
+	// We don't trust the score until the window is filled.
+	if len(recent) < f.Window {
+		return flapping && score >= low, score
+	}
+
+	if flapping {
+		return score >= low, score
+	}
+
+	return score >= high, score
+}
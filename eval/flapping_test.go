@@ -0,0 +1,54 @@
+package eval
+
+import (
+	"testing"
+
+	"github.com/gansoi/gansoi/checks"
+)
+
+func TestFlapScore(t *testing.T) {
+	cases := []struct {
+		in       States
+		expected float64
+	}{
+		{States{}, 0.0},
+		{States{StateUp}, 0.0},
+		{States{StateUp, StateUp, StateUp}, 0.0},
+		{States{StateUp, StateDown, StateUp}, 100.0},
+		{States{StateUp, StateDown, StateDown, StateDown, StateDown}, 25.0},
+	}
+
+	for i, c := range cases {
+		score := flapScore(c.in)
+		if score != c.expected {
+			t.Errorf("%d: flapScore() returned %f, expected %f", i, score, c.expected)
+		}
+	}
+}
+
+func TestDetectFlapping(t *testing.T) {
+	f := &checks.FlapDetection{Window: 5, High: 50, Low: 25}
+
+	cases := []struct {
+		recent   States
+		flapping bool
+		f        *checks.FlapDetection
+		expected bool
+	}{
+		{States{StateUp, StateDown, StateUp, StateDown, StateUp}, false, &checks.FlapDetection{}, false},
+		{States{StateUp, StateDown, StateUp}, false, f, false},
+		{States{StateUp, StateDown, StateUp}, true, f, true},
+		{States{StateUp, StateDown, StateUp, StateDown, StateUp}, false, f, true},
+		{States{StateUp, StateDown, StateDown, StateDown, StateUp}, false, f, true},
+		{States{StateUp, StateDown, StateDown, StateDown, StateDown}, false, f, false},
+		{States{StateUp, StateDown, StateDown, StateDown, StateDown}, true, f, true},
+		{States{StateUp, StateUp, StateUp, StateUp, StateUp}, true, f, false},
+	}
+
+	for i, c := range cases {
+		flapping, _ := detectFlapping(c.recent, c.flapping, c.f)
+		if flapping != c.expected {
+			t.Errorf("%d: detectFlapping() returned %v, expected %v", i, flapping, c.expected)
+		}
+	}
+}
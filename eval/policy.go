@@ -0,0 +1,34 @@
+package eval
+
+import (
+	"github.com/gansoi/gansoi/checks"
+)
+
+// reduceHistory will reduce history, oldest first, to a single state
+// according to policy. Only the latest window states are considered. current
+// is the current state, it will be kept if the policy can't decide.
+func reduceHistory(history States, policy *checks.Policy, window int, current State) State {
+	if len(history) > window {
+		history = history[len(history)-window:]
+	}
+
+	// We need a full window to decide anything.
+	if len(history) < window {
+		if policy.Mode == checks.PolicyConsecutive {
+			return current
+		}
+
+		return StateUnknown
+	}
+
+	switch policy.Mode {
+	case checks.PolicyConsecutive:
+		return history.ReduceConsecutive(current)
+
+	case checks.PolicyPercentage:
+		return history.ReducePercentage(policy.ThresholdPercent())
+
+	default:
+		return history.Reduce()
+	}
+}
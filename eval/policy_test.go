@@ -0,0 +1,38 @@
+package eval
+
+import (
+	"testing"
+
+	"github.com/gansoi/gansoi/checks"
+)
+
+func TestReduceHistory(t *testing.T) {
+	majority := &checks.Policy{}
+	consecutive := &checks.Policy{Mode: checks.PolicyConsecutive}
+	percentage := &checks.Policy{Mode: checks.PolicyPercentage, Threshold: 60}
+
+	cases := []struct {
+		history  States
+		policy   *checks.Policy
+		window   int
+		current  State
+		expected State
+	}{
+		{States{StateDown, StateDown}, majority, 3, StateUp, StateUnknown},
+		{States{StateUp, StateDown, StateDown}, majority, 3, StateUp, StateDown},
+		{States{StateDown, StateDown, StateDown, StateUp, StateUp}, majority, 3, StateDown, StateUp},
+		{States{StateDown}, consecutive, 2, StateUp, StateUp},
+		{States{StateUp, StateDown, StateDown}, consecutive, 2, StateUp, StateDown},
+		{States{StateDown, StateDown, StateUp}, consecutive, 2, StateDown, StateDown},
+		{States{StateDown, StateDown, StateUp}, percentage, 5, StateUp, StateUnknown},
+		{States{StateDown, StateDown, StateUp, StateDown, StateUp}, percentage, 5, StateUp, StateDown},
+		{States{StateDown, StateDown, StateUp, StateUp, StateUp}, percentage, 5, StateDown, StateUp},
+	}
+
+	for i, c := range cases {
+		state := reduceHistory(c.history, c.policy, c.window, c.current)
+		if state != c.expected {
+			t.Errorf("%d: reduceHistory() returned %s, expected %s", i, state, c.expected)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package exporter
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/gansoi/gansoi/checks"
+	"github.com/gansoi/gansoi/database"
+	"github.com/gansoi/gansoi/eval"
+	"github.com/gansoi/gansoi/transports/ssh"
+)
+
+type (
+	// Exporter keeps the latest evaluations and check results for exposing
+	// in the Prometheus text format. Evaluations are replicated to all
+	// nodes, but like the evaluator, only the leader sees results from all
+	// nodes.
+	Exporter struct {
+		sync.RWMutex
+		db          database.Reader
+		leader      bool
+		evaluations map[string]*eval.Evaluation
+		results     map[resultKey]*checks.CheckResult
+	}
+
+	// resultKey identifies the latest result from a single node.
+	resultKey struct {
+		checkHostID string
+		node        string
+	}
+)
+
+// NewExporter will instantiate a new Exporter. db is used for resolving the
+// hosts of checks.
+func NewExporter(db database.Reader) *Exporter {
+	return &Exporter{
+		db:          db,
+		evaluations: make(map[string]*eval.Evaluation),
+		results:     make(map[resultKey]*checks.CheckResult),
+	}
+}
+
+// Load will read the latest evaluation of all checks from the database. This
+// should be called at startup, evaluations are only saved when checks are
+// run.
+func (e *Exporter) Load() error {
+	var all []checks.Check
+
+	err := e.db.All(&all, -1, 0, false)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		return err
+	}
+
+	for i := range all {
+		check := &all[i]
+
+		hostIDs, _ := check.HostIDs(e.db)
+
+		// The final evaluation uses an empty host ID.
+		hostIDs = append(hostIDs, "")
+
+		for _, hostID := range hostIDs {
+			evaluation, err := eval.LatestEvaluation(e.db, &checks.CheckResult{
+				CheckHostID: checks.CheckHostID(check.ID, hostID),
+			})
+			if err == nil {
+				e.addEvaluation(evaluation)
+			}
+		}
+	}
+
+	return nil
+}
+
+// PostApply implements database.Listener.
+func (e *Exporter) PostApply(leader bool, command database.Command, data interface{}) {
+	e.Lock()
+	e.leader = leader
+	e.Unlock()
+
+	switch v := data.(type) {
+	case *eval.Evaluation:
+		if command == database.CommandSave {
+			e.addEvaluation(v)
+		}
+
+	case *checks.CheckResult:
+		if command == database.CommandSave || command == database.CommandForward {
+			e.addResult(v)
+		}
+
+	case *checks.Check:
+		switch command {
+		case database.CommandSave:
+			e.updateCheck(v)
+		case database.CommandDelete:
+			e.removeCheck(v.ID)
+		}
+
+	case *ssh.SSH:
+		if command == database.CommandDelete {
+			e.removeHost(v.ID)
+		}
+	}
+}
+
+func (e *Exporter) addEvaluation(evaluation *eval.Evaluation) {
+	e.Lock()
+	defer e.Unlock()
+
+	e.evaluations[evaluation.CheckHostID] = evaluation
+}
+
+func (e *Exporter) addResult(result *checks.CheckResult) {
+	checkHostID := result.CheckHostID
+	if checkHostID == "" {
+		checkHostID = checks.CheckHostID(result.CheckID, result.HostID)
+	}
+
+	key := resultKey{checkHostID: checkHostID, node: result.Node}
+
+	e.Lock()
+	defer e.Unlock()
+
+	latest, found := e.results[key]
+	if found && latest.TimeStamp.After(result.TimeStamp) {
+		return
+	}
+
+	e.results[key] = result
+}
+
+// updateCheck will forget the results of check, the agent or its arguments
+// could have changed. Evaluations are kept for hosts still covered by check.
+func (e *Exporter) updateCheck(check *checks.Check) {
+	hostIDs, err := check.HostIDs(e.db)
+	if err != nil {
+		return
+	}
+
+	// The final evaluation uses an empty host ID.
+	keep := map[string]bool{"": true}
+	for _, hostID := range hostIDs {
+		keep[hostID] = true
+	}
+
+	e.Lock()
+	defer e.Unlock()
+
+	for checkHostID, evaluation := range e.evaluations {
+		if evaluation.CheckID == check.ID && !keep[evaluation.HostID] {
+			delete(e.evaluations, checkHostID)
+		}
+	}
+
+	for key, result := range e.results {
+		if result.CheckID == check.ID {
+			delete(e.results, key)
+		}
+	}
+}
+
+// removeHost will forget everything about the host identified by hostID.
+func (e *Exporter) removeHost(hostID string) {
+	e.Lock()
+	defer e.Unlock()
+
+	for checkHostID, evaluation := range e.evaluations {
+		if evaluation.HostID == hostID {
+			delete(e.evaluations, checkHostID)
+		}
+	}
+
+	for key, result := range e.results {
+		if result.HostID == hostID {
+			delete(e.results, key)
+		}
+	}
+}
+
+// removeCheck will forget everything about the check identified by checkID.
+func (e *Exporter) removeCheck(checkID string) {
+	e.Lock()
+	defer e.Unlock()
+
+	for checkHostID, evaluation := range e.evaluations {
+		if evaluation.CheckID == checkID {
+			delete(e.evaluations, checkHostID)
+		}
+	}
+
+	for key, result := range e.results {
+		if result.CheckID == checkID {
+			delete(e.results, key)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package exporter
+
+import (
+	"bytes"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/gansoi/gansoi/boltdb"
+	"github.com/gansoi/gansoi/checks"
+	"github.com/gansoi/gansoi/database"
+	"github.com/gansoi/gansoi/eval"
+	"github.com/gansoi/gansoi/plugins"
+	"github.com/gansoi/gansoi/transports/ssh"
+)
+
+// Make sure we implement the needed interfaces.
+var _ database.Listener = (*Exporter)(nil)
+
+var (
+	testCounter = expvar.NewInt("exporter_test_counter")
+)
+
+func evaluation(checkID string, hostID string, state eval.State) *eval.Evaluation {
+	return &eval.Evaluation{
+		CheckHostID: checks.CheckHostID(checkID, hostID),
+		CheckID:     checkID,
+		HostID:      hostID,
+		State:       state,
+	}
+}
+
+func result(clock time.Time, checkID string, node string, values map[string]interface{}) *checks.CheckResult {
+	r := &checks.CheckResult{
+		CheckHostID: checks.CheckHostID(checkID, ""),
+		CheckID:     checkID,
+		Node:        node,
+		TimeStamp:   clock,
+		Duration:    1500 * time.Millisecond,
+		Results:     plugins.NewAgentResult(),
+	}
+
+	for key, value := range values {
+		r.Results.AddValue(key, value)
+	}
+
+	return r
+}
+
+func output(e *Exporter) string {
+	var buf bytes.Buffer
+
+	e.write(&buf)
+
+	return buf.String()
+}
+
+func TestExporterPostApply(t *testing.T) {
+	e := NewExporter(nil)
+	clock := time.Now()
+
+	e.PostApply(true, database.CommandSave, evaluation("check", "", eval.StateUp))
+	e.PostApply(true, database.CommandSave, evaluation("check", "host", eval.StateDown))
+	e.PostApply(true, database.CommandDelete, evaluation("ignored", "", eval.StateUp))
+	e.PostApply(true, database.CommandForward, result(clock, "check", "node1", map[string]interface{}{
+		"latency": 12,
+		"name":    "not numeric",
+	}))
+	e.PostApply(true, database.CommandSave, result(clock.Add(-time.Minute), "check", "node1", map[string]interface{}{
+		"latency": 99,
+	}))
+	e.PostApply(true, database.CommandSave, result(clock, "check", "node2", map[string]interface{}{
+		"latency": 13.5,
+	}))
+
+	out := output(e)
+
+	expected := []string{
+		`gansoi_check_state{check="check",host=""} 1`,
+		`gansoi_check_state{check="check",host="host"} 2`,
+		`gansoi_check_duration_seconds{check="check",host="",node="node1"} 1.5`,
+		`gansoi_check_value{check="check",host="",node="node1",key="latency"} 12`,
+		`gansoi_check_value{check="check",host="",node="node2",key="latency"} 13.5`,
+		`# TYPE gansoi_check_state gauge`,
+	}
+
+	for _, line := range expected {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("Output is missing '%s':\n%s", line, out)
+		}
+	}
+
+	for _, unexpected := range []string{"ignored", "not numeric", " 99\n"} {
+		if strings.Contains(out, unexpected) {
+			t.Errorf("Output contains '%s':\n%s", unexpected, out)
+		}
+	}
+
+	check := &checks.Check{}
+	check.ID = "check"
+	e.PostApply(true, database.CommandDelete, check)
+
+	if strings.Contains(output(e), `check="check"`) {
+		t.Fatalf("Deleted check is still exported")
+	}
+}
+
+func TestExporterPrune(t *testing.T) {
+	e := NewExporter(nil)
+	clock := time.Now()
+
+	hostResult := result(clock, "check", "node1", map[string]interface{}{"latency": 12})
+	hostResult.HostID = "host2"
+	hostResult.CheckHostID = checks.CheckHostID("check", "host2")
+
+	e.PostApply(true, database.CommandSave, evaluation("check", "", eval.StateUp))
+	e.PostApply(true, database.CommandSave, evaluation("check", "host1", eval.StateUp))
+	e.PostApply(true, database.CommandSave, evaluation("check", "host2", eval.StateDown))
+	e.PostApply(true, database.CommandSave, evaluation("other", "host2", eval.StateDown))
+	e.PostApply(true, database.CommandForward, result(clock, "check", "node1", map[string]interface{}{"latency": 12}))
+	e.PostApply(true, database.CommandForward, hostResult)
+
+	// host2 is removed from the check. Results are dropped, the arguments
+	// could have changed.
+	check := &checks.Check{Hosts: []string{"host1"}}
+	check.ID = "check"
+	e.PostApply(true, database.CommandSave, check)
+
+	out := output(e)
+
+	for _, line := range []string{
+		`gansoi_check_state{check="check",host=""} 1`,
+		`gansoi_check_state{check="check",host="host1"} 1`,
+		`gansoi_check_state{check="other",host="host2"} 2`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("Output is missing '%s':\n%s", line, out)
+		}
+	}
+
+	for _, unexpected := range []string{`check="check",host="host2"`, "gansoi_check_value{"} {
+		if strings.Contains(out, unexpected) {
+			t.Errorf("Output contains '%s' after check update:\n%s", unexpected, out)
+		}
+	}
+
+	e.PostApply(true, database.CommandForward, hostResult)
+
+	host := &ssh.SSH{}
+	host.ID = "host2"
+	e.PostApply(true, database.CommandDelete, host)
+
+	if strings.Contains(output(e), `host="host2"`) {
+		t.Fatalf("Deleted host is still exported:\n%s", output(e))
+	}
+}
+
+func TestExporterLeader(t *testing.T) {
+	e := NewExporter(nil)
+
+	e.PostApply(false, database.CommandSave, evaluation("check", "", eval.StateUp))
+	if !strings.Contains(output(e), "\ngansoi_leader 0\n") {
+		t.Errorf("Follower exported as leader:\n%s", output(e))
+	}
+
+	e.PostApply(true, database.CommandSave, evaluation("check", "", eval.StateUp))
+	if !strings.Contains(output(e), "\ngansoi_leader 1\n") {
+		t.Errorf("Leader not exported as leader:\n%s", output(e))
+	}
+}
+
+func TestExporterLoad(t *testing.T) {
+	db := boltdb.NewTestStore()
+	defer db.Close()
+
+	check := &checks.Check{AgentID: "mock"}
+	check.ID = "loaded"
+	db.Save(check)
+
+	ev := evaluation("loaded", "", eval.StateDown)
+	ev.ID = 1
+	db.Save(ev)
+
+	e := NewExporter(db)
+
+	err := e.Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %s", err.Error())
+	}
+
+	if !strings.Contains(output(e), `gansoi_check_state{check="loaded",host=""} 2`) {
+		t.Fatalf("Load() did not load the latest evaluation:\n%s", output(e))
+	}
+}
+
+func TestExporterExpvars(t *testing.T) {
+	testCounter.Set(42)
+
+	out := output(NewExporter(nil))
+
+	if !strings.Contains(out, "# TYPE gansoi_exporter_test_counter untyped\ngansoi_exporter_test_counter 42\n") {
+		t.Fatalf("Output is missing expvar:\n%s", out)
+	}
+}
+
+func TestExporterRouter(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+
+	e := NewExporter(nil)
+	e.PostApply(true, database.CommandSave, evaluation("quote\"d", "", eval.StateUp))
+
+	router := gin.New()
+	e.Router(router.Group("/metrics"))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Got status %d", w.Code)
+	}
+
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("Wrong content type '%s'", w.Header().Get("Content-Type"))
+	}
+
+	if !strings.Contains(w.Body.String(), `gansoi_check_state{check="quote\"d",host=""} 1`) {
+		t.Fatalf("Label not escaped:\n%s", w.Body.String())
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	cases := map[float64]string{
+		1:      "1",
+		0.25:   "0.25",
+		-3:     "-3",
+		1e21:   "1e+21",
+		1.5e-9: "1.5e-09",
+	}
+
+	for value, expected := range cases {
+		if formatValue(value) != expected {
+			t.Errorf("formatValue(%f) returned %s, expected %s", value, formatValue(value), expected)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package exporter
+
+import (
+	"bytes"
+	"expvar"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/gansoi/gansoi/checks"
+	"github.com/gansoi/gansoi/eval"
+)
+
+const (
+	contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+	// prefix is prepended to all metric names.
+	prefix = "gansoi_"
+)
+
+// Router will mount the metrics endpoint on router.
+//
+// Check states are replicated and the same on all nodes. Check results are
+// forwarded to the leader without being replicated, so
+// gansoi_check_duration_seconds and gansoi_check_value are only complete on
+// the leader. A follower will only export results seen while it was leader,
+// if any. Scrape all nodes and use the results from the node where
+// gansoi_leader is 1.
+func (e *Exporter) Router(router *gin.RouterGroup) {
+	router.GET("", func(c *gin.Context) {
+		var buf bytes.Buffer
+
+		e.write(&buf)
+
+		c.Data(http.StatusOK, contentType, buf.Bytes())
+	})
+}
+
+// write will write all metrics to w in the Prometheus text format.
+func (e *Exporter) write(w io.Writer) {
+	e.RLock()
+	e.writeLeader(w)
+	e.writeStates(w)
+	e.writeResults(w)
+	e.RUnlock()
+
+	writeExpvars(w)
+}
+
+// writeLeader will write if this node was the leader when the latest entry
+// was applied.
+func (e *Exporter) writeLeader(w io.Writer) {
+	leader := 0.0
+	if e.leader {
+		leader = 1.0
+	}
+
+	header(w, "leader", "gauge", "1 if this node is the leader, check results are only complete on the leader")
+	sample(w, "leader", "", leader)
+}
+
+// writeStates will write the state of all evaluations.
+func (e *Exporter) writeStates(w io.Writer) {
+	var states []string
+	for s := eval.State(0); s.Valid(); s++ {
+		name, _ := s.MarshalText()
+		states = append(states, fmt.Sprintf("%d=%s", s, name))
+	}
+
+	header(w, "check_state", "gauge", "Current state of check/host pairs ("+strings.Join(states, ", ")+")")
+
+	ids := make([]string, 0, len(e.evaluations))
+	for id := range e.evaluations {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		evaluation := e.evaluations[id]
+
+		sample(w, "check_state", labels("check", evaluation.CheckID, "host", evaluation.HostID), float64(evaluation.State))
+	}
+}
+
+// writeResults will write the duration and numeric values of the latest
+// results.
+func (e *Exporter) writeResults(w io.Writer) {
+	results := make([]*checks.CheckResult, 0, len(e.results))
+	for _, result := range e.results {
+		results = append(results, result)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].CheckHostID != results[j].CheckHostID {
+			return results[i].CheckHostID < results[j].CheckHostID
+		}
+
+		return results[i].Node < results[j].Node
+	})
+
+	header(w, "check_duration_seconds", "gauge", "Duration of the latest check run, complete on the leader only")
+
+	for _, result := range results {
+		l := labels("check", result.CheckID, "host", result.HostID, "node", result.Node)
+
+		sample(w, "check_duration_seconds", l, result.Duration.Seconds())
+	}
+
+	header(w, "check_value", "gauge", "Numeric values from the latest check result, complete on the leader only")
+
+	for _, result := range results {
+		values := result.Results.Numeric()
+
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			l := labels("check", result.CheckID, "host", result.HostID, "node", result.Node, "key", key)
+
+			sample(w, "check_value", l, values[key])
+		}
+	}
+}
+
+// writeExpvars will write all integer and float expvars. Some are counters,
+// some are gauges, so we leave them untyped.
+func writeExpvars(w io.Writer) {
+	expvar.Do(func(kv expvar.KeyValue) {
+		var value float64
+
+		switch v := kv.Value.(type) {
+		case *expvar.Int:
+			value = float64(v.Value())
+		case *expvar.Float:
+			value = v.Value()
+		default:
+			return
+		}
+
+		name := metricName(kv.Key)
+
+		header(w, name, "untyped", "Internal counter "+kv.Key)
+		sample(w, name, "", value)
+	})
+}
+
+// header will write HELP and TYPE for a metric.
+func header(w io.Writer, name string, typ string, help string) {
+	fmt.Fprintf(w, "# HELP %s%s %s\n", prefix, name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help))
+	fmt.Fprintf(w, "# TYPE %s%s %s\n", prefix, name, typ)
+}
+
+// sample will write a single sample.
+func sample(w io.Writer, name string, labels string, value float64) {
+	fmt.Fprintf(w, "%s%s%s %s\n", prefix, name, labels, formatValue(value))
+}
+
+// labels will format pairs of label names and values.
+func labels(pairs ...string) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+	var parts []string
+	for i := 0; i+1 < len(pairs); i += 2 {
+		parts = append(parts, pairs[i]+`="`+escaper.Replace(pairs[i+1])+`"`)
+	}
+
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// formatValue will format value as expected by Prometheus.
+func formatValue(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// metricName will replace characters not allowed in metric names.
+func metricName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || r == ':' {
+			return r
+		}
+
+		return '_'
+	}, name)
+}
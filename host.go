@@ -0,0 +1,206 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/gansoi/gansoi/boltdb"
+	"github.com/gansoi/gansoi/ca"
+	"github.com/gansoi/gansoi/cluster"
+	"github.com/gansoi/gansoi/config"
+	"github.com/gansoi/gansoi/database"
+	"github.com/gansoi/gansoi/logger"
+	"github.com/gansoi/gansoi/transports/ssh"
+)
+
+type (
+	// hostReply is the reply from a running core when looking up a host.
+	hostReply struct {
+		Host *ssh.SSH `json:"host"`
+		Key  []byte   `json:"key"`
+	}
+)
+
+var (
+	// errNoCore will be returned if no core is running on this node.
+	errNoCore = errors.New("no running core")
+
+	// hostTimeout is the time allowed for a running core to reply.
+	hostTimeout = 5 * time.Second
+)
+
+// hostHandler will reply with the host identified by the id parameter and
+// the cluster private key. The key is secret, only clients presenting a
+// certificate signed by coreCA are served.
+func hostHandler(db database.Reader, coreCA *ca.CA) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.TLS == nil {
+			c.AbortWithError(http.StatusForbidden, errors.New("TLS required"))
+			return
+		}
+
+		_, err := coreCA.VerifyHTTPRequest(c.Request)
+		if err != nil {
+			c.AbortWithError(http.StatusForbidden, err)
+			return
+		}
+
+		host := &ssh.SSH{}
+
+		err = db.One("ID", c.Param("id"), host)
+		if err != nil {
+			c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+
+		// A cluster without a key can still use --keyfile.
+		key, _ := ssh.KeyPEM(db)
+
+		c.JSON(http.StatusOK, hostReply{Host: host, Key: key})
+	}
+}
+
+// loadHost will look up the host identified by hostID and the cluster
+// private key. The core running on this node is asked first, if no core is
+// running, the database in conf.DataDir is read directly.
+func loadHost(conf *config.Configuration, hostID string) (*ssh.SSH, error) {
+	host, err := fetchHost(conf, hostID)
+	if err == nil {
+		return host, nil
+	}
+
+	if !errors.Is(err, errNoCore) {
+		return nil, err
+	}
+
+	logger.Debug("main", "looking up host %s in the database: %s", hostID, err.Error())
+
+	return readHost(conf, hostID)
+}
+
+// fetchHost will ask the core running on this node for the host identified
+// by hostID, authenticating with the node certificate. errNoCore is returned
+// if the core cannot be reached.
+func fetchHost(conf *config.Configuration, hostID string) (*ssh.SSH, error) {
+	info := cluster.NewInfo(path.Join(conf.DataDir, "cluster.json"))
+	if info.NodeCert == nil || info.NodeKey == nil || info.CACert == nil {
+		return nil, fmt.Errorf("%w: node is not part of a cluster", errNoCore)
+	}
+
+	pair, err := tls.X509KeyPair(info.NodeCert, info.NodeKey)
+	if err != nil {
+		return nil, err
+	}
+
+	rootCA, err := ca.DecodeCert(info.CACert)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(rootCA)
+
+	client := &http.Client{
+		Timeout: hostTimeout,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				Certificates: []tls.Certificate{pair},
+				RootCAs:      pool,
+			},
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	u := "https://" + localAddress(conf.Bind) + "/node/host/" + url.PathEscape(hostID)
+
+	resp, err := client.Get(u)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errNoCore, err.Error())
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("host %s not found", hostID)
+	default:
+		return nil, fmt.Errorf("looking up host %s failed: %s", hostID, resp.Status)
+	}
+
+	var reply hostReply
+
+	err = json.NewDecoder(resp.Body).Decode(&reply)
+	if err != nil {
+		return nil, err
+	}
+
+	if reply.Host == nil {
+		return nil, fmt.Errorf("host %s not found", hostID)
+	}
+
+	// The cluster key can be overridden by --keyfile, so a missing key is
+	// not fatal here.
+	err = ssh.LoadKey(reply.Key)
+	if err != nil {
+		logger.Debug("main", "failed to load cluster key: %s", err.Error())
+	}
+
+	return reply.Host, nil
+}
+
+// localAddress returns an address for reaching a listener bound to bind on
+// this node.
+func localAddress(bind string) string {
+	host, port, err := net.SplitHostPort(cluster.DefaultPort(bind))
+	if err != nil {
+		return bind
+	}
+
+	ip := net.ParseIP(host)
+	if host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+
+	return net.JoinHostPort(host, port)
+}
+
+// readHost will read the host identified by hostID and the cluster private
+// key from the database in conf.DataDir. The database is opened read-only,
+// this will fail if a core is holding it.
+func readHost(conf *config.Configuration, hostID string) (*ssh.SSH, error) {
+	db, err := boltdb.NewReadOnlyStore(path.Join(conf.DataDir, "gansoi.db"), 500*time.Millisecond)
+	if errors.Is(err, boltdb.ErrLocked) {
+		return nil, fmt.Errorf("cannot read host %s, the database is in use by a core that does not reply on %s. Use --address, --username and --keyfile instead", hostID, localAddress(conf.Bind))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	host := &ssh.SSH{}
+
+	err = db.One("ID", hostID, host)
+	if err != nil {
+		return nil, fmt.Errorf("host %s not found: %s", hostID, err.Error())
+	}
+
+	// The cluster key can be overridden by --keyfile, so a missing key is
+	// not fatal here.
+	err = ssh.Load(db)
+	if err != nil {
+		logger.Debug("main", "failed to load cluster key: %s", err.Error())
+	}
+
+	return host, nil
+}
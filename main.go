@@ -14,9 +14,11 @@ import (
 	"math/rand"
 	"net/http"
 	"os"
+	"os/user"
 	"path"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/contrib/static"
@@ -32,18 +34,23 @@ import (
 	"github.com/gansoi/gansoi/config"
 	"github.com/gansoi/gansoi/database"
 	"github.com/gansoi/gansoi/eval"
+	"github.com/gansoi/gansoi/exporter"
 	"github.com/gansoi/gansoi/logger"
 	"github.com/gansoi/gansoi/node"
 	"github.com/gansoi/gansoi/notify"
 	"github.com/gansoi/gansoi/plugins"
+	_ "github.com/gansoi/gansoi/plugins/agents/dns"
 	_ "github.com/gansoi/gansoi/plugins/agents/error"
+	_ "github.com/gansoi/gansoi/plugins/agents/exec"
 	_ "github.com/gansoi/gansoi/plugins/agents/filesystem"
 	_ "github.com/gansoi/gansoi/plugins/agents/http"
 	_ "github.com/gansoi/gansoi/plugins/agents/linuxload"
 	_ "github.com/gansoi/gansoi/plugins/agents/linuxmemory"
 	_ "github.com/gansoi/gansoi/plugins/agents/mysql"
+	_ "github.com/gansoi/gansoi/plugins/agents/nagios"
 	_ "github.com/gansoi/gansoi/plugins/agents/ping"
 	_ "github.com/gansoi/gansoi/plugins/agents/process"
+	_ "github.com/gansoi/gansoi/plugins/agents/prometheus"
 	_ "github.com/gansoi/gansoi/plugins/agents/smtp"
 	_ "github.com/gansoi/gansoi/plugins/agents/ssh"
 	_ "github.com/gansoi/gansoi/plugins/agents/tcpport"
@@ -51,12 +58,20 @@ import (
 	_ "github.com/gansoi/gansoi/plugins/notifiers/console"
 	_ "github.com/gansoi/gansoi/plugins/notifiers/email"
 	_ "github.com/gansoi/gansoi/plugins/notifiers/slack"
+	"github.com/gansoi/gansoi/transports"
 	"github.com/gansoi/gansoi/transports/ssh"
+	"github.com/gansoi/gansoi/tsdb"
 )
 
 var (
 	configFile = config.DefaultPath
 
+	// Flags used by runcheck and nagiosplugin to run checks on remote hosts.
+	checkAddress  string
+	checkUsername string
+	checkKeyFile  string
+	checkHostID   string
+
 	// These can be overridden when testing.
 	exit   = os.Exit
 	stdin  = io.Reader(os.Stdin)
@@ -157,6 +172,29 @@ func main() {
 		},
 	}
 
+	for _, cmd := range []*cobra.Command{cmdCheck, nagCheck} {
+		cmd.Flags().StringVar(&checkAddress,
+			"address",
+			"",
+			"Run the check on a remote host using SSH (1.2.3.4 or 1.2.3.4:22).")
+		cmd.Flags().StringVar(&checkUsername,
+			"username",
+			"",
+			"The SSH username. Defaults to the current user.")
+		cmd.Flags().StringVar(&checkKeyFile,
+			"keyfile",
+			"",
+			"The private key used for SSH authentication.")
+		cmd.Flags().StringVar(&checkHostID,
+			"hostid",
+			"",
+			"Run the check on a host from the cluster. The host is looked up from the core running on this node, or from the database if no core is running.")
+		cmd.Flags().StringVar(&configFile,
+			"config",
+			config.DefaultPath,
+			"The configuration file to use when looking up hosts.")
+	}
+
 	cmdDemo := &cobra.Command{
 		Use:   "demo",
 		Short: "Run a local Gansoi demo",
@@ -227,8 +265,14 @@ func runCheck(printSummary bool, arguments []string) {
 		exit(3)
 	}
 
-	// FIXME: Support remote hosts here!
-	result := checks.RunCheck(nil, &check)
+	transport, err := checkTransport()
+	if err != nil {
+		stderr.Write([]byte(err.Error()))
+
+		exit(3)
+	}
+
+	result := checks.RunCheck(transport, &check)
 
 	if printSummary {
 		if result.Error != "" {
@@ -249,6 +293,62 @@ func runCheck(printSummary bool, arguments []string) {
 	}
 }
 
+// checkTransport returns the transport used by runCheck as requested by
+// command line flags. If no remote host is requested, nil is returned and the
+// check will run locally.
+func checkTransport() (transports.Transport, error) {
+	if checkAddress != "" && checkHostID != "" {
+		return nil, errors.New("--address and --hostid cannot be used together")
+	}
+
+	var host *ssh.SSH
+
+	switch {
+	case checkHostID != "":
+		var err error
+
+		host, err = loadHost(loadConfig(), checkHostID)
+		if err != nil {
+			return nil, err
+		}
+
+	case checkAddress != "":
+		if checkKeyFile == "" {
+			return nil, errors.New("--keyfile is required when using --address")
+		}
+
+		host = &ssh.SSH{
+			Address:  checkAddress,
+			Username: checkUsername,
+		}
+
+	default:
+		return nil, nil
+	}
+
+	if checkUsername != "" {
+		host.Username = checkUsername
+	}
+
+	if host.Username == "" {
+		current, err := user.Current()
+		if err != nil {
+			return nil, err
+		}
+
+		host.Username = current.Username
+	}
+
+	if checkKeyFile != "" {
+		err := ssh.LoadKeyFile(checkKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load key from %s: %s", checkKeyFile, err.Error())
+		}
+	}
+
+	return host, nil
+}
+
 func loadConfig() *config.Configuration {
 	conf := config.NewConfiguration()
 	err := conf.LoadFromFile(configFile)
@@ -270,6 +370,106 @@ func openDatabase(conf *config.Configuration) *boltdb.BoltStore {
 	return db
 }
 
+// openResultStore will open the node-local store for raw check results.
+func openResultStore(conf *config.Configuration) *boltdb.LocalStore {
+	results, err := boltdb.NewLocalStore(path.Join(conf.DataDir, "results.db"))
+	if err != nil {
+		logger.Info("main", "failed to open result store in %s: %s", conf.DataDir, err.Error())
+		exit(1)
+	}
+
+	return results
+}
+
+// testRemote will run check on all live nodes except self. Nodes failing to
+// respond will be represented by a failed result.
+func testRemote(n *node.Node, check *checks.Check, self string) checks.ResultMatrix {
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+
+	matrix := make(checks.ResultMatrix)
+
+	for _, name := range n.LiveNodes() {
+		if name == self {
+			continue
+		}
+
+		wg.Add(1)
+
+		go func(name string) {
+			defer wg.Done()
+
+			remote := make(checks.ResultMatrix)
+
+			e := n.Post(name, "/test", check, &remote)
+			if e != nil {
+				remote.Add(&checks.CheckResult{
+					CheckID:   check.ID,
+					Node:      name,
+					Error:     e.Error(),
+					TimeStamp: time.Now(),
+				})
+			}
+
+			lock.Lock()
+			matrix.Merge(remote)
+			lock.Unlock()
+		}(name)
+	}
+
+	wg.Wait()
+
+	return matrix
+}
+
+// queryRemote will ask all live nodes except self to answer req from their
+// metric store. Nodes failing to respond are left out.
+func queryRemote(n *node.Node, req *tsdb.Request, self string) []*tsdb.Response {
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+
+	var responses []*tsdb.Response
+
+	for _, name := range n.LiveNodes() {
+		if name == self {
+			continue
+		}
+
+		wg.Add(1)
+
+		go func(name string) {
+			defer wg.Done()
+
+			var resp tsdb.Response
+
+			e := n.Post(name, "/metrics", req, &resp)
+			if e != nil {
+				logger.Debug("main", "Failed to query metrics on %s: %s", name, e.Error())
+				return
+			}
+
+			lock.Lock()
+			responses = append(responses, &resp)
+			lock.Unlock()
+		}(name)
+	}
+
+	wg.Wait()
+
+	return responses
+}
+
+// openMetricStore will open the time-series store for numeric values.
+func openMetricStore(conf *config.Configuration) *tsdb.Store {
+	metrics, err := tsdb.NewStore(path.Join(conf.DataDir, "metrics.db"), conf.Retention.Metrics)
+	if err != nil {
+		logger.Info("main", "failed to open metric store in %s: %s", conf.DataDir, err.Error())
+		exit(1)
+	}
+
+	return metrics
+}
+
 func initCore(_ *cobra.Command, _ []string) {
 	conf := loadConfig()
 	db := openDatabase(conf)
@@ -404,17 +604,53 @@ func runCore(_ *cobra.Command, _ []string) {
 	// And listen for future changes.
 	n.RegisterListener(summary)
 
+	// Check results from all nodes arrive through the Raft log. The evaluator
+	// will only evaluate them on the leader.
+	n.RegisterListener(e)
+
+	// Numeric values from check results are kept for graphing. Each node
+	// records the results it produces, and queries are answered by all live
+	// nodes. This way no history is lost when the leader changes.
+	metrics := openMetricStore(conf)
+	metrics.SetPeers(func(req *tsdb.Request) []*tsdb.Response {
+		return queryRemote(n, req, info.Self())
+	})
+
+	// Check states, latest results and internal counters in the Prometheus
+	// text format.
+	exp := exporter.NewExporter(n)
+	err = exp.Load()
+	if err != nil {
+		logger.Info("main", "Failed to load evaluations for exporter: %s", err.Error())
+	}
+	n.RegisterListener(exp)
+
+	// The SSH key is generated by the leader, but all nodes need it for
+	// executing remote checks.
+	ssh.Load(n)
+	n.RegisterListener(ssh.KeyListener{})
+
+	// All nodes run the scheduler. Checks are distributed across all live
+	// nodes.
+	// Raw results are kept on the node executing the check, and forwarded to
+	// the leader for evaluation. They never reach the Raft log.
+	results := openResultStore(conf)
+
 	scheduler := checks.NewScheduler(n, info.Self())
+	scheduler.SetLimits(conf.Limits)
+	scheduler.SetResultStore(results)
+	scheduler.SetRecorder(metrics)
+	scheduler.Run()
 
-	go func() {
-		for {
-			result := <-scheduler.Results
-			e.Evaluate(result)
-		}
-	}()
+	// All nodes roll up their own results. The rollups are replicated, and
+	// old rollups are deleted by the leader.
+	compactor := checks.NewCompactor(results, n, info.Self(), conf.Retention)
+	compactor.Run()
 
 	go func() {
 		for leader := range n.LeaderCh() {
+			compactor.SetLeader(leader)
+
 			if leader {
 				sshErr := ssh.Init(n)
 				if sshErr != nil {
@@ -430,10 +666,6 @@ func runCore(_ *cobra.Command, _ []string) {
 				if conf.ExclusiveSeeding {
 					conf.DeleteUnknownSeeds(n)
 				}
-
-				scheduler.Run()
-			} else {
-				scheduler.Stop()
 			}
 		}
 	}()
@@ -442,7 +674,32 @@ func runCore(_ *cobra.Command, _ []string) {
 	engine.Use(gin.Logger())
 	engine.Use(gin.ErrorLogger())
 
-	n.Router(internal.Group("/node"))
+	nodeRouter := internal.Group("/node")
+	n.Router(nodeRouter)
+
+	// Other nodes can ask us to run a check for testing.
+	nodeRouter.POST("/test", func(c *gin.Context) {
+		var check checks.Check
+		e := c.BindJSON(&check)
+		if e != nil {
+			return
+		}
+
+		matrix, e := checks.RunAll(n, &check, info.Self())
+		if e != nil {
+			c.AbortWithError(http.StatusBadRequest, e)
+			return
+		}
+
+		c.JSON(http.StatusOK, matrix)
+	})
+
+	// The command line tools can look up hosts from the running core.
+	nodeRouter.GET("/host/:id", hostHandler(n, core.CA()))
+
+	// Other nodes can ask for the numeric values recorded here.
+	metrics.NodeRouter(nodeRouter.Group("/metrics"))
+
 	core.Router(internal.Group(cluster.CorePrefix), stream, n)
 
 	api := engine.Group("/api")
@@ -453,6 +710,16 @@ func runCore(_ *cobra.Command, _ []string) {
 		}))
 	}
 
+	// Prometheus endpoint. It's outside /api to match what Prometheus
+	// expects by default, but uses the same authentication.
+	metricsGroup := engine.Group("/metrics")
+	if conf.HTTP.Login != "" && conf.HTTP.Password != "" {
+		metricsGroup.Use(gin.BasicAuth(gin.Accounts{
+			conf.HTTP.Login: conf.HTTP.Password,
+		}))
+	}
+	exp.Router(metricsGroup)
+
 	restChecks := node.NewRestAPI[checks.Check](n)
 	restChecks.Router(api.Group("/checks"))
 
@@ -468,34 +735,47 @@ func runCore(_ *cobra.Command, _ []string) {
 	restHosts := node.NewRestAPI[ssh.SSH](n)
 	restHosts.Router(api.Group("/hosts"))
 
-	// Endpoint for running a check on the cluster node.
+	restMaintenance := node.NewRestAPI[eval.Maintenance](n)
+	restMaintenance.Router(api.Group("/maintenance"))
+
+	// Rollups of old check results. The host ID is optional.
+	api.GET("/rollups/:checkid", func(c *gin.Context) {
+		checkHostID := checks.CheckHostID(c.Param("checkid"), c.Query("host"))
+
+		rollups := make([]checks.Rollup, 0)
+
+		e := n.Find("CheckHostID", checkHostID, &rollups, -1, 0, false)
+		if e != nil && e != database.ErrNotFound {
+			c.AbortWithError(http.StatusInternalServerError, e)
+			return
+		}
+
+		c.JSON(http.StatusOK, rollups)
+	})
+
+	// Time-series of numeric values from check results.
+	metrics.Router(api.Group("/metrics"))
+
+	// Endpoint for running a check on all hosts. If the query parameter
+	// "nodes" is "all", the check is run from all live nodes.
 	api.POST("/test", func(c *gin.Context) {
 		var check checks.Check
-		var checkResult *checks.CheckResult
 		e := c.BindJSON(&check)
 		if e != nil {
-			c.AbortWithError(http.StatusBadRequest, e)
+			return
 		}
 
-		if len(check.Hosts) > 0 {
-			host := ssh.SSH{}
-
-			// Try the first host only. This should be good enough for now.
-			e = db.One("ID", check.Hosts[0], &host)
-			if e != nil {
-				c.AbortWithError(http.StatusBadRequest, e)
-				return
-			}
-
-			checkResult = checks.RunCheck(&host, &check)
-			checkResult.HostID = check.Hosts[0]
-		} else {
-			checkResult = checks.RunCheck(nil, &check)
+		matrix, e := checks.RunAll(n, &check, info.Self())
+		if e != nil {
+			c.AbortWithError(http.StatusBadRequest, e)
+			return
 		}
 
-		checkResult.Node = info.Self()
+		if c.Query("nodes") == "all" {
+			matrix.Merge(testRemote(n, &check, info.Self()))
+		}
 
-		c.JSON(http.StatusOK, checkResult)
+		c.JSON(http.StatusOK, matrix)
 	})
 
 	api.POST("/testcontact", func(c *gin.Context) {
@@ -571,6 +851,44 @@ func runCore(_ *cobra.Command, _ []string) {
 		writer.Close()
 	})
 
+	// Results pushed to passive checks. The token is the authentication, so
+	// this is kept out of the API group.
+	engine.POST("/api/push/:token", func(c *gin.Context) {
+		check, e := checks.FindPassive(n, c.Param("token"))
+		if e == checks.ErrUnknownToken {
+			c.AbortWithError(http.StatusNotFound, e)
+			return
+		}
+
+		if e != nil {
+			c.AbortWithError(http.StatusInternalServerError, e)
+			return
+		}
+
+		var push checks.Push
+
+		// An empty body is a simple "I'm alive".
+		if c.Request.ContentLength != 0 {
+			e = c.BindJSON(&push)
+			if e != nil {
+				return
+			}
+		}
+
+		result, e := scheduler.Push(check, &push)
+		if errors.Is(e, checks.ErrInvalidPush) {
+			c.AbortWithError(http.StatusBadRequest, e)
+			return
+		}
+
+		if e != nil {
+			c.AbortWithError(http.StatusInternalServerError, e)
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	})
+
 	engine.GET("/ssh/pubkey", func(c *gin.Context) {
 		publicKey := ssh.PublicKey()
 
@@ -3,18 +3,28 @@ package main
 import (
 	"bytes"
 	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/gin-gonic/gin"
+
+	"github.com/gansoi/gansoi/boltdb"
+	"github.com/gansoi/gansoi/ca"
 	"github.com/gansoi/gansoi/cluster"
 	"github.com/gansoi/gansoi/config"
+	"github.com/gansoi/gansoi/database"
+	"github.com/gansoi/gansoi/transports/ssh"
 )
 
 type (
@@ -173,6 +183,88 @@ func TestNagiosPluginBangHashFailed(t *testing.T) {
 	main()
 }
 
+func TestCheckTransport(t *testing.T) {
+	defer func() {
+		checkAddress = ""
+		checkUsername = ""
+		checkKeyFile = ""
+		checkHostID = ""
+	}()
+
+	cases := []struct {
+		address  string
+		username string
+		keyFile  string
+		hostID   string
+		remote   bool
+		err      bool
+	}{
+		{"", "", "", "", false, false},
+		{"127.0.0.1", "", "", "", false, true},
+		{"127.0.0.1", "root", "/does/not/exist", "", false, true},
+		{"127.0.0.1", "", "", "host", false, true},
+	}
+
+	for i, c := range cases {
+		checkAddress = c.address
+		checkUsername = c.username
+		checkKeyFile = c.keyFile
+		checkHostID = c.hostID
+
+		transport, err := checkTransport()
+		if c.err != (err != nil) {
+			t.Errorf("%d: checkTransport() returned unexpected error: %v", i, err)
+		}
+
+		if c.remote != (transport != nil) {
+			t.Errorf("%d: checkTransport() returned wrong transport: %v", i, transport)
+		}
+	}
+}
+
+func TestLoadHost(t *testing.T) {
+	dirname, _ := ioutil.TempDir(os.TempDir(), "TestLoadHost")
+	defer os.RemoveAll(dirname)
+
+	conf := &config.Configuration{
+		DataDir: dirname,
+	}
+
+	_, err := loadHost(conf, "host")
+	if err == nil {
+		t.Fatalf("loadHost() did not fail without a database")
+	}
+
+	db := openDatabase(conf)
+	db.ProcessLogEntry(database.NewLogEntry(database.CommandSave, &ssh.SSH{
+		Object:   database.Object{ID: "host"},
+		Address:  "127.0.0.1",
+		Username: "gansoi",
+	}))
+
+	// A running core holds the lock on the database.
+	_, err = loadHost(conf, "host")
+	if err == nil || !strings.Contains(err.Error(), "in use by a core") {
+		t.Fatalf("loadHost() did not fail with a clear error for a locked database, got %v", err)
+	}
+
+	db.Close()
+
+	host, err := loadHost(conf, "host")
+	if err != nil {
+		t.Fatalf("loadHost() failed: %s", err.Error())
+	}
+
+	if host.Address != "127.0.0.1" || host.Username != "gansoi" {
+		t.Fatalf("loadHost() returned wrong host: %+v", host)
+	}
+
+	_, err = loadHost(conf, "unknown")
+	if err == nil {
+		t.Fatalf("loadHost() did not fail for unknown host")
+	}
+}
+
 func TestLoadConfig(t *testing.T) {
 	f, _ := ioutil.TempFile(os.TempDir(), "TestLoadConfig")
 	f.WriteString("")
@@ -312,3 +404,116 @@ func TestMainVersion(t *testing.T) {
 	os.Args = []string{os.Args[0], "version"}
 	main()
 }
+
+func TestLoadHostRunningCore(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+
+	dirname, _ := ioutil.TempDir(os.TempDir(), "TestLoadHostRunningCore")
+	defer os.RemoveAll(dirname)
+
+	coreCA, _ := ca.InitCA()
+	nodeKey, _ := ca.GenerateKey()
+	csr, _ := ca.GenerateCSR(nodeKey, "node", []net.IP{net.ParseIP("127.0.0.1")})
+	nodeCert, _ := coreCA.SignCSR(csr)
+
+	info := cluster.NewInfo(path.Join(dirname, "cluster.json"))
+	info.CACert, _ = coreCA.CertificatePEM()
+	info.NodeCert, _ = ca.EncodeCert(nodeCert)
+	info.NodeKey, _ = ca.EncodeKey(nodeKey)
+	info.Save()
+
+	db := boltdb.NewTestStore()
+	defer db.Close()
+
+	ssh.Init(db)
+	db.Save(&ssh.SSH{
+		Object:   database.Object{ID: "host"},
+		Address:  "127.0.0.1",
+		Username: "gansoi",
+	})
+
+	router := gin.New()
+	router.GET("/node/host/:id", hostHandler(db, coreCA))
+
+	pair, _ := tls.X509KeyPair(info.NodeCert, info.NodeKey)
+
+	server := httptest.NewUnstartedServer(router)
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{pair},
+		ClientAuth:   tls.RequestClientCert,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	conf := &config.Configuration{
+		DataDir: dirname,
+		Bind:    server.Listener.Addr().String(),
+	}
+
+	host, err := loadHost(conf, "host")
+	if err != nil {
+		t.Fatalf("loadHost() failed: %s", err.Error())
+	}
+
+	if host.Address != "127.0.0.1" || host.Username != "gansoi" {
+		t.Fatalf("loadHost() returned wrong host: %+v", host)
+	}
+
+	if ssh.PublicKey() == "" {
+		t.Fatalf("loadHost() did not load the cluster key")
+	}
+
+	// The core knows, we should not look in the database.
+	_, err = loadHost(conf, "unknown")
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("loadHost() did not fail for unknown host, got %v", err)
+	}
+
+	// Clients without a certificate signed by the cluster CA must not get
+	// the key.
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/node/host/host", nil)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("hostHandler() served a request without TLS, got %d", w.Code)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(coreCA.Certificate)
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+		},
+	}
+
+	resp, err := client.Get(server.URL + "/node/host/host")
+	if err != nil {
+		t.Fatalf("Get() failed: %s", err.Error())
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("hostHandler() served a client without a certificate, got %d", resp.StatusCode)
+	}
+}
+
+func TestLocalAddress(t *testing.T) {
+	cases := []struct {
+		bind     string
+		expected string
+	}{
+		{":4934", "127.0.0.1:4934"},
+		{"0.0.0.0:4934", "127.0.0.1:4934"},
+		{"[::]:4934", "127.0.0.1:4934"},
+		{"10.0.0.1", "10.0.0.1:4934"},
+		{"10.0.0.1:1234", "10.0.0.1:1234"},
+	}
+
+	for _, c := range cases {
+		got := localAddress(c.bind)
+		if got != c.expected {
+			t.Errorf("localAddress(%s) returned %s, expected %s", c.bind, got, c.expected)
+		}
+	}
+}
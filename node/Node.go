@@ -3,8 +3,10 @@ package node
 import (
 	"bytes"
 	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"expvar"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
@@ -51,6 +53,8 @@ var (
 	applyNoleader = expvar.NewInt("apply_noleader")
 	applyProxy    = expvar.NewInt("apply_proxy")
 	applyDirect   = expvar.NewInt("apply_direct")
+	forwardProxy  = expvar.NewInt("forward_proxy")
+	forwardDirect = expvar.NewInt("forward_direct")
 	nodeSave      = expvar.NewInt("node_save")
 	nodeOne       = expvar.NewInt("node_one")
 	nodeAll       = expvar.NewInt("node_all")
@@ -60,6 +64,10 @@ var (
 	// ErrNoLeader will be returned if an operation requires a leader - but
 	// we have none.
 	ErrNoLeader = errors.New("no leader")
+
+	// liveTimeout is the time a node can go without reporting in before it
+	// is no longer considered live. Nodes will report every two seconds.
+	liveTimeout = time.Second * 10
 )
 
 func init() {
@@ -223,6 +231,31 @@ func (n *Node) applyHandler(c *gin.Context) {
 	n.raft.Apply(b, time.Minute)
 }
 
+// forwardHandler can be used by other nodes to forward data to listeners on
+// the leader. The POST body should consists of the complete output from
+// LogEntry.Byte().
+func (n *Node) forwardHandler(c *gin.Context) {
+	if !n.leader {
+		c.AbortWithStatus(http.StatusGone)
+		return
+	}
+
+	var entry database.LogEntry
+
+	err := c.BindJSON(&entry)
+	if err != nil {
+		return
+	}
+
+	data := entry.Payload()
+	if data == nil {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	n.PostApply(true, database.CommandForward, data)
+}
+
 // nodesHandler will return stats for all nodes in the cluster.
 func (n *Node) nodesHandler(c *gin.Context) {
 	var all []nodeInfo
@@ -236,6 +269,23 @@ func (n *Node) nodesHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, all)
 }
 
+// LiveNodes returns the names of all nodes that reported in to the cluster
+// recently.
+func (n *Node) LiveNodes() []string {
+	var all []nodeInfo
+	var live []string
+
+	n.db.All(&all, -1, 0, false)
+
+	for _, ni := range all {
+		if time.Since(ni.Updated) < liveTimeout {
+			live = append(live, ni.Name)
+		}
+	}
+
+	return live
+}
+
 // apply will apply the log entry to the local Raft node if it's leader, will
 // forward to leader otherwise.
 func (n *Node) apply(entry *database.LogEntry) error {
@@ -302,6 +352,68 @@ func (n *Node) Find(field string, value interface{}, to interface{}, limit int,
 	return n.db.Find(field, value, to, limit, skip, reverse)
 }
 
+// Forward will deliver data to listeners on the leader as
+// database.CommandForward without committing anything to the Raft log. This
+// is much cheaper than Save() for data the cluster doesn't need to agree on.
+func (n *Node) Forward(data interface{}) error {
+	if n.raft.Leader() == "" {
+		applyNoleader.Add(1)
+
+		return ErrNoLeader
+	}
+
+	if n.leader {
+		forwardDirect.Add(1)
+
+		n.PostApply(true, database.CommandForward, data)
+
+		return nil
+	}
+
+	forwardProxy.Add(1)
+
+	entry := database.NewLogEntry(database.CommandForward, data)
+
+	r := bytes.NewReader(entry.Byte())
+	l := n.raft.Leader()
+	u := "https://" + string(l) + n.basePath + "/forward"
+
+	resp, err := n.client.Post(u, "gansoi/entry", r)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("forward to %s failed: %s", l, resp.Status)
+	}
+
+	return nil
+}
+
+// Post will POST data as JSON to path on the node called name, and decode
+// the JSON response into result. path is relative to the node router.
+func (n *Node) Post(name string, path string, data interface{}, result interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	u := "https://" + name + n.basePath + path
+
+	resp, err := n.client.Post(u, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("request to %s failed: %s", name, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
 // Delete deletes one record.
 func (n *Node) Delete(data interface{}) error {
 	nodeDelete.Add(1)
@@ -338,6 +450,7 @@ func (n *Node) Router(router *gin.RouterGroup) {
 	router.GET("/stats", ginexpvar.Handler())
 	router.GET("/nodes", n.nodesHandler)
 	router.POST("/apply", n.applyHandler)
+	router.POST("/forward", n.forwardHandler)
 }
 
 // AddPeer adds a new cluster/raft peer.
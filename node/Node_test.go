@@ -1,9 +1,15 @@
 package node
 
 import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"sync"
 	"testing"
 
+	"github.com/gin-gonic/gin"
+
 	"github.com/gansoi/gansoi/database"
 )
 
@@ -30,3 +36,94 @@ func TestNodeLeaderChange(t *testing.T) {
 // Make sure we implement the needed interfaces.
 var _ database.ReadWriter = (*Node)(nil)
 var _ database.Broadcaster = (*Node)(nil)
+
+type (
+	forwardListener chan database.Command
+)
+
+func (f forwardListener) PostApply(_ bool, command database.Command, _ interface{}) {
+	f <- command
+}
+
+func TestNodeForwardHandler(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+
+	listener := make(forwardListener, 1)
+
+	n := &Node{}
+	n.RegisterListener(listener)
+
+	router := gin.New()
+	n.Router(router.Group("/node"))
+
+	entry := database.NewLogEntry(database.CommandSave, &nodeInfo{Name: "test"})
+
+	// Only the leader should accept forwarded data.
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/node/forward", bytes.NewReader(entry.Byte()))
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusGone {
+		t.Fatalf("Follower accepted forwarded data, got %d", w.Code)
+	}
+
+	n.leader = true
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/node/forward", strings.NewReader("garbage"))
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Leader accepted garbage, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/node/forward", bytes.NewReader(entry.Byte()))
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Leader did not accept forwarded data, got %d", w.Code)
+	}
+
+	if command := <-listener; command != database.CommandForward {
+		t.Fatalf("Listener got %s, expected %s", command, database.CommandForward)
+	}
+}
+
+func TestNodePost(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+
+	router := gin.New()
+	router.POST("/node/echo", func(c *gin.Context) {
+		var data map[string]string
+		c.BindJSON(&data)
+		c.JSON(http.StatusOK, data)
+	})
+
+	server := httptest.NewTLSServer(router)
+	defer server.Close()
+
+	n := &Node{client: server.Client(), basePath: "/node"}
+	name := strings.TrimPrefix(server.URL, "https://")
+
+	var result map[string]string
+
+	err := n.Post(name, "/echo", map[string]string{"hello": "world"}, &result)
+	if err != nil {
+		t.Fatalf("Post() failed: %s", err.Error())
+	}
+
+	if result["hello"] != "world" {
+		t.Fatalf("Post() decoded wrong result: %v", result)
+	}
+
+	err = n.Post(name, "/nonexisting", nil, &result)
+	if err == nil {
+		t.Fatalf("Post() did not fail on 404")
+	}
+
+	err = n.Post(name, "/echo", make(chan int), &result)
+	if err == nil {
+		t.Fatalf("Post() did not fail on unmarshalable data")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/go-playground/validator/v10"
 
 	"github.com/gansoi/gansoi/database"
+	"github.com/gansoi/gansoi/eval"
 )
 
 type (
@@ -14,6 +15,10 @@ type (
 		database.Object `storm:"inline"`
 		Name            string   `json:"name" validate:"required"`
 		Members         []string `json:"members"`
+
+		// States limits notifications to changes to or from these states.
+		// If empty, the group is notified about all changes.
+		States []eval.State `json:"states"`
 	}
 )
 
@@ -45,6 +50,24 @@ func (g *ContactGroup) GetContacts(db database.Reader) ([]*Contact, error) {
 	return contacts, nil
 }
 
+// Wants returns true if g should be notified about a change involving any
+// of states.
+func (g *ContactGroup) Wants(states ...eval.State) bool {
+	if len(g.States) == 0 {
+		return true
+	}
+
+	for _, wanted := range g.States {
+		for _, state := range states {
+			if state == wanted {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
 // Validate implements database.Validator.
 func (g *ContactGroup) Validate(db database.Reader) error {
 	v := validator.New()
@@ -7,6 +7,7 @@ import (
 
 	"github.com/gansoi/gansoi/boltdb"
 	"github.com/gansoi/gansoi/database"
+	"github.com/gansoi/gansoi/eval"
 )
 
 func TestLoadContactGroupFail(t *testing.T) {
@@ -125,3 +126,24 @@ func TestContactGroupValidate(t *testing.T) {
 		}
 	}
 }
+
+func TestContactGroupWants(t *testing.T) {
+	cases := []struct {
+		wanted   []eval.State
+		states   []eval.State
+		expected bool
+	}{
+		{nil, []eval.State{eval.StateWarning}, true},
+		{[]eval.State{eval.StateCritical}, []eval.State{eval.StateWarning, eval.StateUp}, false},
+		{[]eval.State{eval.StateCritical}, []eval.State{eval.StateCritical, eval.StateWarning}, true},
+		{[]eval.State{eval.StateCritical, eval.StateDown}, []eval.State{eval.StateUp, eval.StateDown}, true},
+	}
+
+	for i, c := range cases {
+		group := &ContactGroup{States: c.wanted}
+
+		if group.Wants(c.states...) != c.expected {
+			t.Errorf("%d: Wants(%v) returned %v, expected %v", i, c.states, !c.expected, c.expected)
+		}
+	}
+}
@@ -22,6 +22,14 @@ var (
 	stateCacheLock sync.RWMutex
 	stateCache     = make(map[string]eval.State)
 
+	// maintenanceCache holds the CheckHostIDs seen in maintenance, until
+	// we see them out of maintenance again.
+	maintenanceCache = make(map[string]bool)
+
+	// flapCache holds the CheckHostIDs seen flapping, until we see them
+	// stable again.
+	flapCache = make(map[string]bool)
+
 	sent = expvar.NewInt("notification_sent")
 )
 
@@ -59,15 +67,99 @@ func (n *Notifier) gotEvaluation(e *eval.Evaluation) error {
 	// For how long have we had this state?
 	duration := e.End.Sub(e.Start)
 
-	// Retrieve the last known state of the check. If the last state is
-	// unknown, StateUnknown will be used.
-	stateCacheLock.RLock()
-	lastState := stateCache[e.CheckHostID]
-	stateCacheLock.RUnlock()
+	// A soft state is not confirmed yet. We wait for it to become hard.
+	if e.Soft {
+		logger.Debug("notify", "[%s] Ignoring soft state %s (attempt %d of %d)", e.CheckHostID, e.State, e.Attempts, check.MaxAttempts)
+		return nil
+	}
+
+	// A check depending on a failed check is expected to fail. Notifications
+	// will be sent for the failed check, no need to page anyone twice.
+	if e.State == eval.StateUnreachable {
+		logger.Debug("notify", "[%s] Ignoring %s state", e.CheckHostID, e.State)
+		return nil
+	}
+
+	// Nothing is sent during maintenance. We remember that we have been in
+	// maintenance, to be able to tell anyone if the target is still down when
+	// the window ends.
+	stateCacheLock.Lock()
+	maintained := maintenanceCache[e.CheckHostID]
+
+	if e.Maintenance {
+		maintenanceCache[e.CheckHostID] = true
+	} else {
+		delete(maintenanceCache, e.CheckHostID)
+	}
+	stateCacheLock.Unlock()
+
+	if e.Maintenance {
+		logger.Debug("notify", "[%s] Ignoring %s state in maintenance", e.CheckHostID, e.State)
+		return nil
+	}
+
+	if maintained && e.State.Problem() {
+		stateCacheLock.Lock()
+		stateCache[e.CheckHostID] = e.State
+		stateCacheLock.Unlock()
+
+		logger.Info("notify", "%s is still %s after maintenance %s", e.CheckHostID, e.State, e.History.ColorString())
+
+		n.notify(&check, e, []eval.State{e.State}, fmt.Sprintf("%s is still %s after maintenance", e.CheckHostID, e.State.String()))
+
+		return nil
+	}
+
+	// Retrieve the last known state of the check. If we have never seen the
+	// check, seen will be false.
+	stateCacheLock.Lock()
+	lastState, seen := stateCache[e.CheckHostID]
+	flapped := flapCache[e.CheckHostID]
+
+	if e.Flapping {
+		flapCache[e.CheckHostID] = true
+	} else {
+		delete(flapCache, e.CheckHostID)
+	}
+	stateCacheLock.Unlock()
+
+	// While flapping, we only tell when it starts and stops. Individual
+	// state changes are suppressed.
+	switch {
+	case e.Flapping && !flapped:
+		logger.Info("notify", "%s started flapping (%.0f%%) %s", e.CheckHostID, e.FlapScore, e.History.ColorString())
+
+		n.notify(&check, e, []eval.State{e.State, lastState}, fmt.Sprintf("%s started flapping", e.CheckHostID))
+
+		return nil
+
+	case e.Flapping:
+		logger.Debug("notify", "[%s] Ignoring %s state while flapping (%.0f%%)", e.CheckHostID, e.State, e.FlapScore)
+
+		return nil
+
+	case flapped:
+		stateCacheLock.Lock()
+		stateCache[e.CheckHostID] = e.State
+		stateCacheLock.Unlock()
+
+		logger.Info("notify", "%s stopped flapping and is %s %s", e.CheckHostID, e.State, e.History.ColorString())
+
+		n.notify(&check, e, []eval.State{e.State, lastState}, fmt.Sprintf("%s stopped flapping and is %s", e.CheckHostID, e.State.String()))
+
+		return nil
+	}
+
+	// A check "coming online" is unknown until enough results arrive. We
+	// keep waiting for a real state.
+	if !seen && e.State == eval.StateUnknown {
+		logger.Debug("notify", "[%s] Ignoring %s state before first evaluation %s", e.CheckHostID, e.State, e.History.ColorString())
+		return nil
+	}
 
 	// If nothing changed since last evaluation, we can safely abort since
 	// there's nothing to notify about.
-	if e.State == lastState {
+	if seen && e.State == lastState {
 		logger.Debug("notify", "[%s] Ignoring unchanged state (Last: %s, Current: %s, Duration: %s) %s", e.CheckHostID, lastState, e.State, duration.String(), e.History.ColorString())
 		return nil
 	}
@@ -77,20 +169,26 @@ func (n *Notifier) gotEvaluation(e *eval.Evaluation) error {
 	stateCacheLock.Unlock()
 
 	// If we arrive here we know that state has changed since last evaluation.
-	// If we changed from StateUnknown, we ignore this state change because it
-	// is caused by a check "coming online".
-	if lastState == eval.StateUnknown {
-		logger.Info("notify", "[%s] Ignoring %s when previous state is %s %v", e.CheckHostID, e.State, lastState, e.History.ColorString())
+	// If this is the first state we see, we ignore it because it is caused by
+	// a check "coming online". Later changes to StateUnknown are reported, a
+	// plugin can report unknown on its own.
+	if !seen {
+		logger.Info("notify", "[%s] Ignoring %s when previous state is unknown %v", e.CheckHostID, e.State, e.History.ColorString())
 
 		return nil
 	}
 
 	logger.Info("notify", "%s is %s %s", e.CheckHostID, e.State, e.History.ColorString())
 
-	text := fmt.Sprintf("%s is %s", e.CheckHostID, e.State.String())
+	n.notify(&check, e, []eval.State{e.State, lastState}, fmt.Sprintf("%s is %s", e.CheckHostID, e.State.String()))
+
+	return nil
+}
 
-	targetGroups := check.ContactGroups
-	for _, groupID := range targetGroups {
+// notify will send text to all contacts in the contact groups of check
+// wanting to know about states.
+func (n *Notifier) notify(check *checks.Check, e *eval.Evaluation, states []eval.State, text string) {
+	for _, groupID := range check.ContactGroups {
 		group, err := LoadContactGroup(n.db, groupID)
 		if err != nil {
 			logger.Info("notify", "[%s] ContactGroup not found (%s)", e.CheckHostID, groupID)
@@ -98,6 +196,12 @@ func (n *Notifier) gotEvaluation(e *eval.Evaluation) error {
 			continue
 		}
 
+		if !group.Wants(states...) {
+			logger.Debug("notify", "[%s] ContactGroup %s is not interested in %v", e.CheckHostID, groupID, states)
+
+			continue
+		}
+
 		contacts, _ := group.GetContacts(n.db)
 		for _, contact := range contacts {
 			sent.Add(1)
@@ -106,6 +210,4 @@ func (n *Notifier) gotEvaluation(e *eval.Evaluation) error {
 			contact.Notify(text)
 		}
 	}
-
-	return nil
 }
@@ -186,3 +186,330 @@ func TestPostApply(t *testing.T) {
 }
 
 var _ database.Listener = (*Notifier)(nil)
+
+func TestGotEvaluationSoft(t *testing.T) {
+	db := boltdb.NewTestStore()
+
+	contact := &Contact{Name: "testcontact", Notifier: "mockn"}
+	db.Save(contact)
+
+	group := &ContactGroup{Name: "testgroup", Members: []string{contact.GetID()}}
+	db.Save(group)
+
+	check := &checks.Check{
+		Name:          "soft",
+		AgentID:       "mock",
+		MaxAttempts:   2,
+		ContactGroups: []string{group.GetID()},
+	}
+	db.Save(check)
+
+	e := eval.NewEvaluator(db)
+	n, _ := NewNotifier(db)
+
+	timeline := []struct {
+		err             bool
+		expectedMessage string
+	}{
+		{false, ""},
+		{false, ""},
+		{true, ""},
+		{false, ""},
+		{true, ""},
+		{true, "Down"},
+		{true, ""},
+		{false, "Up"},
+	}
+
+	for i, c := range timeline {
+		check.Arguments = json.RawMessage(`{}`)
+		if c.err {
+			check.Arguments = json.RawMessage(`{"err": true}`)
+		}
+
+		result := checks.RunCheck(nil, check)
+		result.CheckHostID = checks.CheckHostID(check.GetID(), "")
+		result.CheckID = check.GetID()
+
+		evaluation, err := e.Evaluate(result)
+		if err != nil {
+			t.Fatalf("Evaluate() failed: %s", err.Error())
+		}
+
+		notifyMessage = ""
+		n.gotEvaluation(evaluation)
+
+		if c.expectedMessage != "" && !strings.Contains(notifyMessage, c.expectedMessage) {
+			t.Errorf("%d: Notification '%s' did not contain '%s' as expected", i, notifyMessage, c.expectedMessage)
+		}
+
+		if c.expectedMessage == "" && notifyMessage != "" {
+			t.Errorf("%d: Got unexpected notification: %s", i, notifyMessage)
+		}
+	}
+}
+
+func TestGotEvaluationUnreachable(t *testing.T) {
+	db := boltdb.NewTestStore()
+
+	contact := &Contact{Name: "testcontact", Notifier: "mockn"}
+	db.Save(contact)
+
+	group := &ContactGroup{Name: "testgroup", Members: []string{contact.GetID()}}
+	db.Save(group)
+
+	check := &checks.Check{
+		Name:          "unreachable",
+		AgentID:       "mock",
+		ContactGroups: []string{group.GetID()},
+	}
+	db.Save(check)
+
+	n, _ := NewNotifier(db)
+
+	timeline := []struct {
+		state           eval.State
+		expectedMessage string
+	}{
+		{eval.StateUp, ""},
+		{eval.StateUnreachable, ""},
+		{eval.StateUp, ""},
+		{eval.StateUnreachable, ""},
+		{eval.StateDown, "Down"},
+	}
+
+	for i, c := range timeline {
+		e := &eval.Evaluation{
+			CheckID:     check.GetID(),
+			CheckHostID: checks.CheckHostID(check.GetID(), ""),
+			State:       c.state,
+		}
+
+		notifyMessage = ""
+		n.gotEvaluation(e)
+
+		if c.expectedMessage != "" && !strings.Contains(notifyMessage, c.expectedMessage) {
+			t.Errorf("%d: Notification '%s' did not contain '%s' as expected", i, notifyMessage, c.expectedMessage)
+		}
+
+		if c.expectedMessage == "" && notifyMessage != "" {
+			t.Errorf("%d: Got unexpected notification: %s", i, notifyMessage)
+		}
+	}
+}
+
+func TestGotEvaluationMaintenance(t *testing.T) {
+	db := boltdb.NewTestStore()
+
+	contact := &Contact{Name: "testcontact", Notifier: "mockn"}
+	db.Save(contact)
+
+	group := &ContactGroup{Name: "testgroup", Members: []string{contact.GetID()}}
+	db.Save(group)
+
+	check := &checks.Check{
+		Name:          "maintenance",
+		AgentID:       "mock",
+		ContactGroups: []string{group.GetID()},
+	}
+	db.Save(check)
+
+	n, _ := NewNotifier(db)
+
+	timeline := []struct {
+		state           eval.State
+		maintenance     bool
+		expectedMessage string
+	}{
+		{eval.StateUp, false, ""},
+		{eval.StateDown, true, ""},
+		{eval.StateUp, true, ""},
+		{eval.StateUp, false, ""},
+		{eval.StateDown, true, ""},
+		{eval.StateDown, true, ""},
+		{eval.StateDown, false, "still Down after maintenance"},
+		{eval.StateDown, false, ""},
+		{eval.StateUp, false, "Up"},
+	}
+
+	for i, c := range timeline {
+		e := &eval.Evaluation{
+			CheckID:     check.GetID(),
+			CheckHostID: checks.CheckHostID(check.GetID(), ""),
+			State:       c.state,
+			Maintenance: c.maintenance,
+		}
+
+		notifyMessage = ""
+		n.gotEvaluation(e)
+
+		if c.expectedMessage != "" && !strings.Contains(notifyMessage, c.expectedMessage) {
+			t.Errorf("%d: Notification '%s' did not contain '%s' as expected", i, notifyMessage, c.expectedMessage)
+		}
+
+		if c.expectedMessage == "" && notifyMessage != "" {
+			t.Errorf("%d: Got unexpected notification: %s", i, notifyMessage)
+		}
+	}
+}
+
+func TestGotEvaluationSeverity(t *testing.T) {
+	db := boltdb.NewTestStore()
+
+	contact := &Contact{Name: "testcontact", Notifier: "mockn"}
+	db.Save(contact)
+
+	pager := &ContactGroup{
+		Name:    "pager",
+		Members: []string{contact.GetID()},
+		States:  []eval.State{eval.StateCritical, eval.StateDown},
+	}
+	db.Save(pager)
+
+	check := &checks.Check{
+		Name:          "severity",
+		AgentID:       "mock",
+		ContactGroups: []string{pager.GetID()},
+	}
+	db.Save(check)
+
+	n, _ := NewNotifier(db)
+
+	timeline := []struct {
+		state           eval.State
+		expectedMessage string
+	}{
+		{eval.StateUp, ""},
+		{eval.StateWarning, ""},
+		{eval.StateCritical, "Critical"},
+		{eval.StateWarning, "Warning"},
+		{eval.StateUp, ""},
+		{eval.StateDown, "Down"},
+		{eval.StateUp, "Up"},
+	}
+
+	for i, c := range timeline {
+		e := &eval.Evaluation{
+			CheckID:     check.GetID(),
+			CheckHostID: checks.CheckHostID(check.GetID(), ""),
+			State:       c.state,
+		}
+
+		notifyMessage = ""
+		n.gotEvaluation(e)
+
+		if c.expectedMessage != "" && !strings.Contains(notifyMessage, c.expectedMessage) {
+			t.Errorf("%d: Notification '%s' did not contain '%s' as expected", i, notifyMessage, c.expectedMessage)
+		}
+
+		if c.expectedMessage == "" && notifyMessage != "" {
+			t.Errorf("%d: Got unexpected notification: %s", i, notifyMessage)
+		}
+	}
+}
+
+func TestGotEvaluationFlapping(t *testing.T) {
+	db := boltdb.NewTestStore()
+
+	contact := &Contact{Name: "testcontact", Notifier: "mockn"}
+	db.Save(contact)
+
+	group := &ContactGroup{Name: "testgroup", Members: []string{contact.GetID()}}
+	db.Save(group)
+
+	check := &checks.Check{
+		Name:          "flapping",
+		AgentID:       "mock",
+		ContactGroups: []string{group.GetID()},
+	}
+	db.Save(check)
+
+	n, _ := NewNotifier(db)
+
+	timeline := []struct {
+		state           eval.State
+		flapping        bool
+		expectedMessage string
+	}{
+		{eval.StateUp, false, ""},
+		{eval.StateDown, false, "is Down"},
+		{eval.StateUp, true, "started flapping"},
+		{eval.StateDown, true, ""},
+		{eval.StateUp, true, ""},
+		{eval.StateDown, false, "stopped flapping and is Down"},
+		{eval.StateDown, false, ""},
+		{eval.StateUp, false, "is Up"},
+	}
+
+	for i, c := range timeline {
+		e := &eval.Evaluation{
+			CheckID:     check.GetID(),
+			CheckHostID: checks.CheckHostID(check.GetID(), ""),
+			State:       c.state,
+			Flapping:    c.flapping,
+		}
+
+		notifyMessage = ""
+		n.gotEvaluation(e)
+
+		if c.expectedMessage != "" && !strings.Contains(notifyMessage, c.expectedMessage) {
+			t.Errorf("%d: Notification '%s' did not contain '%s' as expected", i, notifyMessage, c.expectedMessage)
+		}
+
+		if c.expectedMessage == "" && notifyMessage != "" {
+			t.Errorf("%d: Got unexpected notification: %s", i, notifyMessage)
+		}
+	}
+}
+
+func TestGotEvaluationUnknown(t *testing.T) {
+	db := boltdb.NewTestStore()
+
+	contact := &Contact{Name: "testcontact", Notifier: "mockn"}
+	db.Save(contact)
+
+	group := &ContactGroup{Name: "testgroup", Members: []string{contact.GetID()}}
+	db.Save(group)
+
+	check := &checks.Check{
+		Name:          "unknown",
+		AgentID:       "mock",
+		ContactGroups: []string{group.GetID()},
+	}
+	db.Save(check)
+
+	n, _ := NewNotifier(db)
+
+	// Before the first real state, unknown means "not evaluated yet". After
+	// that, a plugin reporting unknown is a change worth telling about.
+	timeline := []struct {
+		state           eval.State
+		expectedMessage string
+	}{
+		{eval.StateUnknown, ""},
+		{eval.StateUp, ""},
+		{eval.StateUnknown, "Unknown"},
+		{eval.StateUnknown, ""},
+		{eval.StateDown, "Down"},
+		{eval.StateUp, "Up"},
+	}
+
+	for i, c := range timeline {
+		e := &eval.Evaluation{
+			CheckID:     check.GetID(),
+			CheckHostID: checks.CheckHostID(check.GetID(), ""),
+			State:       c.state,
+		}
+
+		notifyMessage = ""
+		n.gotEvaluation(e)
+
+		if c.expectedMessage != "" && !strings.Contains(notifyMessage, c.expectedMessage) {
+			t.Errorf("%d: Notification '%s' did not contain '%s' as expected", i, notifyMessage, c.expectedMessage)
+		}
+
+		if c.expectedMessage == "" && notifyMessage != "" {
+			t.Errorf("%d: Got unexpected notification: %s", i, notifyMessage)
+		}
+	}
+}
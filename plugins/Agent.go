@@ -55,11 +55,13 @@ func ListAgents() []AgentDescription {
 	list := make([]AgentDescription, 0, len(agents))
 
 	for name, typ := range agents {
-		_, remote := reflect.New(typ).Interface().(RemoteAgent)
+		agent := reflect.New(typ).Interface()
+		_, remote := agent.(RemoteAgent)
+		_, contextRemote := agent.(ContextRemoteAgent)
 
 		list = append(list, AgentDescription{
 			Name:      name,
-			Remote:    remote,
+			Remote:    remote || contextRemote,
 			Arguments: getArguments(typ),
 		})
 	}
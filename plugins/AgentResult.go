@@ -1,6 +1,9 @@
 package plugins
 
-import "unicode"
+import (
+	"strings"
+	"unicode"
+)
 
 type (
 	// AgentResult describes the result from an agent.
@@ -22,3 +25,63 @@ func (a AgentResult) AddValue(key string, value interface{}) {
 func ValidateResultKeyRune(r rune) bool {
 	return unicode.IsLetter(r) || unicode.IsNumber(r) || r == '_'
 }
+
+// SanitizeResultKey will replace all runes not allowed in a AgentResult key
+// with underscores.
+func SanitizeResultKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		if ValidateResultKeyRune(r) {
+			return r
+		}
+
+		return '_'
+	}, key)
+}
+
+// Numeric returns all numeric values from a as float64. Other values are
+// left out.
+func (a AgentResult) Numeric() map[string]float64 {
+	values := make(map[string]float64)
+
+	for key, value := range a {
+		f, ok := ToFloat(value)
+		if ok {
+			values[key] = f
+		}
+	}
+
+	return values
+}
+
+// ToFloat will convert a numeric value to float64. If value is not numeric,
+// false is returned.
+func ToFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+
+	return 0, false
+}
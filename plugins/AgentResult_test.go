@@ -48,3 +48,38 @@ func TestValidateResultKeyRune(t *testing.T) {
 		}
 	}
 }
+
+func TestResultNumeric(t *testing.T) {
+	r := NewAgentResult()
+	r.AddValue("int", 1)
+	r.AddValue("uint8", uint8(2))
+	r.AddValue("float", 3.5)
+	r.AddValue("string", "4")
+	r.AddValue("bool", true)
+
+	values := r.Numeric()
+	if len(values) != 3 {
+		t.Fatalf("Numeric() returned %d values, expected 3", len(values))
+	}
+
+	if values["int"] != 1 || values["uint8"] != 2 || values["float"] != 3.5 {
+		t.Fatalf("Numeric() returned wrong values: %v", values)
+	}
+}
+
+func TestSanitizeResultKey(t *testing.T) {
+	cases := map[string]string{
+		"load1":         "load1",
+		"/var":          "_var",
+		"http.requests": "http_requests",
+		"Æble grød":     "Æble_grød",
+		"":              "",
+	}
+
+	for in, expected := range cases {
+		result := SanitizeResultKey(in)
+		if result != expected {
+			t.Errorf("SanitizeResultKey(%s) returned %s, expected %s", in, result, expected)
+		}
+	}
+}
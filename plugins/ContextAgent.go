@@ -0,0 +1,115 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gansoi/gansoi/transports"
+)
+
+type (
+	// ContextAgent should be implemented by agents supporting cancellation.
+	// The agent should abort as soon as possible when ctx is done.
+	ContextAgent interface {
+		// CheckContext should run the agents check.
+		CheckContext(ctx context.Context, result AgentResult) error
+	}
+
+	// ContextRemoteAgent should be implemented by remote agents supporting
+	// cancellation.
+	ContextRemoteAgent interface {
+		RemoteCheckContext(ctx context.Context, transport transports.Transport, result AgentResult) error
+	}
+
+	// agentAdapter adapts an Agent to a ContextAgent.
+	agentAdapter struct {
+		agent Agent
+	}
+
+	// remoteAgentAdapter adapts a RemoteAgent to a ContextRemoteAgent.
+	remoteAgentAdapter struct {
+		agent RemoteAgent
+	}
+)
+
+// AdaptAgent will return agent as a ContextAgent. Agents only implementing
+// Agent will be wrapped in an adapter. If agent implements neither, nil is
+// returned.
+func AdaptAgent(agent interface{}) ContextAgent {
+	switch a := agent.(type) {
+	case ContextAgent:
+		return a
+	case Agent:
+		return &agentAdapter{agent: a}
+	}
+
+	return nil
+}
+
+// AdaptRemoteAgent will return agent as a ContextRemoteAgent. Agents only
+// implementing RemoteAgent will be wrapped in an adapter. If agent implements
+// neither, nil is returned.
+func AdaptRemoteAgent(agent interface{}) ContextRemoteAgent {
+	switch a := agent.(type) {
+	case ContextRemoteAgent:
+		return a
+	case RemoteAgent:
+		return &remoteAgentAdapter{agent: a}
+	}
+
+	return nil
+}
+
+// CheckContext implements ContextAgent. The legacy agent cannot be cancelled,
+// but we will stop waiting for it when ctx is done.
+func (a *agentAdapter) CheckContext(ctx context.Context, result AgentResult) error {
+	return adapt(ctx, result, a.agent.Check)
+}
+
+// RemoteCheckContext implements ContextRemoteAgent. The legacy agent cannot be
+// cancelled, but we will stop waiting for it when ctx is done.
+func (a *remoteAgentAdapter) RemoteCheckContext(ctx context.Context, transport transports.Transport, result AgentResult) error {
+	return adapt(ctx, result, func(r AgentResult) error {
+		return a.agent.RemoteCheck(transport, r)
+	})
+}
+
+// adapt will run check in its own goroutine and wait for it to return or for
+// ctx to be done - whatever comes first. The check gets its own AgentResult,
+// an abandoned check can keep running without touching result.
+func adapt(ctx context.Context, result AgentResult, check func(AgentResult) error) error {
+	type checkReturn struct {
+		result AgentResult
+		err    error
+	}
+
+	done := make(chan checkReturn, 1)
+
+	go func() {
+		r := NewAgentResult()
+
+		defer func() {
+			// A panic in this goroutine would take everything down. Pass it
+			// on as an error instead.
+			if err := recover(); err != nil {
+				done <- checkReturn{err: fmt.Errorf("%s", err)}
+			}
+		}()
+
+		err := check(r)
+
+		done <- checkReturn{result: r, err: err}
+	}()
+
+	select {
+	case ret := <-done:
+		for key, value := range ret.result {
+			result.AddValue(key, value)
+		}
+
+		return ret.err
+
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
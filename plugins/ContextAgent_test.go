@@ -0,0 +1,113 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gansoi/gansoi/transports"
+)
+
+type (
+	slowAgent struct {
+		delay time.Duration
+		panic bool
+	}
+
+	slowRemoteAgent struct {
+		slowAgent
+	}
+
+	contextAgent struct{}
+)
+
+func (a *slowAgent) Check(result AgentResult) error {
+	time.Sleep(a.delay)
+
+	if a.panic {
+		panic("panic")
+	}
+
+	result.AddValue("ran", true)
+
+	return nil
+}
+
+func (a *slowRemoteAgent) RemoteCheck(_ transports.Transport, result AgentResult) error {
+	return a.Check(result)
+}
+
+func (a *contextAgent) CheckContext(ctx context.Context, result AgentResult) error {
+	return nil
+}
+
+func TestAdaptAgent(t *testing.T) {
+	if AdaptAgent(nil) != nil {
+		t.Fatalf("AdaptAgent() adapted nil")
+	}
+
+	c := &contextAgent{}
+	if AdaptAgent(c) != c {
+		t.Fatalf("AdaptAgent() did not return ContextAgent as is")
+	}
+
+	a := AdaptAgent(&slowAgent{})
+	result := NewAgentResult()
+
+	err := a.CheckContext(context.Background(), result)
+	if err != nil {
+		t.Fatalf("CheckContext() returned an error: %s", err.Error())
+	}
+
+	if result["ran"] != true {
+		t.Fatalf("CheckContext() did not run the check")
+	}
+}
+
+func TestAdaptAgentTimeout(t *testing.T) {
+	a := AdaptAgent(&slowAgent{delay: time.Second})
+	result := NewAgentResult()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+
+	err := a.CheckContext(ctx, result)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("CheckContext() did not time out, got %v", err)
+	}
+
+	if len(result) != 0 {
+		t.Fatalf("CheckContext() returned results from an abandoned check")
+	}
+}
+
+func TestAdaptAgentPanic(t *testing.T) {
+	a := AdaptAgent(&slowAgent{panic: true})
+
+	err := a.CheckContext(context.Background(), NewAgentResult())
+	if err == nil {
+		t.Fatalf("CheckContext() did not convert panic to error")
+	}
+}
+
+func TestAdaptRemoteAgent(t *testing.T) {
+	if AdaptRemoteAgent(&slowAgent{}) != nil {
+		t.Fatalf("AdaptRemoteAgent() adapted a local agent")
+	}
+
+	a := AdaptRemoteAgent(&slowRemoteAgent{})
+	result := NewAgentResult()
+
+	err := a.RemoteCheckContext(context.Background(), nil, result)
+	if err != nil {
+		t.Fatalf("RemoteCheckContext() returned an error: %s", err.Error())
+	}
+
+	if result["ran"] != true {
+		t.Fatalf("RemoteCheckContext() did not run the check")
+	}
+}
+
+var _ ContextAgent = (*agentAdapter)(nil)
+var _ ContextRemoteAgent = (*remoteAgentAdapter)(nil)
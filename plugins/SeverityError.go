@@ -0,0 +1,24 @@
+package plugins
+
+type (
+	// SeverityError can be returned by agents to fail with a severity other
+	// than down. Severity should be one of "warning", "critical" or
+	// "unknown".
+	SeverityError struct {
+		Severity string
+		Message  string
+	}
+)
+
+// NewSeverityError will instantiate a new SeverityError.
+func NewSeverityError(severity string, message string) *SeverityError {
+	return &SeverityError{
+		Severity: severity,
+		Message:  message,
+	}
+}
+
+// Error implements error.
+func (e *SeverityError) Error() string {
+	return e.Message
+}
@@ -0,0 +1,27 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// Make sure we implement the needed interfaces.
+var _ error = (*SeverityError)(nil)
+
+func TestSeverityError(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", NewSeverityError("warning", "disk almost full"))
+
+	var severityErr *SeverityError
+	if !errors.As(err, &severityErr) {
+		t.Fatalf("errors.As() failed to find SeverityError")
+	}
+
+	if severityErr.Severity != "warning" {
+		t.Fatalf("Wrong severity '%s'", severityErr.Severity)
+	}
+
+	if severityErr.Error() != "disk almost full" {
+		t.Fatalf("Wrong message '%s'", severityErr.Error())
+	}
+}
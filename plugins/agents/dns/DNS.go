@@ -0,0 +1,524 @@
+package dns
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/gansoi/gansoi/plugins"
+)
+
+type (
+	// DNS will query one or more DNS servers for a single name and record
+	// type.
+	DNS struct {
+		Server    string `json:"server" description:"The server to query (192.0.2.53 or ns1.example.com:53)"`
+		Servers   string `json:"servers" description:"More servers to query and compare, separated by whitespace (ns2.example.com ns3.example.com)"`
+		Name      string `json:"name" description:"The name to look up (example.com)"`
+		Type      string `json:"type" description:"Record type" enum:"A,AAAA,CNAME,MX,TXT,SRV,SOA" default:"A"`
+		Protocol  string `json:"protocol" description:"Protocol used for the query" enum:"udp,tcp" default:"udp"`
+		Recursion bool   `json:"recursion" description:"Ask the server to recurse" default:"true"`
+	}
+
+	// answer is a single formatted answer.
+	answer struct {
+		value string
+		ttl   uint32
+	}
+
+	// reply is the outcome of querying a single server.
+	reply struct {
+		response *dnsmessage.Message
+		duration time.Duration
+		err      error
+	}
+)
+
+const (
+	defaultPort    = "53"
+	defaultTimeout = 5 * time.Second
+
+	// maxUDPSize is the largest UDP response we will read.
+	maxUDPSize = 65535
+
+	// udpTries is the number of times a UDP query is sent before giving up.
+	udpTries = 3
+)
+
+var (
+	types = map[string]dnsmessage.Type{
+		"A":     dnsmessage.TypeA,
+		"AAAA":  dnsmessage.TypeAAAA,
+		"CNAME": dnsmessage.TypeCNAME,
+		"MX":    dnsmessage.TypeMX,
+		"TXT":   dnsmessage.TypeTXT,
+		"SRV":   dnsmessage.TypeSRV,
+		"SOA":   dnsmessage.TypeSOA,
+	}
+
+	rcodes = map[dnsmessage.RCode]string{
+		dnsmessage.RCodeSuccess:        "NOERROR",
+		dnsmessage.RCodeFormatError:    "FORMERR",
+		dnsmessage.RCodeServerFailure:  "SERVFAIL",
+		dnsmessage.RCodeNameError:      "NXDOMAIN",
+		dnsmessage.RCodeNotImplemented: "NOTIMP",
+		dnsmessage.RCodeRefused:        "REFUSED",
+	}
+
+	// ErrMismatch will be returned if a response does not match the query.
+	ErrMismatch = errors.New("response does not match query")
+
+	// retransmitTimeout is how long we wait for a UDP response before
+	// sending the query again.
+	retransmitTimeout = 2 * time.Second
+)
+
+func init() {
+	plugins.RegisterAgent("dns", DNS{})
+}
+
+// Check implements plugins.Agent.
+func (d *DNS) Check(result plugins.AgentResult) error {
+	return d.CheckContext(context.Background(), result)
+}
+
+// CheckContext implements plugins.ContextAgent.
+//
+// Answers of the requested type are added sorted as Answer0, Answer1, ...
+// with the TTL in TTL0, TTL1, ... Answers holds all answers separated by
+// spaces. If the response includes a SOA record, the serial is added as
+// Serial.
+//
+// If more servers are given, all servers are queried and the above is added
+// for the first server. For each server, the address, response code, answers
+// and serial are added as Server0, Rcode0, Answers0, Serial0, Server1, ...
+// SerialsMatch is true if all servers agree on the serial, and AnswersMatch
+// is true if all servers agree on the answers.
+func (d *DNS) CheckContext(ctx context.Context, result plugins.AgentResult) error {
+	query, err := d.query()
+	if err != nil {
+		return err
+	}
+
+	servers, err := d.servers()
+	if err != nil {
+		return err
+	}
+
+	protocol := d.Protocol
+	if protocol == "" {
+		protocol = "udp"
+	}
+
+	replies := make([]reply, len(servers))
+
+	var wg sync.WaitGroup
+
+	for i, server := range servers {
+		wg.Add(1)
+
+		go func(i int, server string) {
+			defer wg.Done()
+
+			replies[i] = ask(ctx, protocol, server, query)
+		}(i, server)
+	}
+
+	wg.Wait()
+
+	for i, r := range replies {
+		if r.err == nil {
+			continue
+		}
+
+		if len(servers) == 1 {
+			return r.err
+		}
+
+		return fmt.Errorf("%s: %s", servers[i], r.err.Error())
+	}
+
+	typ := query.Questions[0].Type
+
+	result.AddValue("QueryTime", ms(replies[0].duration))
+
+	addResponse(typ, replies[0].response, result)
+
+	if len(servers) > 1 {
+		addComparison(typ, servers, replies, result)
+	}
+
+	return nil
+}
+
+// servers returns the addresses of all servers to query.
+func (d *DNS) servers() ([]string, error) {
+	fields := strings.FieldsFunc(d.Server+" "+d.Servers, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+
+	if len(fields) == 0 {
+		return nil, errors.New("no server given")
+	}
+
+	servers := make([]string, len(fields))
+
+	for i, server := range fields {
+		_, _, err := net.SplitHostPort(server)
+		if err != nil {
+			server = net.JoinHostPort(server, defaultPort)
+		}
+
+		servers[i] = server
+	}
+
+	return servers, nil
+}
+
+// ask will send query to server.
+func ask(ctx context.Context, protocol string, server string, query *dnsmessage.Message) reply {
+	start := time.Now()
+
+	response, err := exchange(ctx, protocol, server, query)
+
+	// Like most resolvers, retry truncated responses using TCP.
+	if err == nil && response.Header.Truncated && protocol == "udp" {
+		response, err = exchange(ctx, "tcp", server, query)
+	}
+
+	return reply{
+		response: response,
+		duration: time.Since(start),
+		err:      err,
+	}
+}
+
+// query will build the query message.
+func (d *DNS) query() (*dnsmessage.Message, error) {
+	typeName := d.Type
+	if typeName == "" {
+		typeName = "A"
+	}
+
+	typ, found := types[strings.ToUpper(typeName)]
+	if !found {
+		return nil, fmt.Errorf("unsupported record type '%s'", d.Type)
+	}
+
+	if d.Name == "" {
+		return nil, errors.New("no name given")
+	}
+
+	fqdn := d.Name
+	if !strings.HasSuffix(fqdn, ".") {
+		fqdn += "."
+	}
+
+	name, err := dnsmessage.NewName(fqdn)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:               uint16(rand.Intn(65536)),
+			RecursionDesired: d.Recursion,
+		},
+		Questions: []dnsmessage.Question{{
+			Name:  name,
+			Type:  typ,
+			Class: dnsmessage.ClassINET,
+		}},
+	}, nil
+}
+
+// exchange will send query to server and wait for the response.
+func exchange(ctx context.Context, protocol string, server string, query *dnsmessage.Message) (*dnsmessage.Message, error) {
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	var dialer net.Dialer
+
+	conn, err := dialer.DialContext(ctx, protocol, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(defaultTimeout)
+	}
+	conn.SetDeadline(deadline)
+
+	if protocol == "tcp" {
+		return exchangeTCP(conn, packed, query)
+	}
+
+	return exchangeUDP(conn, packed, query, deadline)
+}
+
+// exchangeUDP will send a query over UDP. A lost query or response is
+// retransmitted after retransmitTimeout, up to udpTries times in total. The
+// last try will wait until deadline. Responses not matching the query are
+// ignored, they could be late responses to earlier queries or spoofed.
+func exchangeUDP(conn net.Conn, packed []byte, query *dnsmessage.Message, deadline time.Time) (*dnsmessage.Message, error) {
+	buf := make([]byte, maxUDPSize)
+
+	for try := 1; ; try++ {
+		_, err := conn.Write(packed)
+		if err != nil {
+			return nil, err
+		}
+
+		tryDeadline := time.Now().Add(retransmitTimeout)
+		if try >= udpTries || tryDeadline.After(deadline) {
+			tryDeadline = deadline
+		}
+		conn.SetReadDeadline(tryDeadline)
+
+		response, err := readUDP(conn, buf, query)
+
+		var netErr net.Error
+		if err != nil && errors.As(err, &netErr) && netErr.Timeout() && tryDeadline.Before(deadline) {
+			continue
+		}
+
+		return response, err
+	}
+}
+
+// readUDP will read from conn until a response matching query arrives.
+func readUDP(conn net.Conn, buf []byte, query *dnsmessage.Message) (*dnsmessage.Message, error) {
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+
+		response, err := parseResponse(buf[:n], query)
+		if err == nil {
+			return response, nil
+		}
+	}
+}
+
+// exchangeTCP will send a query over TCP. Messages are prefixed with their
+// length.
+func exchangeTCP(conn net.Conn, packed []byte, query *dnsmessage.Message) (*dnsmessage.Message, error) {
+	buf := make([]byte, 2+len(packed))
+	binary.BigEndian.PutUint16(buf, uint16(len(packed)))
+	copy(buf[2:], packed)
+
+	_, err := conn.Write(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	var length uint16
+
+	err = binary.Read(conn, binary.BigEndian, &length)
+	if err != nil {
+		return nil, err
+	}
+
+	buf = make([]byte, length)
+
+	_, err = io.ReadFull(conn, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseResponse(buf, query)
+}
+
+// parseResponse will parse buf and make sure it's a response to query.
+func parseResponse(buf []byte, query *dnsmessage.Message) (*dnsmessage.Message, error) {
+	var response dnsmessage.Message
+
+	err := response.Unpack(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	if !response.Header.Response || response.Header.ID != query.Header.ID {
+		return nil, ErrMismatch
+	}
+
+	if len(response.Questions) != 1 {
+		return nil, ErrMismatch
+	}
+
+	// Some servers randomize the case of the name to make spoofing harder.
+	q := query.Questions[0]
+	r := response.Questions[0]
+	if r.Type != q.Type || r.Class != q.Class || !strings.EqualFold(r.Name.String(), q.Name.String()) {
+		return nil, ErrMismatch
+	}
+
+	return &response, nil
+}
+
+// addResponse will add the interesting parts of response to result.
+func addResponse(typ dnsmessage.Type, response *dnsmessage.Message, result plugins.AgentResult) {
+	result.AddValue("Rcode", rcode(response))
+	result.AddValue("Authoritative", response.Header.Authoritative)
+	result.AddValue("Truncated", response.Header.Truncated)
+
+	answers := findAnswers(typ, response)
+	values := make([]string, len(answers))
+
+	for i, a := range answers {
+		values[i] = a.value
+
+		result.AddValue(fmt.Sprintf("Answer%d", i), a.value)
+		result.AddValue(fmt.Sprintf("TTL%d", i), a.ttl)
+	}
+
+	result.AddValue("AnswerCount", len(answers))
+	result.AddValue("Answers", strings.Join(values, " "))
+
+	serial, found := findSerial(response)
+	if found {
+		result.AddValue("Serial", serial)
+	}
+}
+
+// addComparison will add the response from each server to result, and
+// whether all servers agree.
+func addComparison(typ dnsmessage.Type, servers []string, replies []reply, result plugins.AgentResult) {
+	serialsMatch := true
+	answersMatch := true
+
+	var firstAnswers string
+	var firstSerial uint32
+
+	for i, r := range replies {
+		answers := findAnswers(typ, r.response)
+		values := make([]string, len(answers))
+
+		for j, a := range answers {
+			values[j] = a.value
+		}
+
+		joined := strings.Join(values, " ")
+
+		result.AddValue(fmt.Sprintf("Server%d", i), servers[i])
+		result.AddValue(fmt.Sprintf("Rcode%d", i), rcode(r.response))
+		result.AddValue(fmt.Sprintf("Answers%d", i), joined)
+
+		serial, found := findSerial(r.response)
+		if found {
+			result.AddValue(fmt.Sprintf("Serial%d", i), serial)
+		}
+
+		// A server without a serial can't agree with anyone.
+		if !found {
+			serialsMatch = false
+		}
+
+		if i == 0 {
+			firstAnswers = joined
+			firstSerial = serial
+
+			continue
+		}
+
+		if serial != firstSerial {
+			serialsMatch = false
+		}
+
+		if joined != firstAnswers {
+			answersMatch = false
+		}
+	}
+
+	result.AddValue("SerialsMatch", serialsMatch)
+	result.AddValue("AnswersMatch", answersMatch)
+}
+
+// rcode returns the name of the response code of response.
+func rcode(response *dnsmessage.Message) string {
+	name, found := rcodes[response.Header.RCode]
+	if !found {
+		name = fmt.Sprintf("RCODE%d", response.Header.RCode)
+	}
+
+	return name
+}
+
+// findAnswers returns the formatted answers of type typ in response sorted by
+// value.
+func findAnswers(typ dnsmessage.Type, response *dnsmessage.Message) []answer {
+	var answers []answer
+
+	for _, rr := range response.Answers {
+		if rr.Header.Type != typ {
+			continue
+		}
+
+		answers = append(answers, answer{format(rr.Body), rr.Header.TTL})
+	}
+
+	sort.Slice(answers, func(i, j int) bool {
+		return answers[i].value < answers[j].value
+	})
+
+	return answers
+}
+
+// findSerial returns the serial of the SOA record in response, if any.
+func findSerial(response *dnsmessage.Message) (uint32, bool) {
+	// Negative responses from authoritative servers carry the SOA in the
+	// authority section.
+	for _, section := range [][]dnsmessage.Resource{response.Answers, response.Authorities} {
+		for _, rr := range section {
+			soa, isSOA := rr.Body.(*dnsmessage.SOAResource)
+			if isSOA {
+				return soa.Serial, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// format will format a resource like dig does.
+func format(body dnsmessage.ResourceBody) string {
+	switch r := body.(type) {
+	case *dnsmessage.AResource:
+		return net.IP(r.A[:]).String()
+	case *dnsmessage.AAAAResource:
+		return net.IP(r.AAAA[:]).String()
+	case *dnsmessage.CNAMEResource:
+		return r.CNAME.String()
+	case *dnsmessage.MXResource:
+		return fmt.Sprintf("%d %s", r.Pref, r.MX.String())
+	case *dnsmessage.TXTResource:
+		return strings.Join(r.TXT, "")
+	case *dnsmessage.SRVResource:
+		return fmt.Sprintf("%d %d %d %s", r.Priority, r.Weight, r.Port, r.Target.String())
+	case *dnsmessage.SOAResource:
+		return fmt.Sprintf("%s %s %d %d %d %d %d", r.NS.String(), r.MBox.String(), r.Serial, r.Refresh, r.Retry, r.Expire, r.MinTTL)
+	}
+
+	return ""
+}
+
+// ms will convert a time.Duration to milliseconds.
+func ms(d time.Duration) int64 {
+	return ((d + time.Millisecond/2) / time.Millisecond).Nanoseconds()
+}
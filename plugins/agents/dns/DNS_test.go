@@ -0,0 +1,426 @@
+package dns
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/gansoi/gansoi/plugins"
+)
+
+type (
+	testServer struct {
+		udp net.PacketConn
+		tcp net.Listener
+	}
+)
+
+func name(s string) dnsmessage.Name {
+	return dnsmessage.MustNewName(s)
+}
+
+func rr(n string, typ dnsmessage.Type, ttl uint32, body dnsmessage.ResourceBody) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{
+			Name:  name(n),
+			Type:  typ,
+			Class: dnsmessage.ClassINET,
+			TTL:   ttl,
+		},
+		Body: body,
+	}
+}
+
+var (
+	soa = &dnsmessage.SOAResource{
+		NS:      name("ns1.example.com."),
+		MBox:    name("hostmaster.example.com."),
+		Serial:  2020010101,
+		Refresh: 3600,
+		Retry:   600,
+		Expire:  86400,
+		MinTTL:  300,
+	}
+
+	zone = map[string][]dnsmessage.Resource{
+		"example.com.": {
+			rr("example.com.", dnsmessage.TypeA, 300, &dnsmessage.AResource{A: [4]byte{192, 0, 2, 2}}),
+			rr("example.com.", dnsmessage.TypeA, 60, &dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}}),
+			rr("example.com.", dnsmessage.TypeAAAA, 300, &dnsmessage.AAAAResource{AAAA: [16]byte{0x20, 0x01, 0x0d, 0xb8, 15: 1}}),
+			rr("example.com.", dnsmessage.TypeMX, 300, &dnsmessage.MXResource{Pref: 10, MX: name("mail.example.com.")}),
+			rr("example.com.", dnsmessage.TypeTXT, 300, &dnsmessage.TXTResource{TXT: []string{"v=spf1 ", "-all"}}),
+			rr("example.com.", dnsmessage.TypeSOA, 300, soa),
+		},
+		"www.example.com.": {
+			rr("www.example.com.", dnsmessage.TypeCNAME, 300, &dnsmessage.CNAMEResource{CNAME: name("example.com.")}),
+		},
+		"_sip._tcp.example.com.": {
+			rr("_sip._tcp.example.com.", dnsmessage.TypeSRV, 300, &dnsmessage.SRVResource{Priority: 10, Weight: 5, Port: 5060, Target: name("sip.example.com.")}),
+		},
+	}
+)
+
+// respond will build a response for query. big.example.com is
+// truncated over UDP.
+func respond(query []byte, udp bool) []byte {
+	var msg dnsmessage.Message
+	if msg.Unpack(query) != nil || len(msg.Questions) != 1 {
+		return nil
+	}
+
+	q := msg.Questions[0]
+
+	msg.Header.Response = true
+	msg.Header.Authoritative = true
+
+	switch {
+	case q.Name.String() == "big.example.com." && udp:
+		msg.Header.Truncated = true
+
+	case q.Name.String() == "big.example.com.":
+		msg.Answers = append(msg.Answers, rr("big.example.com.", dnsmessage.TypeA, 300, &dnsmessage.AResource{A: [4]byte{192, 0, 2, 3}}))
+
+	case q.Name.String() == "refused.example.com.":
+		msg.Header.RCode = dnsmessage.RCodeRefused
+
+	case zone[q.Name.String()] == nil:
+		msg.Header.RCode = dnsmessage.RCodeNameError
+		msg.Authorities = append(msg.Authorities, rr("example.com.", dnsmessage.TypeSOA, 300, soa))
+
+	default:
+		for _, r := range zone[q.Name.String()] {
+			if r.Header.Type == q.Type || r.Header.Type == dnsmessage.TypeCNAME {
+				msg.Answers = append(msg.Answers, r)
+			}
+		}
+	}
+
+	packed, _ := msg.Pack()
+
+	return packed
+}
+
+func newTestServer(t *testing.T) *testServer {
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket() failed: %s", err.Error())
+	}
+
+	tcp, err := net.Listen("tcp", udp.LocalAddr().String())
+	if err != nil {
+		tcp, err = net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Listen() failed: %s", err.Error())
+		}
+	}
+
+	s := &testServer{udp: udp, tcp: tcp}
+
+	t.Cleanup(func() {
+		udp.Close()
+		tcp.Close()
+	})
+
+	go func() {
+		buf := make([]byte, 512)
+
+		for {
+			n, addr, err := udp.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			// Send garbage first to make sure it's ignored.
+			udp.WriteTo([]byte("garbage"), addr)
+			udp.WriteTo(respond(buf[:n], true), addr)
+		}
+	}()
+
+	go func() {
+		for {
+			conn, err := tcp.Accept()
+			if err != nil {
+				return
+			}
+
+			var length uint16
+			binary.Read(conn, binary.BigEndian, &length)
+
+			buf := make([]byte, length)
+			io.ReadFull(conn, buf)
+
+			response := respond(buf, false)
+			binary.Write(conn, binary.BigEndian, uint16(len(response)))
+			conn.Write(response)
+			conn.Close()
+		}
+	}()
+
+	return s
+}
+
+func TestCheck(t *testing.T) {
+	s := newTestServer(t)
+
+	cases := []struct {
+		protocol string
+		name     string
+		typ      string
+		expected map[string]interface{}
+	}{
+		{"udp", "example.com", "A", map[string]interface{}{
+			"Rcode":         "NOERROR",
+			"Authoritative": true,
+			"AnswerCount":   2,
+			"Answers":       "192.0.2.1 192.0.2.2",
+			"Answer0":       "192.0.2.1",
+			"TTL0":          uint32(60),
+			"Answer1":       "192.0.2.2",
+			"TTL1":          uint32(300),
+		}},
+		{"tcp", "example.com.", "AAAA", map[string]interface{}{"Answers": "2001:db8::1"}},
+		{"udp", "www.example.com", "CNAME", map[string]interface{}{"Answers": "example.com."}},
+		{"udp", "www.example.com", "A", map[string]interface{}{"AnswerCount": 0}},
+		{"udp", "example.com", "mx", map[string]interface{}{"Answers": "10 mail.example.com."}},
+		{"udp", "example.com", "TXT", map[string]interface{}{"Answers": "v=spf1 -all"}},
+		{"udp", "_sip._tcp.example.com", "SRV", map[string]interface{}{"Answers": "10 5 5060 sip.example.com."}},
+		{"tcp", "example.com", "SOA", map[string]interface{}{
+			"Answers": "ns1.example.com. hostmaster.example.com. 2020010101 3600 600 86400 300",
+			"Serial":  uint32(2020010101),
+		}},
+		{"udp", "missing.example.com", "A", map[string]interface{}{
+			"Rcode":       "NXDOMAIN",
+			"AnswerCount": 0,
+			"Serial":      uint32(2020010101),
+		}},
+		{"udp", "refused.example.com", "A", map[string]interface{}{"Rcode": "REFUSED"}},
+		{"udp", "big.example.com", "A", map[string]interface{}{"Answers": "192.0.2.3", "Truncated": false}},
+	}
+
+	for _, c := range cases {
+		d := DNS{
+			Server:   s.udp.LocalAddr().String(),
+			Name:     c.name,
+			Type:     c.typ,
+			Protocol: c.protocol,
+		}
+
+		if c.protocol == "tcp" {
+			d.Server = s.tcp.Addr().String()
+		}
+
+		// Truncated responses are retried using TCP on the same port.
+		if c.name == "big.example.com" && s.tcp.Addr().String() != s.udp.LocalAddr().String() {
+			continue
+		}
+
+		result := plugins.NewAgentResult()
+		err := d.Check(result)
+		if err != nil {
+			t.Fatalf("%s %s: Check() failed: %s", c.name, c.typ, err.Error())
+		}
+
+		for key, value := range c.expected {
+			if result[key] != value {
+				t.Errorf("%s %s: %s is %v (%T), expected %v (%T)", c.name, c.typ, key, result[key], result[key], value, value)
+			}
+		}
+
+		if _, found := result["QueryTime"]; !found {
+			t.Errorf("%s %s: QueryTime not added", c.name, c.typ)
+		}
+	}
+}
+
+func TestCheckFail(t *testing.T) {
+	s := newTestServer(t)
+
+	cases := []DNS{
+		{Server: s.udp.LocalAddr().String(), Name: "example.com", Type: "PTR"},
+		{Server: s.udp.LocalAddr().String(), Name: "example..com", Type: "A"},
+		{Name: "example.com", Type: "A"},
+		{Server: s.udp.LocalAddr().String(), Type: "A"},
+		{Server: s.udp.LocalAddr().String(), Name: "example.com", Type: "A", Protocol: "sctp"},
+	}
+
+	for i, d := range cases {
+		err := d.Check(plugins.NewAgentResult())
+		if err == nil {
+			t.Errorf("%d: Check() did not fail", i)
+		}
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	// Nobody will answer on this socket.
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket() failed: %s", err.Error())
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	d := DNS{Server: conn.LocalAddr().String(), Name: "example.com"}
+	err = d.CheckContext(ctx, plugins.NewAgentResult())
+	if err == nil {
+		t.Fatalf("CheckContext() did not time out")
+	}
+}
+
+func TestDefaultPort(t *testing.T) {
+	// There's nothing listening on port 53 here, we just want to make sure
+	// a server without port is accepted.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	for _, server := range []string{"127.0.0.1", "::1"} {
+		d := DNS{Server: server, Name: "example.com"}
+		err := d.CheckContext(ctx, plugins.NewAgentResult())
+		if err == nil {
+			continue
+		}
+
+		if addrErr, ok := err.(*net.AddrError); ok {
+			t.Errorf("%s: Address not accepted: %s", server, addrErr.Error())
+		}
+	}
+}
+
+func TestCheckRetransmit(t *testing.T) {
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket() failed: %s", err.Error())
+	}
+	defer udp.Close()
+
+	// The first query is dropped, as if lost on the way.
+	go func() {
+		buf := make([]byte, 512)
+
+		for i := 0; ; i++ {
+			n, addr, err := udp.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			if i > 0 {
+				udp.WriteTo(respond(buf[:n], true), addr)
+			}
+		}
+	}()
+
+	defer func(timeout time.Duration) {
+		retransmitTimeout = timeout
+	}(retransmitTimeout)
+	retransmitTimeout = 50 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	d := DNS{Server: udp.LocalAddr().String(), Name: "example.com"}
+	result := plugins.NewAgentResult()
+
+	err = d.CheckContext(ctx, result)
+	if err != nil {
+		t.Fatalf("CheckContext() failed: %s", err.Error())
+	}
+
+	if result["AnswerCount"] != 2 {
+		t.Fatalf("CheckContext() returned wrong result: %v", result)
+	}
+}
+
+// newSerialServer will start a UDP server answering all queries with a SOA
+// record with serial.
+func newSerialServer(t *testing.T, serial uint32) string {
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket() failed: %s", err.Error())
+	}
+
+	t.Cleanup(func() { udp.Close() })
+
+	record := *soa
+	record.Serial = serial
+
+	go func() {
+		buf := make([]byte, 512)
+
+		for {
+			n, addr, err := udp.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			var msg dnsmessage.Message
+			if msg.Unpack(buf[:n]) != nil || len(msg.Questions) != 1 {
+				continue
+			}
+
+			msg.Header.Response = true
+			msg.Header.Authoritative = true
+			msg.Answers = []dnsmessage.Resource{rr(msg.Questions[0].Name.String(), dnsmessage.TypeSOA, 300, &record)}
+
+			packed, _ := msg.Pack()
+			udp.WriteTo(packed, addr)
+		}
+	}()
+
+	return udp.LocalAddr().String()
+}
+
+func TestCheckServers(t *testing.T) {
+	ns1 := newSerialServer(t, 2020010101)
+	ns2 := newSerialServer(t, 2020010101)
+	ns3 := newSerialServer(t, 2020010102)
+
+	cases := []struct {
+		servers string
+		match   bool
+	}{
+		{ns2, true},
+		{ns2 + ", " + ns3, false},
+		{ns3, false},
+	}
+
+	for i, c := range cases {
+		d := DNS{Server: ns1, Servers: c.servers, Name: "example.com", Type: "SOA"}
+		result := plugins.NewAgentResult()
+
+		err := d.Check(result)
+		if err != nil {
+			t.Fatalf("%d: Check() failed: %s", i, err.Error())
+		}
+
+		if result["SerialsMatch"] != c.match || result["AnswersMatch"] != c.match {
+			t.Errorf("%d: Check() did not compare serials, got %v", i, result)
+		}
+
+		if result["Serial"] != uint32(2020010101) || result["Server0"] != ns1 || result["Serial1"] == nil {
+			t.Errorf("%d: Check() returned wrong result: %v", i, result)
+		}
+	}
+
+	// All servers must answer.
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket() failed: %s", err.Error())
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	d := DNS{Server: ns1, Servers: conn.LocalAddr().String(), Name: "example.com", Type: "SOA"}
+	err = d.CheckContext(ctx, plugins.NewAgentResult())
+	if err == nil {
+		t.Fatalf("CheckContext() did not fail when a server did not answer")
+	}
+}
@@ -0,0 +1,134 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/gansoi/gansoi/plugins"
+	"github.com/gansoi/gansoi/transports"
+	"github.com/gansoi/gansoi/transports/local"
+)
+
+type (
+	// Exec will execute a command and parse its output into the result.
+	// The exit code, stderr and runtime are recorded as well.
+	Exec struct {
+		Command      string `json:"command" description:"Command to execute (/usr/local/bin/probe)"`
+		Arguments    string `json:"arguments" description:"Arguments as interpreted by a shell (--verbose)"`
+		Format       string `json:"format" description:"Format of stdout" enum:"auto,json,keyvalue,number" default:"auto"`
+		AllowFailure bool   `json:"allowFailure" description:"Do not fail on non-zero exit codes, leave it to expressions" default:"false"`
+	}
+)
+
+const (
+	// FormatAuto will try JSON, a single number and key=value lines in that
+	// order.
+	FormatAuto = "auto"
+
+	// FormatJSON parses stdout as a JSON object.
+	FormatJSON = "json"
+
+	// FormatKeyValue parses stdout as key=value lines.
+	FormatKeyValue = "keyvalue"
+
+	// FormatNumber parses stdout as a single number.
+	FormatNumber = "number"
+)
+
+var (
+	// ErrNoCommand will be returned if no command is configured.
+	ErrNoCommand = errors.New("no command given")
+)
+
+func init() {
+	plugins.RegisterAgent("exec", Exec{})
+}
+
+// commandLine returns the command and its arguments as a single command line.
+func (e *Exec) commandLine() string {
+	if e.Arguments == "" {
+		return e.Command
+	}
+
+	return e.Command + " " + e.Arguments
+}
+
+// Check implements plugins.Agent.
+func (e *Exec) Check(result plugins.AgentResult) error {
+	return e.CheckContext(context.Background(), result)
+}
+
+// CheckContext implements plugins.ContextAgent. The command is executed on
+// the local host and killed when ctx is done.
+func (e *Exec) CheckContext(ctx context.Context, result plugins.AgentResult) error {
+	// The SSH transport executes commands using the remote shell. We do the
+	// same locally to have arguments interpreted alike.
+	return e.run(func() (io.Reader, io.Reader, error) {
+		return (&local.Local{}).ExecContext(ctx, "/bin/sh", "-c", e.commandLine())
+	}, result)
+}
+
+// RemoteCheck implements plugins.RemoteAgent.
+func (e *Exec) RemoteCheck(transport transports.Transport, result plugins.AgentResult) error {
+	return e.RemoteCheckContext(context.Background(), transport, result)
+}
+
+// RemoteCheckContext implements plugins.ContextRemoteAgent.
+func (e *Exec) RemoteCheckContext(ctx context.Context, transport transports.Transport, result plugins.AgentResult) error {
+	return e.run(func() (io.Reader, io.Reader, error) {
+		return transports.ExecContext(ctx, transport, e.commandLine())
+	}, result)
+}
+
+// run will call exec and parse the output.
+func (e *Exec) run(exec func() (io.Reader, io.Reader, error), result plugins.AgentResult) error {
+	if e.Command == "" {
+		return ErrNoCommand
+	}
+
+	start := time.Now()
+	stdout, stderr, execErr := exec()
+	runtime := time.Since(start)
+
+	code, exited := transports.ExitCode(execErr)
+	if !exited {
+		return execErr
+	}
+
+	var output, errOutput []byte
+	if stdout != nil {
+		output, _ = ioutil.ReadAll(stdout)
+	}
+
+	if stderr != nil {
+		errOutput, _ = ioutil.ReadAll(stderr)
+	}
+
+	err := parse(e.Format, output, result)
+
+	// These are added after parsing, the command should not be able to
+	// override them.
+	result.AddValue("ExitCode", code)
+	result.AddValue("Stderr", strings.TrimSpace(string(errOutput)))
+	result.AddValue("Runtime", ms(runtime))
+
+	if err != nil {
+		return err
+	}
+
+	if code != 0 && !e.AllowFailure {
+		return fmt.Errorf("%s exited with code %d", e.Command, code)
+	}
+
+	return nil
+}
+
+// ms will convert a time.Duration to milliseconds.
+func ms(d time.Duration) int64 {
+	return ((d + time.Millisecond/2) / time.Millisecond).Nanoseconds()
+}
@@ -0,0 +1,131 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/gansoi/gansoi/plugins"
+	"github.com/gansoi/gansoi/transports/mock"
+)
+
+type (
+	TransportMock struct {
+		mock.Mock
+		cmd    string
+		stdout string
+		stderr string
+		err    error
+	}
+
+	exitError int
+)
+
+func (m *TransportMock) Exec(cmd string, arguments ...string) (io.Reader, io.Reader, error) {
+	m.cmd = cmd
+
+	return bytes.NewBufferString(m.stdout), bytes.NewBufferString(m.stderr), m.err
+}
+
+func (e exitError) Error() string {
+	return "exit"
+}
+
+func (e exitError) ExitStatus() int {
+	return int(e)
+}
+
+func TestRemoteCheck(t *testing.T) {
+	cases := []struct {
+		exec     Exec
+		mock     TransportMock
+		err      bool
+		exitCode int
+	}{
+		{Exec{}, TransportMock{}, true, 0},
+		{Exec{Command: "probe"}, TransportMock{stdout: "a=1"}, false, 0},
+		{Exec{Command: "probe"}, TransportMock{stdout: "a=1", err: exitError(1)}, true, 1},
+		{Exec{Command: "probe", AllowFailure: true}, TransportMock{stdout: "a=1", stderr: "oops\n", err: exitError(1)}, false, 1},
+		{Exec{Command: "probe", Format: FormatNumber}, TransportMock{stdout: "a=1"}, true, 0},
+		{Exec{Command: "probe"}, TransportMock{err: errors.New("connection refused")}, true, -1},
+	}
+
+	for i, c := range cases {
+		result := plugins.NewAgentResult()
+		err := c.exec.RemoteCheck(&c.mock, result)
+		if c.err != (err != nil) {
+			t.Errorf("%d: RemoteCheck() returned unexpected error: %v", i, err)
+		}
+
+		if c.exitCode < 0 {
+			if result["ExitCode"] != nil {
+				t.Errorf("%d: RemoteCheck() added ExitCode for failed transport", i)
+			}
+
+			continue
+		}
+
+		if c.exec.Command == "" {
+			continue
+		}
+
+		if result["ExitCode"] != c.exitCode {
+			t.Errorf("%d: RemoteCheck() recorded exit code %v, expected %d", i, result["ExitCode"], c.exitCode)
+		}
+
+		if result["Stderr"] != "" && result["Stderr"] != "oops" {
+			t.Errorf("%d: RemoteCheck() recorded wrong stderr '%v'", i, result["Stderr"])
+		}
+
+		if _, found := result["Runtime"]; !found {
+			t.Errorf("%d: RemoteCheck() did not record runtime", i)
+		}
+	}
+}
+
+func TestRemoteCheckCommandLine(t *testing.T) {
+	m := &TransportMock{}
+
+	e := Exec{Command: "probe", Arguments: "--verbose 'a b'"}
+	e.RemoteCheck(m, plugins.NewAgentResult())
+	if m.cmd != "probe --verbose 'a b'" {
+		t.Fatalf("RemoteCheck() executed '%s'", m.cmd)
+	}
+
+	e = Exec{Command: "probe"}
+	e.RemoteCheck(m, plugins.NewAgentResult())
+	if m.cmd != "probe" {
+		t.Fatalf("RemoteCheck() executed '%s'", m.cmd)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	e := Exec{Command: "echo", Arguments: `'{"ExitCode": 5, "load": 0.5}' && echo warning >&2 && exit 3`, AllowFailure: true}
+	result := plugins.NewAgentResult()
+
+	err := e.Check(result)
+	if err != nil {
+		t.Fatalf("Check() failed: %s", err.Error())
+	}
+
+	if result["load"] != 0.5 || result["ExitCode"] != 3 || result["Stderr"] != "warning" {
+		t.Fatalf("Check() returned wrong result: %v", result)
+	}
+}
+
+func TestCheckContextTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	e := Exec{Command: "sleep", Arguments: "10", AllowFailure: true}
+	err := e.CheckContext(ctx, plugins.NewAgentResult())
+	if err != context.DeadlineExceeded {
+		t.Fatalf("CheckContext() returned %v, expected %v", err, context.DeadlineExceeded)
+	}
+}
+
+var _ plugins.ContextAgent = (*Exec)(nil)
+var _ plugins.ContextRemoteAgent = (*Exec)(nil)
@@ -0,0 +1,146 @@
+package exec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gansoi/gansoi/plugins"
+)
+
+var (
+	// ErrFormat will be returned if the output does not match the format.
+	ErrFormat = errors.New("output does not match format")
+)
+
+// parse will parse output according to format and add the values to result.
+func parse(format string, output []byte, result plugins.AgentResult) error {
+	output = bytes.TrimSpace(output)
+
+	switch format {
+	case FormatJSON:
+		return parseJSON(output, result)
+	case FormatKeyValue:
+		return parseKeyValue(output, result)
+	case FormatNumber:
+		return parseNumber(output, result)
+	case FormatAuto, "":
+		if len(output) == 0 {
+			return nil
+		}
+
+		if parseJSON(output, result) == nil {
+			return nil
+		}
+
+		if parseNumber(output, result) == nil {
+			return nil
+		}
+
+		return parseKeyValue(output, result)
+	}
+
+	return fmt.Errorf("unknown format '%s'", format)
+}
+
+// parseJSON will parse output as a JSON object. Nested objects and arrays are
+// flattened using underscores, {"disk": {"free": 1}} becomes disk_free.
+func parseJSON(output []byte, result plugins.AgentResult) error {
+	var object map[string]interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(output))
+	decoder.UseNumber()
+
+	err := decoder.Decode(&object)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrFormat, err.Error())
+	}
+
+	flatten("", object, result)
+
+	return nil
+}
+
+// flatten will add value to result using prefix as key.
+func flatten(prefix string, value interface{}, result plugins.AgentResult) {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+
+		return prefix + "_" + key
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			flatten(join(key), child, result)
+		}
+	case []interface{}:
+		for i, child := range v {
+			flatten(join(strconv.Itoa(i)), child, result)
+		}
+	case json.Number:
+		f, _ := v.Float64()
+		result.AddValue(plugins.SanitizeResultKey(prefix), f)
+	case nil:
+		// Nothing to add for null values.
+	default:
+		result.AddValue(plugins.SanitizeResultKey(prefix), v)
+	}
+}
+
+// parseKeyValue will parse output as key=value lines. Empty lines and lines
+// starting with # are ignored.
+func parseKeyValue(output []byte, result plugins.AgentResult) error {
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return fmt.Errorf("%w: '%s' is not key=value", ErrFormat, line)
+		}
+
+		result.AddValue(plugins.SanitizeResultKey(strings.TrimSpace(parts[0])), value(strings.TrimSpace(parts[1])))
+	}
+
+	return scanner.Err()
+}
+
+// parseNumber will parse output as a single number added as Value.
+func parseNumber(output []byte, result plugins.AgentResult) error {
+	f, err := strconv.ParseFloat(string(output), 64)
+	if err != nil {
+		return fmt.Errorf("%w: '%s' is not a number", ErrFormat, output)
+	}
+
+	result.AddValue("Value", f)
+
+	return nil
+}
+
+// value will convert s to a float64 or bool if possible.
+func value(s string) interface{} {
+	f, err := strconv.ParseFloat(s, 64)
+	if err == nil {
+		return f
+	}
+
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+
+	return s
+}
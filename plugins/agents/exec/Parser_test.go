@@ -0,0 +1,55 @@
+package exec
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/gansoi/gansoi/plugins"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		format   string
+		output   string
+		expected plugins.AgentResult
+		err      bool
+	}{
+		{FormatAuto, "", plugins.AgentResult{}, false},
+		{FormatAuto, " 42\n", plugins.AgentResult{"Value": 42.0}, false},
+		{FormatAuto, `{"a": 1, "b": "text", "c": true, "d": null}`, plugins.AgentResult{"a": 1.0, "b": "text", "c": true}, false},
+		{FormatAuto, `{"disk": {"free space": 10, "mounts": ["/", "/boot"]}}`, plugins.AgentResult{
+			"disk_free_space": 10.0,
+			"disk_mounts_0":   "/",
+			"disk_mounts_1":   "/boot",
+		}, false},
+		{FormatAuto, "# comment\na=1\n\nb = text\nc=true\nd=1=2\n", plugins.AgentResult{"a": 1.0, "b": "text", "c": true, "d": "1=2"}, false},
+		{FormatAuto, "just some text", plugins.AgentResult{}, true},
+		{FormatJSON, "[1, 2]", plugins.AgentResult{}, true},
+		{FormatJSON, "42", plugins.AgentResult{}, true},
+		{FormatKeyValue, "42", plugins.AgentResult{}, true},
+		{FormatKeyValue, "=42", plugins.AgentResult{}, true},
+		{FormatNumber, "a=1", plugins.AgentResult{}, true},
+		{FormatNumber, "-1.5", plugins.AgentResult{"Value": -1.5}, false},
+		{"yaml", "a: 1", plugins.AgentResult{}, true},
+	}
+
+	for i, c := range cases {
+		result := plugins.NewAgentResult()
+		err := parse(c.format, []byte(c.output), result)
+		if c.err != (err != nil) {
+			t.Errorf("%d: parse() returned unexpected error: %v", i, err)
+		}
+
+		if !c.err && !reflect.DeepEqual(result, c.expected) {
+			t.Errorf("%d: parse() returned %v, expected %v", i, result, c.expected)
+		}
+	}
+}
+
+func TestParseErrFormat(t *testing.T) {
+	err := parse(FormatNumber, []byte("no"), plugins.NewAgentResult())
+	if !errors.Is(err, ErrFormat) {
+		t.Fatalf("parse() did not return ErrFormat, got %v", err)
+	}
+}
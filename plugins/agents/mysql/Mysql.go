@@ -1,6 +1,7 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
 	"strconv"
 
@@ -21,13 +22,18 @@ func init() {
 
 // Check implements plugins.Agent.
 func (m *MySQL) Check(result plugins.AgentResult) error {
+	return m.CheckContext(context.Background(), result)
+}
+
+// CheckContext implements plugins.ContextAgent.
+func (m *MySQL) CheckContext(ctx context.Context, result plugins.AgentResult) error {
 	db, err := sql.Open("mysql", m.DSN)
 	if err != nil {
 		return err
 	}
 	defer db.Close()
 
-	rows, err := db.Query("SHOW GLOBAL STATUS")
+	rows, err := db.QueryContext(ctx, "SHOW GLOBAL STATUS")
 	if err != nil {
 		return err
 	}
@@ -54,3 +60,4 @@ func (m *MySQL) Check(result plugins.AgentResult) error {
 
 // Ensure compliance
 var _ plugins.Agent = (*MySQL)(nil)
+var _ plugins.ContextAgent = (*MySQL)(nil)
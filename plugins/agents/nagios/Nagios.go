@@ -0,0 +1,124 @@
+package nagios
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/gansoi/gansoi/plugins"
+	"github.com/gansoi/gansoi/transports"
+	"github.com/gansoi/gansoi/transports/local"
+)
+
+type (
+	// Nagios will execute a Nagios plugin and map its exit code to a state.
+	// Performance data is added to the result.
+	Nagios struct {
+		Plugin    string `json:"plugin" description:"Path to the plugin (/usr/lib/nagios/plugins/check_load)"`
+		Arguments string `json:"arguments" description:"Arguments as interpreted by a shell (-w 5,4,3 -c 10,8,6)"`
+	}
+)
+
+const (
+	// Exit codes as defined by the Nagios plugin development guidelines.
+	exitOK       = 0
+	exitWarning  = 1
+	exitCritical = 2
+)
+
+var (
+	// ErrNoPlugin will be returned if no plugin is configured.
+	ErrNoPlugin = errors.New("no plugin given")
+)
+
+func init() {
+	plugins.RegisterAgent("nagios", Nagios{})
+}
+
+// commandLine returns the plugin and its arguments as a single command line.
+func (n *Nagios) commandLine() string {
+	if n.Arguments == "" {
+		return n.Plugin
+	}
+
+	return n.Plugin + " " + n.Arguments
+}
+
+// Check implements plugins.Agent.
+func (n *Nagios) Check(result plugins.AgentResult) error {
+	return n.CheckContext(context.Background(), result)
+}
+
+// CheckContext implements plugins.ContextAgent. The plugin is executed on
+// the local host and killed when ctx is done.
+func (n *Nagios) CheckContext(ctx context.Context, result plugins.AgentResult) error {
+	if n.Plugin == "" {
+		return ErrNoPlugin
+	}
+
+	// The SSH transport executes commands using the remote shell. We do the
+	// same locally to have arguments interpreted alike.
+	stdout, stderr, err := (&local.Local{}).ExecContext(ctx, "/bin/sh", "-c", n.commandLine())
+
+	return n.parse(stdout, stderr, err, result)
+}
+
+// RemoteCheck implements plugins.RemoteAgent.
+func (n *Nagios) RemoteCheck(transport transports.Transport, result plugins.AgentResult) error {
+	return n.RemoteCheckContext(context.Background(), transport, result)
+}
+
+// RemoteCheckContext implements plugins.ContextRemoteAgent.
+func (n *Nagios) RemoteCheckContext(ctx context.Context, transport transports.Transport, result plugins.AgentResult) error {
+	if n.Plugin == "" {
+		return ErrNoPlugin
+	}
+
+	stdout, stderr, err := transports.ExecContext(ctx, transport, n.commandLine())
+
+	return n.parse(stdout, stderr, err, result)
+}
+
+// parse will parse the output and exit code of an executed plugin.
+func (n *Nagios) parse(stdout io.Reader, stderr io.Reader, execErr error, result plugins.AgentResult) error {
+	code, exited := transports.ExitCode(execErr)
+	if execErr != nil && !exited {
+		return execErr
+	}
+
+	var output []byte
+	if stdout != nil {
+		output, _ = ioutil.ReadAll(stdout)
+	}
+
+	text, perf := splitOutput(string(output))
+
+	if text == "" && stderr != nil {
+		errOutput, _ := ioutil.ReadAll(stderr)
+		text, _ = splitOutput(string(errOutput))
+	}
+
+	for _, item := range parsePerfdata(perf) {
+		item.addTo(result)
+	}
+
+	result.AddValue("ExitCode", code)
+	result.AddValue("Output", text)
+
+	if text == "" {
+		text = fmt.Sprintf("%s exited with code %d", n.Plugin, code)
+	}
+
+	switch code {
+	case exitOK:
+		return nil
+	case exitWarning:
+		return plugins.NewSeverityError("warning", text)
+	case exitCritical:
+		return errors.New(text)
+	default:
+		return plugins.NewSeverityError("unknown", text)
+	}
+}
@@ -0,0 +1,139 @@
+package nagios
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/gansoi/gansoi/plugins"
+	"github.com/gansoi/gansoi/transports/mock"
+)
+
+type (
+	TransportMock struct {
+		mock.Mock
+		cmd    string
+		stdout string
+		stderr string
+		err    error
+	}
+
+	exitError int
+)
+
+func (m *TransportMock) Exec(cmd string, arguments ...string) (io.Reader, io.Reader, error) {
+	m.cmd = cmd
+
+	return bytes.NewBufferString(m.stdout), bytes.NewBufferString(m.stderr), m.err
+}
+
+func (e exitError) Error() string {
+	return "exit"
+}
+
+func (e exitError) ExitStatus() int {
+	return int(e)
+}
+
+func TestRemoteCheck(t *testing.T) {
+	cases := []struct {
+		stdout   string
+		stderr   string
+		err      error
+		severity string
+		message  string
+	}{
+		{"OK | load1=0.5", "", nil, "", ""},
+		{"WARNING | load1=5.5", "", exitError(1), "warning", "WARNING"},
+		{"CRITICAL | load1=10.5", "", exitError(2), "", "CRITICAL"},
+		{"UNKNOWN | load1=0.5", "", exitError(3), "unknown", "UNKNOWN"},
+		{"", "sh: check_load: not found", exitError(127), "unknown", "sh: check_load: not found"},
+		{"| load1=0.5", "", exitError(2), "", "/usr/lib/nagios/plugins/check_load exited with code 2"},
+	}
+
+	for i, c := range cases {
+		n := Nagios{Plugin: "/usr/lib/nagios/plugins/check_load", Arguments: "-w 5"}
+		result := plugins.NewAgentResult()
+		transport := &TransportMock{stdout: c.stdout, stderr: c.stderr, err: c.err}
+
+		err := n.RemoteCheck(transport, result)
+
+		if transport.cmd != "/usr/lib/nagios/plugins/check_load -w 5" {
+			t.Errorf("%d: RemoteCheck() executed '%s'", i, transport.cmd)
+		}
+
+		if c.message == "" {
+			if err != nil {
+				t.Errorf("%d: RemoteCheck() failed: %s", i, err.Error())
+			}
+		} else if err == nil || err.Error() != c.message {
+			t.Errorf("%d: RemoteCheck() returned %v, expected '%s'", i, err, c.message)
+		}
+
+		var severityErr *plugins.SeverityError
+		severity := ""
+		if errors.As(err, &severityErr) {
+			severity = severityErr.Severity
+		}
+
+		if severity != c.severity {
+			t.Errorf("%d: RemoteCheck() returned severity '%s', expected '%s'", i, severity, c.severity)
+		}
+
+		if c.stdout != "" && result["load1"] == nil {
+			t.Errorf("%d: RemoteCheck() did not add perfdata: %v", i, result)
+		}
+	}
+}
+
+func TestRemoteCheckFail(t *testing.T) {
+	n := Nagios{}
+	err := n.RemoteCheck(&TransportMock{}, plugins.NewAgentResult())
+	if err != ErrNoPlugin {
+		t.Fatalf("RemoteCheck() did not return ErrNoPlugin, got %v", err)
+	}
+
+	n.Plugin = "check_something"
+	err = n.RemoteCheck(&TransportMock{err: errors.New("connection refused")}, plugins.NewAgentResult())
+	if err == nil || err.Error() != "connection refused" {
+		t.Fatalf("RemoteCheck() did not return transport error, got %v", err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	n := Nagios{}
+	err := n.Check(plugins.NewAgentResult())
+	if err != ErrNoPlugin {
+		t.Fatalf("Check() did not return ErrNoPlugin, got %v", err)
+	}
+
+	n = Nagios{Plugin: "echo", Arguments: "'WARNING | a=1;2;3' && exit 1"}
+	result := plugins.NewAgentResult()
+	err = n.Check(result)
+
+	var severityErr *plugins.SeverityError
+	if !errors.As(err, &severityErr) || severityErr.Severity != "warning" {
+		t.Fatalf("Check() did not return a warning, got %v", err)
+	}
+
+	if result["a"] != 1.0 || result["a_crit"] != 3.0 || result["ExitCode"] != 1 {
+		t.Fatalf("Check() returned wrong result: %v", result)
+	}
+}
+
+func TestCheckContextTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	n := Nagios{Plugin: "sleep", Arguments: "10"}
+	err := n.CheckContext(ctx, plugins.NewAgentResult())
+	if err != context.DeadlineExceeded {
+		t.Fatalf("CheckContext() returned %v, expected %v", err, context.DeadlineExceeded)
+	}
+}
+
+var _ plugins.ContextAgent = (*Nagios)(nil)
+var _ plugins.ContextRemoteAgent = (*Nagios)(nil)
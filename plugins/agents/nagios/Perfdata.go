@@ -0,0 +1,185 @@
+package nagios
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gansoi/gansoi/plugins"
+)
+
+type (
+	// perfdata is a single performance data item as described in the Nagios
+	// plugin development guidelines:
+	// 'label'=value[UOM];[warn];[crit];[min];[max]
+	perfdata struct {
+		label string
+		value float64
+		uom   string
+
+		// thresholds holds warn, crit, min and max. Thresholds not present
+		// or not numeric (ranges like "10:20") are left out.
+		thresholds map[string]float64
+	}
+)
+
+var (
+	// thresholdNames is the names of the fields following the value.
+	thresholdNames = []string{"warn", "crit", "min", "max"}
+)
+
+// splitOutput will split plugin output into text and performance data. The
+// first line can contain performance data after a pipe, and all lines after a
+// pipe in the long text are performance data as well.
+func splitOutput(output string) (string, string) {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+
+	var text []string
+	var perf []string
+
+	inPerf := false
+	for i, line := range lines {
+		if inPerf {
+			perf = append(perf, line)
+			continue
+		}
+
+		pipe := strings.IndexRune(line, '|')
+		if pipe == -1 {
+			text = append(text, line)
+			continue
+		}
+
+		text = append(text, strings.TrimSpace(line[:pipe]))
+		perf = append(perf, line[pipe+1:])
+
+		// Only a pipe in the long text starts multi-line performance data.
+		inPerf = i > 0
+	}
+
+	return strings.TrimSpace(strings.Join(text, "\n")), strings.Join(perf, " ")
+}
+
+// parsePerfdata will parse performance data. Malformed items are skipped, a
+// broken plugin should not prevent us from reading the rest.
+func parsePerfdata(data string) []perfdata {
+	var items []perfdata
+
+	for {
+		data = strings.TrimLeftFunc(data, unicode.IsSpace)
+		if data == "" {
+			return items
+		}
+
+		var label string
+		label, data = parseLabel(data)
+
+		end := strings.IndexFunc(data, unicode.IsSpace)
+		if end == -1 {
+			end = len(data)
+		}
+
+		fields := data[:end]
+		data = data[end:]
+
+		if label == "" || !strings.HasPrefix(fields, "=") {
+			continue
+		}
+
+		item, ok := parseFields(label, strings.Split(fields[1:], ";"))
+		if ok {
+			items = append(items, item)
+		}
+	}
+}
+
+// parseLabel will parse a possibly quoted label from the start of data. The
+// label and the remaining data is returned.
+func parseLabel(data string) (string, string) {
+	if !strings.HasPrefix(data, "'") {
+		end := strings.IndexFunc(data, func(r rune) bool {
+			return r == '=' || unicode.IsSpace(r)
+		})
+		if end == -1 {
+			return data, ""
+		}
+
+		return data[:end], data[end:]
+	}
+
+	var label strings.Builder
+
+	for i := 1; i < len(data); i++ {
+		if data[i] != '\'' {
+			label.WriteByte(data[i])
+			continue
+		}
+
+		// Two single quotes is an escaped quote.
+		if i+1 < len(data) && data[i+1] == '\'' {
+			label.WriteByte('\'')
+			i++
+			continue
+		}
+
+		return label.String(), data[i+1:]
+	}
+
+	// Unterminated quote.
+	return "", ""
+}
+
+// parseFields will parse the value and thresholds of a single item.
+func parseFields(label string, fields []string) (perfdata, bool) {
+	item := perfdata{
+		label:      label,
+		thresholds: make(map[string]float64),
+	}
+
+	value := fields[0]
+	end := strings.LastIndexFunc(value, func(r rune) bool {
+		return unicode.IsDigit(r) || r == '.'
+	})
+
+	// "U" means the value could not be determined.
+	if end == -1 {
+		return item, false
+	}
+
+	var err error
+	item.value, err = strconv.ParseFloat(value[:end+1], 64)
+	if err != nil {
+		return item, false
+	}
+
+	item.uom = value[end+1:]
+
+	for i, field := range fields[1:] {
+		if i >= len(thresholdNames) {
+			break
+		}
+
+		f, err := strconv.ParseFloat(field, 64)
+		if err == nil {
+			item.thresholds[thresholdNames[i]] = f
+		}
+	}
+
+	return item, true
+}
+
+// addTo will add the item to result. The value is added as the label, and
+// unit and thresholds are suffixed with an underscore.
+func (p *perfdata) addTo(result plugins.AgentResult) {
+	k := plugins.SanitizeResultKey(p.label)
+
+	result.AddValue(k, p.value)
+
+	if p.uom != "" {
+		result.AddValue(k+"_uom", p.uom)
+	}
+
+	for name, value := range p.thresholds {
+		result.AddValue(k+"_"+name, value)
+	}
+}
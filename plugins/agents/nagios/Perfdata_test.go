@@ -0,0 +1,77 @@
+package nagios
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gansoi/gansoi/plugins"
+)
+
+func TestSplitOutput(t *testing.T) {
+	cases := []struct {
+		output string
+		text   string
+		perf   string
+	}{
+		{"", "", ""},
+		{"DISK OK\n", "DISK OK", ""},
+		{"DISK OK | /=2643MB;5948;5958;0;5968\n", "DISK OK", " /=2643MB;5948;5958;0;5968"},
+		{"DISK OK | a=1\nlong text\nmore text\n", "DISK OK\nlong text\nmore text", " a=1"},
+		{"DISK OK | a=1\nlong text | b=2\nc=3\n", "DISK OK\nlong text", " a=1  b=2 c=3"},
+	}
+
+	for i, c := range cases {
+		text, perf := splitOutput(c.output)
+		if text != c.text || perf != c.perf {
+			t.Errorf("%d: splitOutput() returned '%s' and '%s', expected '%s' and '%s'", i, text, perf, c.text, c.perf)
+		}
+	}
+}
+
+func TestParsePerfdata(t *testing.T) {
+	cases := []struct {
+		data     string
+		expected []perfdata
+	}{
+		{"", nil},
+		{"time=0.5s", []perfdata{{"time", 0.5, "s", map[string]float64{}}}},
+		{"/=2643MB;5948;5958;0;5968", []perfdata{
+			{"/", 2643, "MB", map[string]float64{"warn": 5948, "crit": 5958, "min": 0, "max": 5968}},
+		}},
+		{"'disk usage'=85%;80:90;@95 'it''s'=-3c", []perfdata{
+			{"disk usage", 85, "%", map[string]float64{}},
+			{"it's", -3, "c", map[string]float64{}},
+		}},
+		{"load1=0.5;;;0 load5=U;;;0 broken", []perfdata{
+			{"load1", 0.5, "", map[string]float64{"min": 0}},
+		}},
+		{"'unterminated=5", nil},
+		{"a=1;2;3;4;5;6", []perfdata{
+			{"a", 1, "", map[string]float64{"warn": 2, "crit": 3, "min": 4, "max": 5}},
+		}},
+	}
+
+	for i, c := range cases {
+		items := parsePerfdata(c.data)
+		if !reflect.DeepEqual(items, c.expected) {
+			t.Errorf("%d: parsePerfdata() returned %+v, expected %+v", i, items, c.expected)
+		}
+	}
+}
+
+func TestPerfdataAddTo(t *testing.T) {
+	result := plugins.NewAgentResult()
+
+	p := perfdata{"disk /", 85, "%", map[string]float64{"warn": 80}}
+	p.addTo(result)
+
+	expected := plugins.AgentResult{
+		"disk__":      85.0,
+		"disk___uom":  "%",
+		"disk___warn": 80.0,
+	}
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("addTo() added %v, expected %v", result, expected)
+	}
+}
@@ -0,0 +1,173 @@
+package prometheus
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type (
+	// sample is a single sample from the Prometheus text exposition format
+	// or OpenMetrics.
+	sample struct {
+		name   string
+		labels map[string]string
+		value  float64
+	}
+)
+
+// parseExposition will read all samples from r. Comments, metadata and
+// timestamps are ignored.
+func parseExposition(r io.Reader) ([]sample, error) {
+	var samples []sample
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		s, err := parseSample(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineNumber, err.Error())
+		}
+
+		samples = append(samples, s)
+	}
+
+	return samples, scanner.Err()
+}
+
+// parseSample will parse a single sample line:
+// name{label="value",...} value [timestamp]
+func parseSample(line string) (sample, error) {
+	s := sample{
+		labels: make(map[string]string),
+	}
+
+	end := strings.IndexAny(line, "{ \t")
+	if end <= 0 {
+		return s, fmt.Errorf("malformed sample '%s'", line)
+	}
+
+	s.name = line[:end]
+	rest := line[end:]
+
+	if strings.HasPrefix(rest, "{") {
+		var err error
+
+		rest, err = parseLabels(rest[1:], s.labels)
+		if err != nil {
+			return s, err
+		}
+	}
+
+	// OpenMetrics exemplars follow a hash sign. We don't need them.
+	exemplar := strings.Index(rest, "#")
+	if exemplar >= 0 {
+		rest = rest[:exemplar]
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) < 1 || len(fields) > 2 {
+		return s, fmt.Errorf("malformed sample '%s'", line)
+	}
+
+	value, err := parseValue(fields[0])
+	if err != nil {
+		return s, err
+	}
+
+	s.value = value
+
+	return s, nil
+}
+
+// parseLabels will parse labels until the closing brace into labels. The
+// remainder of data is returned.
+func parseLabels(data string, labels map[string]string) (string, error) {
+	for {
+		data = strings.TrimLeft(data, " \t,")
+
+		if strings.HasPrefix(data, "}") {
+			return data[1:], nil
+		}
+
+		eq := strings.IndexRune(data, '=')
+		if eq <= 0 {
+			return "", fmt.Errorf("malformed labels")
+		}
+
+		name := strings.TrimSpace(data[:eq])
+		data = strings.TrimLeft(data[eq+1:], " \t")
+
+		value, rest, err := parseQuoted(data)
+		if err != nil {
+			return "", err
+		}
+
+		labels[name] = value
+		data = rest
+	}
+}
+
+// parseQuoted will parse a double quoted and escaped string from the start of
+// data. The unquoted string and the remainder of data is returned.
+func parseQuoted(data string) (string, string, error) {
+	if !strings.HasPrefix(data, `"`) {
+		return "", "", fmt.Errorf("label value must be quoted")
+	}
+
+	var value strings.Builder
+
+	for i := 1; i < len(data); i++ {
+		switch data[i] {
+		case '"':
+			return value.String(), data[i+1:], nil
+		case '\\':
+			i++
+			if i >= len(data) {
+				break
+			}
+
+			switch data[i] {
+			case 'n':
+				value.WriteByte('\n')
+			default:
+				value.WriteByte(data[i])
+			}
+		default:
+			value.WriteByte(data[i])
+		}
+	}
+
+	return "", "", fmt.Errorf("unterminated label value")
+}
+
+// parseValue will parse a sample value including NaN and infinity.
+func parseValue(value string) (float64, error) {
+	switch value {
+	case "NaN":
+		return math.NaN(), nil
+	case "+Inf", "Inf":
+		return math.Inf(1), nil
+	case "-Inf":
+		return math.Inf(-1), nil
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value '%s'", value)
+	}
+
+	return f, nil
+}
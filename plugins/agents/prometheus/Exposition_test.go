@@ -0,0 +1,83 @@
+package prometheus
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseExposition(t *testing.T) {
+	input := `# HELP http_requests_total The total number of HTTP requests.
+# TYPE http_requests_total counter
+http_requests_total{method="post",code="200"} 1027 1395066363000
+http_requests_total{method="post",code="400"}    3 1395066363000
+
+msdos_file_access_time_seconds{path="C:\\DIR\\FILE.TXT",error="Cannot find file:\n\"FILE.TXT\""} 1.458255915e9
+metric_without_timestamp_and_labels 12.47
+something_weird{problem="division by zero"} +Inf -3982045
+rpc_duration_seconds{quantile="0.5",} 4773
+exemplar_total{a="#"} 1 # {trace_id="KOO5S4vxi0o"} 0.67
+# EOF
+`
+
+	expected := []sample{
+		{"http_requests_total", map[string]string{"method": "post", "code": "200"}, 1027},
+		{"http_requests_total", map[string]string{"method": "post", "code": "400"}, 3},
+		{"msdos_file_access_time_seconds", map[string]string{"path": `C:\DIR\FILE.TXT`, "error": "Cannot find file:\n\"FILE.TXT\""}, 1.458255915e9},
+		{"metric_without_timestamp_and_labels", map[string]string{}, 12.47},
+		{"something_weird", map[string]string{"problem": "division by zero"}, math.Inf(1)},
+		{"rpc_duration_seconds", map[string]string{"quantile": "0.5"}, 4773},
+		{"exemplar_total", map[string]string{"a": "#"}, 1},
+	}
+
+	samples, err := parseExposition(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parseExposition() failed: %s", err.Error())
+	}
+
+	if !reflect.DeepEqual(samples, expected) {
+		t.Fatalf("parseExposition() returned\n%+v\nexpected\n%+v", samples, expected)
+	}
+}
+
+func TestParseExpositionFail(t *testing.T) {
+	cases := []string{
+		"{a=\"b\"} 1",
+		"metric",
+		"metric 1 2 3",
+		"metric abc",
+		"metric{a=b} 1",
+		"metric{a=\"b} 1",
+		"metric{a} 1",
+	}
+
+	for _, input := range cases {
+		_, err := parseExposition(strings.NewReader(input))
+		if err == nil {
+			t.Errorf("parseExposition() did not fail for '%s'", input)
+		}
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	cases := map[string]float64{
+		"1":    1,
+		"-1.5": -1.5,
+		"Inf":  math.Inf(1),
+		"-Inf": math.Inf(-1),
+		"1e3":  1000,
+	}
+
+	for input, expected := range cases {
+		value, err := parseValue(input)
+		if err != nil || value != expected {
+			t.Errorf("parseValue(%s) returned %f (%v), expected %f", input, value, err, expected)
+		}
+	}
+
+	value, _ := parseValue("NaN")
+	if !math.IsNaN(value) {
+		t.Errorf("parseValue(NaN) returned %f", value)
+	}
+}
@@ -0,0 +1,169 @@
+package prometheus
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gansoi/gansoi/build"
+	"github.com/gansoi/gansoi/plugins"
+)
+
+type (
+	// Prometheus will scrape an endpoint exposing metrics in the Prometheus
+	// text format or OpenMetrics and add selected series to the result.
+	Prometheus struct {
+		URL       string `json:"url" description:"The URL to scrape (http://localhost:9100/metrics)"`
+		Selectors string `json:"selectors" description:"Series to select, separated by whitespace and optionally aliased (up errors=http_requests_total{code=~\"5..\"})"`
+		Insecure  bool   `json:"insecure" description:"Ignore SSL errors"`
+	}
+)
+
+const (
+	// maxBodySize is the maximum number of bytes read from an endpoint.
+	maxBodySize = 16 * 1024 * 1024
+
+	accept = "application/openmetrics-text;version=1.0.0,text/plain;version=0.0.4;q=0.5"
+)
+
+var (
+	userAgent = build.UserAgent + " prometheus-agent"
+)
+
+func init() {
+	plugins.RegisterAgent("prometheus", Prometheus{})
+}
+
+// Check implements plugins.Agent.
+func (p *Prometheus) Check(result plugins.AgentResult) error {
+	return p.CheckContext(context.Background(), result)
+}
+
+// CheckContext implements plugins.ContextAgent.
+//
+// For each selector, the sum of all matching series is added using the
+// metric name - or the alias - as key. If series are distinguished by labels
+// not pinned by an equality matcher, each series is added as well with the
+// label names and values appended to the key: http_requests_total{code="500"}
+// matching series with method GET and POST will add http_requests_total,
+// http_requests_total_method_GET and http_requests_total_method_POST. Use
+// aliases to select the same metric more than once, result keys must be
+// unique.
+func (p *Prometheus) CheckContext(ctx context.Context, result plugins.AgentResult) error {
+	selectors, err := parseSelectors(p.Selectors)
+	if err != nil {
+		return err
+	}
+
+	if len(selectors) == 0 {
+		return fmt.Errorf("no selectors given")
+	}
+
+	start := time.Now()
+
+	samples, err := p.scrape(ctx)
+	if err != nil {
+		return err
+	}
+
+	values := make(map[string]float64)
+
+	add := func(key string, value float64) error {
+		key = plugins.SanitizeResultKey(key)
+
+		if _, found := values[key]; found {
+			return fmt.Errorf("duplicate result key %s, use alias=selector to tell series apart", key)
+		}
+
+		values[key] = value
+
+		return nil
+	}
+
+	for i := range selectors {
+		sel := &selectors[i]
+
+		matched := 0
+		sum := 0.0
+
+		for j := range samples {
+			smp := &samples[j]
+
+			// Non-finite values cannot be represented in JSON.
+			if !sel.matches(smp) || math.IsNaN(smp.value) || math.IsInf(smp.value, 0) {
+				continue
+			}
+
+			matched++
+			sum += smp.value
+
+			seriesKey := sel.seriesKey(smp)
+			if seriesKey == sel.key() {
+				continue
+			}
+
+			err = add(seriesKey, smp.value)
+			if err != nil {
+				return err
+			}
+		}
+
+		if matched == 0 {
+			return fmt.Errorf("no series matching %s", sel.String())
+		}
+
+		err = add(sel.key(), sum)
+		if err != nil {
+			return err
+		}
+	}
+
+	result.AddValue("ScrapeDuration", ms(time.Since(start)))
+
+	for key, value := range values {
+		result.AddValue(key, value)
+	}
+
+	return nil
+}
+
+// scrape will fetch and parse all samples from the endpoint.
+func (p *Prometheus) scrape(ctx context.Context) ([]sample, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", accept)
+	req.Header.Set("User-Agent", userAgent)
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: p.Insecure,
+			},
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return parseExposition(io.LimitReader(resp.Body, maxBodySize))
+}
+
+// ms will convert a time.Duration to milliseconds.
+func ms(d time.Duration) int64 {
+	return ((d + time.Millisecond/2) / time.Millisecond).Nanoseconds()
+}
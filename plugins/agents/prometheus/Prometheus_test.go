@@ -0,0 +1,96 @@
+package prometheus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gansoi/gansoi/plugins"
+)
+
+const (
+	exposition = `# TYPE http_requests_total counter
+http_requests_total{method="GET",code="200"} 90
+http_requests_total{method="GET",code="500"} 4
+http_requests_total{method="POST",code="500"} 1
+# TYPE http_requests_errors_ratio gauge
+http_requests_errors_ratio 0.005
+broken_ratio NaN
+`
+)
+
+func newServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metrics" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		w.Write([]byte(exposition))
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestCheck(t *testing.T) {
+	server := newServer(t)
+
+	p := Prometheus{
+		URL:       server.URL + "/metrics",
+		Selectors: `http_requests_errors_ratio http_requests_total{code="500"} ok=http_requests_total{code="200",method="GET"}`,
+	}
+
+	result := plugins.NewAgentResult()
+	err := p.Check(result)
+	if err != nil {
+		t.Fatalf("Check() failed: %s", err.Error())
+	}
+
+	expected := map[string]float64{
+		"http_requests_errors_ratio":      0.005,
+		"http_requests_total":             5,
+		"http_requests_total_method_GET":  4,
+		"http_requests_total_method_POST": 1,
+		"ok":                              90,
+	}
+
+	for key, value := range expected {
+		if result[key] != value {
+			t.Errorf("%s is %v, expected %f", key, result[key], value)
+		}
+	}
+
+	if _, found := result["ScrapeDuration"]; !found {
+		t.Errorf("ScrapeDuration not added")
+	}
+
+	if len(result) != len(expected)+1 {
+		t.Errorf("Check() added unexpected keys: %v", result)
+	}
+}
+
+func TestCheckFail(t *testing.T) {
+	server := newServer(t)
+
+	cases := []Prometheus{
+		{URL: server.URL + "/metrics"},
+		{URL: server.URL + "/metrics", Selectors: "a{"},
+		{URL: server.URL + "/notfound", Selectors: "up"},
+		{URL: server.URL + "/metrics", Selectors: "up"},
+		{URL: server.URL + "/metrics", Selectors: "broken_ratio"},
+		{URL: ":", Selectors: "up"},
+		{URL: "http://127.0.0.1:0/metrics", Selectors: "up"},
+		{URL: server.URL + "/metrics", Selectors: `http_requests_total{code="500"} http_requests_total{code="200"}`},
+		{URL: server.URL + "/metrics", Selectors: `a=http_requests_errors_ratio a=http_requests_total`},
+	}
+
+	for i, p := range cases {
+		err := p.Check(plugins.NewAgentResult())
+		if err == nil {
+			t.Errorf("%d: Check() did not fail", i)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package prometheus
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+type (
+	// selector selects series by metric name and label matchers like
+	// http_requests_total{code=~"5..",method!="OPTIONS"}. The selector can
+	// be prefixed by an alias to use in result keys instead of the metric
+	// name: errors=http_requests_total{code=~"5.."}.
+	selector struct {
+		alias    string
+		name     string
+		matchers []matcher
+	}
+
+	// matcher matches a single label.
+	matcher struct {
+		label string
+		op    string
+		value string
+		re    *regexp.Regexp
+	}
+)
+
+// parseSelectors will parse a list of selectors separated by whitespace or
+// commas.
+func parseSelectors(data string) ([]selector, error) {
+	var selectors []selector
+
+	for {
+		data = strings.TrimLeftFunc(data, func(r rune) bool {
+			return r == ',' || unicode.IsSpace(r)
+		})
+
+		if data == "" {
+			return selectors, nil
+		}
+
+		var sel selector
+
+		sel.name, data = parseName(data)
+
+		if strings.HasPrefix(data, "=") {
+			sel.alias = sel.name
+			sel.name, data = parseName(data[1:])
+
+			if sel.alias == "" {
+				return nil, fmt.Errorf("%s: alias is empty", sel.name)
+			}
+		}
+
+		if sel.name == "" {
+			return nil, fmt.Errorf("selector is missing a metric name")
+		}
+
+		if strings.HasPrefix(data, "{") {
+			var err error
+
+			data, err = sel.parseMatchers(data[1:])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", sel.name, err.Error())
+			}
+		}
+
+		selectors = append(selectors, sel)
+	}
+}
+
+// parseName will parse a metric name or an alias. The remainder of data is
+// returned.
+func parseName(data string) (string, string) {
+	end := strings.IndexFunc(data, func(r rune) bool {
+		return r == '{' || r == ',' || r == '=' || unicode.IsSpace(r)
+	})
+	if end == -1 {
+		end = len(data)
+	}
+
+	return data[:end], data[end:]
+}
+
+// parseMatchers will parse label matchers until the closing brace. The
+// remainder of data is returned.
+func (s *selector) parseMatchers(data string) (string, error) {
+	for {
+		data = strings.TrimLeft(data, " \t,")
+
+		if strings.HasPrefix(data, "}") {
+			return data[1:], nil
+		}
+
+		end := strings.IndexAny(data, "=!")
+		if end <= 0 {
+			return "", fmt.Errorf("malformed label matcher")
+		}
+
+		m := matcher{label: strings.TrimSpace(data[:end])}
+		data = data[end:]
+
+		for _, op := range []string{"=~", "!~", "!=", "="} {
+			if strings.HasPrefix(data, op) {
+				m.op = op
+				break
+			}
+		}
+
+		if m.op == "" {
+			return "", fmt.Errorf("unknown operator in label matcher for '%s'", m.label)
+		}
+
+		value, rest, err := parseQuoted(strings.TrimLeft(data[len(m.op):], " \t"))
+		if err != nil {
+			return "", err
+		}
+
+		m.value = value
+		data = rest
+
+		if m.op == "=~" || m.op == "!~" {
+			// Like Prometheus, regular expressions are fully anchored.
+			m.re, err = regexp.Compile("^(?:" + value + ")$")
+			if err != nil {
+				return "", err
+			}
+		}
+
+		s.matchers = append(s.matchers, m)
+	}
+}
+
+// matches returns true if the sample s is selected.
+func (s *selector) matches(smp *sample) bool {
+	if smp.name != s.name {
+		return false
+	}
+
+	for _, m := range s.matchers {
+		// A missing label matches like an empty label.
+		value := smp.labels[m.label]
+
+		var ok bool
+		switch m.op {
+		case "=":
+			ok = value == m.value
+		case "!=":
+			ok = value != m.value
+		case "=~":
+			ok = m.re.MatchString(value)
+		case "!~":
+			ok = !m.re.MatchString(value)
+		}
+
+		if !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// key returns the result key used for the sum of series selected by s.
+func (s *selector) key() string {
+	if s.alias != "" {
+		return s.alias
+	}
+
+	return s.name
+}
+
+// seriesKey returns a key unique for the series of smp among series matched
+// by s. Labels not pinned by an equality matcher are appended to the key as
+// name and value pairs, sorted by label name.
+func (s *selector) seriesKey(smp *sample) string {
+	pinned := make(map[string]bool)
+	for _, m := range s.matchers {
+		if m.op == "=" {
+			pinned[m.label] = true
+		}
+	}
+
+	var names []string
+	for name := range smp.labels {
+		if !pinned[name] {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	key := s.key()
+	for _, name := range names {
+		key += "_" + name + "_" + smp.labels[name]
+	}
+
+	return key
+}
+
+// String implements fmt.Stringer.
+func (s *selector) String() string {
+	name := s.name
+	if s.alias != "" {
+		name = s.alias + "=" + name
+	}
+
+	if len(s.matchers) == 0 {
+		return name
+	}
+
+	matchers := make([]string, len(s.matchers))
+	for i, m := range s.matchers {
+		matchers[i] = fmt.Sprintf("%s%s%q", m.label, m.op, m.value)
+	}
+
+	return name + "{" + strings.Join(matchers, ",") + "}"
+}
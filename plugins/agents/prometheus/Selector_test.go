@@ -0,0 +1,105 @@
+package prometheus
+
+import (
+	"testing"
+)
+
+func TestParseSelectors(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected []string
+	}{
+		{"", nil},
+		{"up", []string{"up"}},
+		{" up,  process_open_fds\n", []string{"up", "process_open_fds"}},
+		{`http_requests_total{code=~"5..", method!="GET"} up{job="node"}`, []string{
+			`http_requests_total{code=~"5..",method!="GET"}`,
+			`up{job="node"}`,
+		}},
+		{`a{b!~"c,d}"}`, []string{`a{b!~"c,d}"}`}},
+		{`errors=http_requests_total{code="500"}, http_requests_total`, []string{
+			`errors=http_requests_total{code="500"}`,
+			`http_requests_total`,
+		}},
+	}
+
+	for i, c := range cases {
+		selectors, err := parseSelectors(c.input)
+		if err != nil {
+			t.Fatalf("%d: parseSelectors() failed: %s", i, err.Error())
+		}
+
+		if len(selectors) != len(c.expected) {
+			t.Fatalf("%d: parseSelectors() returned %d selectors, expected %d", i, len(selectors), len(c.expected))
+		}
+
+		for j, sel := range selectors {
+			if sel.String() != c.expected[j] {
+				t.Errorf("%d: Selector %d is %s, expected %s", i, j, sel.String(), c.expected[j])
+			}
+		}
+	}
+}
+
+func TestParseSelectorsFail(t *testing.T) {
+	cases := []string{
+		`{code="200"}`,
+		`a{code}`,
+		`a{code<"200"}`,
+		`a{code=200}`,
+		`a{code="200"`,
+		`a{code=~"("}`,
+		`=up`,
+		`alias=`,
+		`alias={code="200"}`,
+	}
+
+	for _, input := range cases {
+		_, err := parseSelectors(input)
+		if err == nil {
+			t.Errorf("parseSelectors() did not fail for '%s'", input)
+		}
+	}
+}
+
+func TestSelectorMatches(t *testing.T) {
+	smp := &sample{
+		name:   "http_requests_total",
+		labels: map[string]string{"code": "500", "method": "GET"},
+	}
+
+	cases := []struct {
+		selector string
+		matches  bool
+		key      string
+	}{
+		{`http_requests_total`, true, "http_requests_total_code_500_method_GET"},
+		{`up`, false, ""},
+		{`http_requests_total{code="500"}`, true, "http_requests_total_method_GET"},
+		{`errors=http_requests_total{code="500"}`, true, "errors_method_GET"},
+		{`http_requests_total{code="500",method="GET"}`, true, "http_requests_total"},
+		{`http_requests_total{code="200"}`, false, ""},
+		{`http_requests_total{code!="200"}`, true, "http_requests_total_code_500_method_GET"},
+		{`http_requests_total{code!="500"}`, false, ""},
+		{`http_requests_total{code=~"5.."}`, true, "http_requests_total_code_500_method_GET"},
+		{`http_requests_total{code=~"5"}`, false, ""},
+		{`http_requests_total{code!~"5.."}`, false, ""},
+		{`http_requests_total{instance=""}`, true, "http_requests_total_code_500_method_GET"},
+	}
+
+	for _, c := range cases {
+		selectors, err := parseSelectors(c.selector)
+		if err != nil {
+			t.Fatalf("parseSelectors(%s) failed: %s", c.selector, err.Error())
+		}
+
+		sel := &selectors[0]
+		if sel.matches(smp) != c.matches {
+			t.Errorf("%s: matches() returned %v, expected %v", c.selector, !c.matches, c.matches)
+		}
+
+		if c.matches && sel.seriesKey(smp) != c.key {
+			t.Errorf("%s: seriesKey() returned %s, expected %s", c.selector, sel.seriesKey(smp), c.key)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package smtp
 
 import (
+	"context"
+	"net"
 	"net/textproto"
 	"strings"
 
@@ -29,10 +31,24 @@ func defaultPort(address string) string {
 
 // Check implements plugins.Agent.
 func (s *SMTP) Check(result plugins.AgentResult) error {
-	conn, err := textproto.Dial("tcp", defaultPort(s.Address))
+	return s.CheckContext(context.Background(), result)
+}
+
+// CheckContext implements plugins.ContextAgent.
+func (s *SMTP) CheckContext(ctx context.Context, result plugins.AgentResult) error {
+	var dialer net.Dialer
+
+	c, err := dialer.DialContext(ctx, "tcp", defaultPort(s.Address))
 	if err != nil {
 		return err
 	}
+
+	// Make sure we don't wait for the banner forever.
+	if deadline, ok := ctx.Deadline(); ok {
+		c.SetDeadline(deadline)
+	}
+
+	conn := textproto.NewConn(c)
 	defer conn.Close()
 
 	_, banner, _ := conn.ReadResponse(220)
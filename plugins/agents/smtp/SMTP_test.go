@@ -85,3 +85,4 @@ func TestCheck(t *testing.T) {
 }
 
 var _ plugins.Agent = (*SMTP)(nil)
+var _ plugins.ContextAgent = (*SMTP)(nil)
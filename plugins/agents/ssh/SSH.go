@@ -1,6 +1,7 @@
 package ssh
 
 import (
+	"context"
 	"net"
 	"strings"
 	"time"
@@ -35,6 +36,11 @@ func defaultPort(address string) string {
 
 // Check implements plugins.Agent.
 func (s *SSH) Check(result plugins.AgentResult) error {
+	return s.CheckContext(context.Background(), result)
+}
+
+// CheckContext implements plugins.ContextAgent.
+func (s *SSH) CheckContext(ctx context.Context, result plugins.AgentResult) error {
 	conf := ssh.ClientConfig{
 		User:          "gansoi-ssh-agent",
 		ClientVersion: clientVersion,
@@ -57,7 +63,7 @@ func (s *SSH) Check(result plugins.AgentResult) error {
 	}
 
 	start := time.Now()
-	conn, err := ssh.Dial("tcp", defaultPort(s.Address), &conf)
+	conn, err := dial(ctx, defaultPort(s.Address), &conf)
 
 	// This is ugly, but there's no other way of recognizing this "error".
 	if err != nil && err.Error() != "ssh: handshake failed: ssh: unable to authenticate, attempted methods [none], no supported methods remain" {
@@ -75,6 +81,30 @@ func (s *SSH) Check(result plugins.AgentResult) error {
 	return nil
 }
 
+// dial will connect to a SSH server like ssh.Dial() - but will abort when ctx
+// is done.
+func dial(ctx context.Context, address string, conf *ssh.ClientConfig) (*ssh.Client, error) {
+	var dialer net.Dialer
+
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, chans, reqs, err := ssh.NewClientConn(conn, address, conf)
+	if err != nil {
+		conn.Close()
+
+		return nil, err
+	}
+
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
 // ms will convert a time.Duration to milliseconds.
 func ms(d time.Duration) int64 {
 	return ((d + time.Millisecond/2) / time.Millisecond).Nanoseconds()
@@ -173,3 +173,4 @@ func TestBanner(t *testing.T) {
 }
 
 var _ plugins.Agent = (*SSH)(nil)
+var _ plugins.ContextAgent = (*SSH)(nil)
@@ -1,6 +1,7 @@
 package tcpport
 
 import (
+	"context"
 	"net"
 	"time"
 
@@ -18,8 +19,15 @@ type TCPPort struct {
 
 // Check implements plugins.Agent.
 func (t *TCPPort) Check(result plugins.AgentResult) error {
+	return t.CheckContext(context.Background(), result)
+}
+
+// CheckContext implements plugins.ContextAgent.
+func (t *TCPPort) CheckContext(ctx context.Context, result plugins.AgentResult) error {
+	var dialer net.Dialer
+
 	start := time.Now()
-	conn, err := net.Dial("tcp", t.Address)
+	conn, err := dialer.DialContext(ctx, "tcp", t.Address)
 	if err != nil {
 		return err
 	}
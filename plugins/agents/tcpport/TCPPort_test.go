@@ -62,3 +62,4 @@ func TestCheckV6(t *testing.T) {
 }
 
 var _ plugins.Agent = (*TCPPort)(nil)
+var _ plugins.ContextAgent = (*TCPPort)(nil)
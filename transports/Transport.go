@@ -1,6 +1,8 @@
 package transports
 
 import (
+	"context"
+	"errors"
 	"io"
 	"net"
 )
@@ -17,4 +19,65 @@ type (
 		// ReadFile should mimic ioutil.ReadFile.
 		ReadFile(filename string) ([]byte, error)
 	}
+
+	// ContextTransport should be implemented by transports able to abort
+	// executed commands.
+	ContextTransport interface {
+		// ExecContext should execute a binary on the host and kill it when
+		// ctx is done.
+		ExecContext(ctx context.Context, cmd string, arguments ...string) (io.Reader, io.Reader, error)
+	}
 )
+
+// ExecContext will execute cmd using transport. If transport implements
+// ContextTransport, the command will be killed when ctx is done. Otherwise
+// we will stop waiting for it.
+func ExecContext(ctx context.Context, transport Transport, cmd string, arguments ...string) (io.Reader, io.Reader, error) {
+	if t, ok := transport.(ContextTransport); ok {
+		return t.ExecContext(ctx, cmd, arguments...)
+	}
+
+	type execReturn struct {
+		stdout io.Reader
+		stderr io.Reader
+		err    error
+	}
+
+	done := make(chan execReturn, 1)
+
+	go func() {
+		stdout, stderr, err := transport.Exec(cmd, arguments...)
+
+		done <- execReturn{stdout: stdout, stderr: stderr, err: err}
+	}()
+
+	select {
+	case ret := <-done:
+		return ret.stdout, ret.stderr, ret.err
+
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+}
+
+// ExitCode will extract the exit code from an error returned by Exec(). The
+// SSH and local transport use different error types. If err is nil, the exit
+// code is zero. The second return value is false if err does not carry an
+// exit code, the command might not have been run at all.
+func ExitCode(err error) (int, bool) {
+	if err == nil {
+		return 0, true
+	}
+
+	var status interface{ ExitStatus() int }
+	if errors.As(err, &status) {
+		return status.ExitStatus(), true
+	}
+
+	var code interface{ ExitCode() int }
+	if errors.As(err, &code) {
+		return code.ExitCode(), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,85 @@
+package transports
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+type (
+	slowTransport struct {
+		delay time.Duration
+	}
+)
+
+func (s *slowTransport) Dial(network, address string) (net.Conn, error) {
+	return nil, nil
+}
+
+func (s *slowTransport) Exec(cmd string, arguments ...string) (io.Reader, io.Reader, error) {
+	time.Sleep(s.delay)
+
+	return bytes.NewBufferString(cmd), nil, nil
+}
+
+func (s *slowTransport) ReadFile(filename string) ([]byte, error) {
+	return nil, nil
+}
+
+func TestExecContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, _, err := ExecContext(ctx, &slowTransport{delay: time.Second}, "true")
+	if err != context.DeadlineExceeded {
+		t.Fatalf("ExecContext() returned %v, expected %v", err, context.DeadlineExceeded)
+	}
+
+	stdout, _, err := ExecContext(context.Background(), &slowTransport{}, "true")
+	if err != nil {
+		t.Fatalf("ExecContext() failed: %s", err.Error())
+	}
+
+	if stdout.(*bytes.Buffer).String() != "true" {
+		t.Fatalf("ExecContext() returned wrong output")
+	}
+}
+
+type (
+	exitStatus int
+)
+
+func (e exitStatus) Error() string {
+	return "exit"
+}
+
+func (e exitStatus) ExitStatus() int {
+	return int(e)
+}
+
+func TestExitCode(t *testing.T) {
+	cases := []struct {
+		err    error
+		code   int
+		exited bool
+	}{
+		{nil, 0, true},
+		{exitStatus(2), 2, true},
+		{fmt.Errorf("wrapped: %w", exitStatus(3)), 3, true},
+		{exec.Command("false").Run(), 1, true},
+		{errors.New("connection refused"), 0, false},
+	}
+
+	for i, c := range cases {
+		code, exited := ExitCode(c.err)
+		if code != c.code || exited != c.exited {
+			t.Errorf("%d: ExitCode() returned %d, %v, expected %d, %v", i, code, exited, c.code, c.exited)
+		}
+	}
+}
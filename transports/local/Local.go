@@ -0,0 +1,73 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"os/exec"
+	"syscall"
+)
+
+type (
+	// Local is a transport for accessing the local host.
+	Local struct{}
+)
+
+// Dial should mimic net.Dial.
+func (l *Local) Dial(network, address string) (net.Conn, error) {
+	return net.Dial(network, address)
+}
+
+// Exec executes a binary on the local host. Like the SSH transport, stdout
+// and stderr are returned even if the command fails.
+func (l *Local) Exec(cmd string, arguments ...string) (io.Reader, io.Reader, error) {
+	return l.ExecContext(context.Background(), cmd, arguments...)
+}
+
+// ExecContext implements transports.ContextTransport. The command is started
+// in its own process group. When ctx is done, the whole group is killed, a
+// shell would otherwise leave its children running.
+func (l *Local) ExecContext(ctx context.Context, cmd string, arguments ...string) (io.Reader, io.Reader, error) {
+	var stdoutBuf, stderrBuf bytes.Buffer
+
+	c := exec.CommandContext(ctx, cmd, arguments...)
+	c.Stdout = &stdoutBuf
+	c.Stderr = &stderrBuf
+	c.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	err := c.Start()
+	if err != nil {
+		return &stdoutBuf, &stderrBuf, err
+	}
+
+	// Wait() will not return before all processes holding stdout and stderr
+	// are gone.
+	exited := make(chan struct{})
+	defer close(exited)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			syscall.Kill(-c.Process.Pid, syscall.SIGKILL)
+		case <-exited:
+		}
+	}()
+
+	err = c.Wait()
+	if err != nil && ctx.Err() != nil {
+		return &stdoutBuf, &stderrBuf, ctx.Err()
+	}
+
+	if err != nil {
+		return &stdoutBuf, &stderrBuf, err
+	}
+
+	return &stdoutBuf, &stderrBuf, nil
+}
+
+// ReadFile should mimic ioutil.ReadFile.
+func (l *Local) ReadFile(filename string) ([]byte, error) {
+	return ioutil.ReadFile(filename)
+}
@@ -0,0 +1,101 @@
+package local
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gansoi/gansoi/transports"
+)
+
+func TestDial(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() failed: %s", err.Error())
+	}
+	defer l.Close()
+
+	local := &Local{}
+	conn, err := local.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial() failed: %s", err.Error())
+	}
+	conn.Close()
+}
+
+func TestExec(t *testing.T) {
+	local := &Local{}
+
+	stdout, _, err := local.Exec("echo", "hello")
+	if err != nil {
+		t.Fatalf("Exec() failed: %s", err.Error())
+	}
+
+	out, _ := ioutil.ReadAll(stdout)
+	if string(out) != "hello\n" {
+		t.Fatalf("Exec() returned wrong output: '%s'", out)
+	}
+
+	_, stderr, err := local.Exec("sh", "-c", "echo failed >&2; exit 2")
+	if err == nil {
+		t.Fatalf("Exec() did not return error for failing command")
+	}
+
+	out, _ = ioutil.ReadAll(stderr)
+	if string(out) != "failed\n" {
+		t.Fatalf("Exec() returned wrong stderr: '%s'", out)
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	f, _ := ioutil.TempFile(os.TempDir(), "TestReadFile")
+	f.WriteString("contents")
+	f.Close()
+	defer os.Remove(f.Name())
+
+	local := &Local{}
+	contents, err := local.ReadFile(f.Name())
+	if err != nil {
+		t.Fatalf("ReadFile() failed: %s", err.Error())
+	}
+
+	if string(contents) != "contents" {
+		t.Fatalf("ReadFile() returned wrong contents: '%s'", contents)
+	}
+}
+
+var _ transports.Transport = (*Local)(nil)
+
+func TestExecContext(t *testing.T) {
+	local := &Local{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// The sleep is a child of the shell. If only the shell was killed, the
+	// sleep would keep stdout open and ExecContext() would not return.
+	start := time.Now()
+	_, _, err := local.ExecContext(ctx, "/bin/sh", "-c", "sleep 10; echo done")
+	if err != context.DeadlineExceeded {
+		t.Fatalf("ExecContext() returned %v, expected %v", err, context.DeadlineExceeded)
+	}
+
+	if time.Since(start) > 5*time.Second {
+		t.Fatalf("ExecContext() did not kill the process group")
+	}
+
+	stdout, _, err := local.ExecContext(context.Background(), "echo", "hello")
+	if err != nil {
+		t.Fatalf("ExecContext() failed: %s", err.Error())
+	}
+
+	out, _ := ioutil.ReadAll(stdout)
+	if string(out) != "hello\n" {
+		t.Fatalf("ExecContext() returned wrong output: '%s'", out)
+	}
+}
+
+var _ transports.ContextTransport = (*Local)(nil)
@@ -2,6 +2,7 @@ package ssh
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -25,10 +26,16 @@ type (
 	SSH struct {
 		database.Object `storm:"inline"`
 
-		Address  string `json:"address" description:"Hostname or IP address to connect to (1.2.3.4 or 1.2.3.4:22)"`
-		Username string `json:"username" description:"Username"`
+		Address   string            `json:"address" description:"Hostname or IP address to connect to (1.2.3.4 or 1.2.3.4:22)"`
+		Username  string            `json:"username" description:"Username"`
+		DependsOn []string          `json:"dependson" description:"Checks all checks on this host depends on"`
+		Labels    map[string]string `json:"labels" description:"Labels used for selecting hosts from checks (role=db)"`
 	}
 
+	// KeyListener will listen for changes to the private key in the cluster
+	// database.
+	KeyListener struct{}
+
 	// This is a cheap hack to use database.ReadWriter as a key/value store for
 	// our private key.
 	keyStorage struct {
@@ -97,6 +104,74 @@ func Init(db database.ReadWriter) error {
 	return nil
 }
 
+// Load will load a private key previously generated by Init. Load will never
+// generate a new key, and can be used on nodes that should not.
+func Load(db database.Reader) error {
+	pemBytes, err := KeyPEM(db)
+	if err != nil {
+		return err
+	}
+
+	return setKey(pemBytes)
+}
+
+// KeyPEM returns the PEM encoded private key stored in db by Init.
+func KeyPEM(db database.Reader) ([]byte, error) {
+	ks := keyStorage{ID: "rsa-key"}
+
+	err := db.One("ID", ks.ID, &ks)
+	if err != nil {
+		return nil, err
+	}
+
+	return ks.PemBytes, nil
+}
+
+// LoadKey will use the PEM encoded private key pemBytes. This can be used
+// when the key is retrieved from a running core.
+func LoadKey(pemBytes []byte) error {
+	return setKey(pemBytes)
+}
+
+// LoadKeyFile will load a PEM encoded private key from path. This can be used
+// when running checks outside a cluster.
+func LoadKeyFile(path string) error {
+	pemBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return setKey(pemBytes)
+}
+
+// setKey will parse and use pemBytes as private key.
+func setKey(pemBytes []byte) error {
+	s, err := ssh.ParsePrivateKey(pemBytes)
+	if err != nil {
+		return err
+	}
+
+	signerLock.Lock()
+	signer = s
+	signerLock.Unlock()
+
+	return nil
+}
+
+// PostApply implements database.Listener. This will make sure that all nodes
+// will use the key generated by the leader.
+func (k KeyListener) PostApply(_ bool, command database.Command, data interface{}) {
+	ks, isKey := data.(*keyStorage)
+	if !isKey || command != database.CommandSave {
+		return
+	}
+
+	err := setKey(ks.PemBytes)
+	if err != nil {
+		logger.Info("ssh", "Failed to parse private key: %s", err.Error())
+	}
+}
+
 // PublicKey will generate a public key formatted for use in ~/.ssh/authorized_keys.
 func PublicKey() string {
 	signerLock.Lock()
@@ -154,6 +229,12 @@ func (s *SSH) Dial(network string, address string) (net.Conn, error) {
 
 // Exec executes a binary on the remote host.
 func (s *SSH) Exec(cmd string, arguments ...string) (io.Reader, io.Reader, error) {
+	return s.ExecContext(context.Background(), cmd, arguments...)
+}
+
+// ExecContext implements transports.ContextTransport. When ctx is done, the
+// remote command is signalled and the session closed.
+func (s *SSH) ExecContext(ctx context.Context, cmd string, arguments ...string) (io.Reader, io.Reader, error) {
 	for _, arg := range arguments {
 		cmd += " " + arg
 	}
@@ -175,7 +256,23 @@ func (s *SSH) Exec(cmd string, arguments ...string) (io.Reader, io.Reader, error
 	session.Stdout = &stdoutBuf
 	session.Stderr = &stderrBuf
 
+	exited := make(chan struct{})
+	defer close(exited)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			session.Signal(ssh.SIGKILL)
+			session.Close()
+		case <-exited:
+		}
+	}()
+
 	err = session.Run(cmd)
+	if err != nil && ctx.Err() != nil {
+		return &stdoutBuf, &stderrBuf, ctx.Err()
+	}
+
 	if err != nil {
 		return &stdoutBuf, &stderrBuf, err
 	}
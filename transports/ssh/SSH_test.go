@@ -1,11 +1,14 @@
 package ssh
 
 import (
+	"io/ioutil"
+	"os"
 	"reflect"
 	"testing"
 	"time"
 
 	"github.com/gansoi/gansoi/boltdb"
+	"github.com/gansoi/gansoi/database"
 	"github.com/gansoi/gansoi/transports"
 
 	"golang.org/x/crypto/ssh"
@@ -269,3 +272,109 @@ func TestSSHReadFile(t *testing.T) {
 }
 
 var _ transports.Transport = (*SSH)(nil)
+var _ transports.ContextTransport = (*SSH)(nil)
+
+func TestLoad(t *testing.T) {
+	db := boltdb.NewTestStore()
+	defer db.Close()
+
+	signer = nil
+
+	err := Load(db)
+	if err == nil {
+		t.Fatalf("Load() did not fail with no key")
+	}
+
+	ks := keyStorage{
+		ID:       "rsa-key",
+		PemBytes: generateKey(),
+	}
+	db.Save(&ks)
+
+	err = Load(db)
+	if err != nil {
+		t.Fatalf("Load() failed: %s", err.Error())
+	}
+
+	if PublicKey() == "" {
+		t.Fatalf("Load() did not set the key")
+	}
+}
+
+func TestLoadKeyFile(t *testing.T) {
+	signer = nil
+
+	err := LoadKeyFile("/does/not/exist")
+	if err == nil {
+		t.Fatalf("LoadKeyFile() did not fail for missing file")
+	}
+
+	f, _ := ioutil.TempFile(os.TempDir(), "TestLoadKeyFile")
+	defer os.Remove(f.Name())
+
+	f.Write(generateKey())
+	f.Close()
+
+	err = LoadKeyFile(f.Name())
+	if err != nil {
+		t.Fatalf("LoadKeyFile() failed: %s", err.Error())
+	}
+
+	if PublicKey() == "" {
+		t.Fatalf("LoadKeyFile() did not set the key")
+	}
+}
+
+func TestKeyPEMLoadKey(t *testing.T) {
+	db := boltdb.NewTestStore()
+	defer db.Close()
+
+	signer = nil
+
+	_, err := KeyPEM(db)
+	if err == nil {
+		t.Fatalf("KeyPEM() did not fail with no key")
+	}
+
+	ks := keyStorage{
+		ID:       "rsa-key",
+		PemBytes: generateKey(),
+	}
+	db.Save(&ks)
+
+	pemBytes, err := KeyPEM(db)
+	if err != nil {
+		t.Fatalf("KeyPEM() failed: %s", err.Error())
+	}
+
+	err = LoadKey([]byte("garbage"))
+	if err == nil {
+		t.Fatalf("LoadKey() did not fail for garbage")
+	}
+
+	err = LoadKey(pemBytes)
+	if err != nil {
+		t.Fatalf("LoadKey() failed: %s", err.Error())
+	}
+
+	if PublicKey() == "" {
+		t.Fatalf("LoadKey() did not set the key")
+	}
+}
+
+func TestKeyListener(t *testing.T) {
+	signer = nil
+
+	KeyListener{}.PostApply(false, database.CommandSave, &keyStorage{PemBytes: []byte("garbage")})
+	if PublicKey() != "" {
+		t.Fatalf("KeyListener accepted an invalid key")
+	}
+
+	KeyListener{}.PostApply(false, database.CommandSave, nil)
+	KeyListener{}.PostApply(false, database.CommandSave, &keyStorage{PemBytes: generateKey()})
+	if PublicKey() == "" {
+		t.Fatalf("KeyListener did not set the key")
+	}
+}
+
+var _ database.Listener = KeyListener{}
@@ -33,7 +33,7 @@ func (c *connection) count() int {
 
 var (
 	poolLock sync.Mutex
-	pool     = make(map[SSH]*connection)
+	pool     = make(map[string]*connection)
 
 	closeAfter = time.Second * 30
 )
@@ -48,13 +48,13 @@ func loop() {
 	for t := range ticker {
 		poolLock.Lock()
 
-		for s, conn := range pool {
+		for key, conn := range pool {
 			if conn.count() == 0 {
 				conn.connectLock.Lock()
 				if conn.client != nil && t.Sub(conn.lastUse) > closeAfter {
 					conn.client.Close()
 					conn.client = nil
-					logger.Debug("ssh", "Closing unused connection %s", s.Address)
+					logger.Debug("ssh", "Closing unused connection %s", key)
 				}
 
 				conn.connectLock.Unlock()
@@ -67,12 +67,12 @@ func loop() {
 
 func connect(s SSH) (*ssh.Client, error) {
 	poolLock.Lock()
-	conn, found := pool[s]
+	conn, found := pool[s.poolKey()]
 	if !found {
 		conn = &connection{}
 		conn.ref()
 
-		pool[s] = conn
+		pool[s.poolKey()] = conn
 	}
 
 	poolLock.Unlock()
@@ -98,12 +98,17 @@ func connect(s SSH) (*ssh.Client, error) {
 	return client, nil
 }
 
+// poolKey returns the key used for identifying a connection in the pool.
+func (s *SSH) poolKey() string {
+	return s.ID + ":" + s.Username + "@" + defaultPort(s.Address)
+}
+
 // done returns a SSH connection to the pool.
 func done(s SSH) {
 	poolLock.Lock()
 	defer poolLock.Unlock()
 
-	conn, found := pool[s]
+	conn, found := pool[s.poolKey()]
 	if found && conn.client != nil {
 		conn.lastUse = time.Now()
 		conn.unref()
@@ -0,0 +1,295 @@
+package tsdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"expvar"
+	"math"
+	"os"
+	"path"
+	"syscall"
+	"time"
+
+	"go.etcd.io/bbolt"
+
+	"github.com/gansoi/gansoi/checks"
+)
+
+type (
+	// Store is an embedded time-series store for numeric values returned by
+	// agents. Each check/host pair has a series per numeric key.
+	//
+	// Results are not replicated, so each node records the results it
+	// produces itself. This keeps the history when leadership changes. Queries
+	// are answered by asking all live nodes, see SetPeers().
+	Store struct {
+		db        *bbolt.DB
+		retention time.Duration
+		peers     Peers
+	}
+
+	// Peers should ask all other live nodes to answer req. Nodes failing to
+	// answer can be left out.
+	Peers func(req *Request) []*Response
+
+	// Request is a query for the keys of a check/host pair, or for the
+	// points of a single key if Key is set.
+	Request struct {
+		CheckHostID string    `json:"checkhostid"`
+		Key         string    `json:"key"`
+		From        time.Time `json:"from"`
+		To          time.Time `json:"to"`
+	}
+
+	// Response is the answer to a Request from a single node.
+	Response struct {
+		Found  bool     `json:"found"`
+		Keys   []string `json:"keys"`
+		Points []Point  `json:"points"`
+	}
+
+	// Point is a single value in a series.
+	Point struct {
+		Time  time.Time `json:"time"`
+		Value float64   `json:"value"`
+	}
+)
+
+var (
+	// ErrNoSeries will be returned when querying a series not known.
+	ErrNoSeries = errors.New("series not found")
+
+	seriesBucket = []byte("series")
+
+	epoch = time.Unix(0, 0)
+
+	recorded = expvar.NewInt("tsdb_recorded")
+	pruned   = expvar.NewInt("tsdb_pruned")
+)
+
+// NewStore will open a new Store. path will be created if it doesn't exist.
+// Points older than retention will be pruned. A zero retention keeps points
+// forever.
+func NewStore(filepath string, retention time.Duration) (*Store, error) {
+	db, err := bbolt.Open(filepath, 0600, &bbolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	var st syscall.Stat_t
+
+	// If possible, set owner to the same as parent directory. Fail silently.
+	if syscall.Stat(path.Dir(filepath), &st) == nil {
+		os.Chown(filepath, int(st.Uid), int(st.Gid))
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(seriesBucket)
+
+		return err
+	})
+	if err != nil {
+		db.Close()
+
+		return nil, err
+	}
+
+	return &Store{
+		db:        db,
+		retention: retention,
+	}, nil
+}
+
+// Close will close the store.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// SetPeers will make queries include results recorded on other nodes.
+func (s *Store) SetPeers(peers Peers) {
+	s.peers = peers
+}
+
+// Record will save all numeric values from result.
+func (s *Store) Record(result *checks.CheckResult) error {
+	values := result.Results.Numeric()
+	if len(values) == 0 {
+		return nil
+	}
+
+	checkHostID := result.CheckHostID
+	if checkHostID == "" {
+		checkHostID = checks.CheckHostID(result.CheckID, result.HostID)
+	}
+
+	ts := encodeTime(result.TimeStamp)
+	cutoff := encodeTime(result.TimeStamp.Add(-s.retention))
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		series, err := tx.Bucket(seriesBucket).CreateBucketIfNotExists([]byte(checkHostID))
+		if err != nil {
+			return err
+		}
+
+		for key, value := range values {
+			b, err := series.CreateBucketIfNotExists([]byte(key))
+			if err != nil {
+				return err
+			}
+
+			err = b.Put(ts, encodeValue(value))
+			if err != nil {
+				return err
+			}
+
+			recorded.Add(1)
+
+			if s.retention > 0 {
+				err = prune(b, cutoff)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// prune will delete all points before cutoff from b.
+func prune(b *bbolt.Bucket, cutoff []byte) error {
+	var old [][]byte
+
+	c := b.Cursor()
+	for k, _ := c.First(); k != nil && string(k) < string(cutoff); k, _ = c.Next() {
+		old = append(old, k)
+	}
+
+	for _, k := range old {
+		err := b.Delete(k)
+		if err != nil {
+			return err
+		}
+
+		pruned.Add(1)
+	}
+
+	return nil
+}
+
+// Keys will return the numeric keys recorded for checkHostID.
+func (s *Store) Keys(checkHostID string) ([]string, error) {
+	var keys []string
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		series := tx.Bucket(seriesBucket).Bucket([]byte(checkHostID))
+		if series == nil {
+			return ErrNoSeries
+		}
+
+		return series.ForEach(func(k []byte, _ []byte) error {
+			keys = append(keys, string(k))
+
+			return nil
+		})
+	})
+
+	return keys, err
+}
+
+// Query will return the points for key in checkHostID between from and to,
+// both inclusive. If step is positive, points will be averaged in windows
+// of step length. Windows without any points are left out.
+func (s *Store) Query(checkHostID string, key string, from time.Time, to time.Time, step time.Duration) ([]Point, error) {
+	points := make([]Point, 0)
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		series := tx.Bucket(seriesBucket).Bucket([]byte(checkHostID))
+		if series == nil {
+			return ErrNoSeries
+		}
+
+		b := series.Bucket([]byte(key))
+		if b == nil {
+			return ErrNoSeries
+		}
+
+		max := string(encodeTime(to))
+
+		c := b.Cursor()
+		for k, v := c.Seek(encodeTime(from)); k != nil && string(k) <= max; k, v = c.Next() {
+			points = append(points, Point{
+				Time:  decodeTime(k),
+				Value: decodeValue(v),
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if step > 0 {
+		points = downsample(points, step)
+	}
+
+	return points, nil
+}
+
+// downsample will average points in windows of step length. points must be
+// sorted by time.
+func downsample(points []Point, step time.Duration) []Point {
+	result := make([]Point, 0)
+
+	var sum float64
+	var count int
+	var window time.Time
+
+	for _, p := range points {
+		t := p.Time.Truncate(step)
+
+		if count > 0 && !t.Equal(window) {
+			result = append(result, Point{Time: window, Value: sum / float64(count)})
+			sum, count = 0, 0
+		}
+
+		window = t
+		sum += p.Value
+		count++
+	}
+
+	if count > 0 {
+		result = append(result, Point{Time: window, Value: sum / float64(count)})
+	}
+
+	return result
+}
+
+// encodeTime will encode t as a sortable key. Keys are unsigned, times
+// before the epoch will be encoded as the epoch.
+func encodeTime(t time.Time) []byte {
+	if t.Before(epoch) {
+		t = epoch
+	}
+
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(t.UnixNano()))
+
+	return b
+}
+
+func decodeTime(b []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(b)))
+}
+
+func encodeValue(f float64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, math.Float64bits(f))
+
+	return b
+}
+
+func decodeValue(b []byte) float64 {
+	return math.Float64frombits(binary.BigEndian.Uint64(b))
+}
@@ -0,0 +1,307 @@
+package tsdb
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/gansoi/gansoi/checks"
+	"github.com/gansoi/gansoi/plugins"
+)
+
+func newTestStore(t *testing.T, retention time.Duration) *Store {
+	dir, err := os.MkdirTemp("", "tsdb")
+	if err != nil {
+		t.Fatalf("MkdirTemp() failed: %s", err.Error())
+	}
+
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	s, err := NewStore(path.Join(dir, "metrics.db"), retention)
+	if err != nil {
+		t.Fatalf("NewStore() failed: %s", err.Error())
+	}
+
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+func result(clock time.Time, values map[string]interface{}) *checks.CheckResult {
+	r := &checks.CheckResult{
+		CheckHostID: checks.CheckHostID("check", "host"),
+		CheckID:     "check",
+		HostID:      "host",
+		TimeStamp:   clock,
+		Results:     plugins.NewAgentResult(),
+	}
+
+	for key, value := range values {
+		r.Results.AddValue(key, value)
+	}
+
+	return r
+}
+
+func TestNewStoreFail(t *testing.T) {
+	_, err := NewStore("/dev/null/metrics.db", 0)
+	if err == nil {
+		t.Fatalf("NewStore() failed to detect error")
+	}
+}
+
+func TestStoreRecordQuery(t *testing.T) {
+	s := newTestStore(t, 0)
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 10; i++ {
+		err := s.Record(result(start.Add(time.Duration(i)*time.Minute), map[string]interface{}{
+			"latency": i,
+			"name":    "not numeric",
+		}))
+		if err != nil {
+			t.Fatalf("Record() failed: %s", err.Error())
+		}
+	}
+
+	keys, err := s.Keys(checks.CheckHostID("check", "host"))
+	if err != nil {
+		t.Fatalf("Keys() failed: %s", err.Error())
+	}
+
+	if len(keys) != 1 || keys[0] != "latency" {
+		t.Fatalf("Keys() returned wrong keys: %v", keys)
+	}
+
+	cases := []struct {
+		from     time.Time
+		to       time.Time
+		step     time.Duration
+		expected []float64
+	}{
+		{start, start.Add(time.Hour), 0, []float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}},
+		{start.Add(2 * time.Minute), start.Add(4 * time.Minute), 0, []float64{2, 3, 4}},
+		{start, start.Add(time.Hour), 5 * time.Minute, []float64{2, 7}},
+		{start.Add(8 * time.Minute), start.Add(time.Hour), 5 * time.Minute, []float64{8.5}},
+		{time.Time{}, start.Add(time.Minute), 0, []float64{0, 1}},
+		{start.Add(time.Hour), start.Add(2 * time.Hour), 0, []float64{}},
+	}
+
+	for i, c := range cases {
+		points, err := s.Query(checks.CheckHostID("check", "host"), "latency", c.from, c.to, c.step)
+		if err != nil {
+			t.Fatalf("%d: Query() failed: %s", i, err.Error())
+		}
+
+		if len(points) != len(c.expected) {
+			t.Fatalf("%d: Query() returned %d points, expected %d", i, len(points), len(c.expected))
+		}
+
+		for j, p := range points {
+			if p.Value != c.expected[j] {
+				t.Errorf("%d: Point %d is %f, expected %f", i, j, p.Value, c.expected[j])
+			}
+		}
+	}
+
+	_, err = s.Query(checks.CheckHostID("check", "host"), "unknown", start, start, 0)
+	if err != ErrNoSeries {
+		t.Fatalf("Query() did not return ErrNoSeries for unknown key, got %v", err)
+	}
+
+	_, err = s.Query("unknown", "latency", start, start, 0)
+	if err != ErrNoSeries {
+		t.Fatalf("Query() did not return ErrNoSeries for unknown series, got %v", err)
+	}
+}
+
+func TestStoreRetention(t *testing.T) {
+	s := newTestStore(t, time.Hour)
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		s.Record(result(start.Add(time.Duration(i)*time.Hour), map[string]interface{}{"value": i}))
+	}
+
+	points, _ := s.Query(checks.CheckHostID("check", "host"), "value", start, start.Add(3*time.Hour), 0)
+	if len(points) != 2 {
+		t.Fatalf("Retention not respected, got %d points, expected 2", len(points))
+	}
+}
+
+func TestStoreQueryHandler(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+
+	s := newTestStore(t, 0)
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 4; i++ {
+		s.Record(result(start.Add(time.Duration(i)*time.Minute), map[string]interface{}{"value": i}))
+	}
+
+	router := gin.New()
+	s.Router(router.Group("/metrics"))
+
+	cases := []struct {
+		query  string
+		status int
+		points int
+	}{
+		{"", http.StatusBadRequest, 0},
+		{"?check=check&host=host&key=value&from=2020-01-01T00:00:00Z&to=1577836860", http.StatusOK, 2},
+		{"?check=check&host=host&key=value&to=2020-01-01T01:00:00Z&step=2m", http.StatusOK, 2},
+		{"?check=check&host=host&key=value&from=yesterday", http.StatusBadRequest, 0},
+		{"?check=check&host=host&key=value&to=tomorrow", http.StatusBadRequest, 0},
+		{"?check=check&host=host&key=value&step=-1s", http.StatusBadRequest, 0},
+		{"?check=check&host=host&key=unknown", http.StatusNotFound, 0},
+	}
+
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/metrics"+c.query, nil)
+		router.ServeHTTP(w, req)
+
+		if w.Code != c.status {
+			t.Fatalf("%s: Got status %d, expected %d", c.query, w.Code, c.status)
+		}
+
+		if c.status != http.StatusOK {
+			continue
+		}
+
+		var points []Point
+		json.Unmarshal(w.Body.Bytes(), &points)
+		if len(points) != c.points {
+			t.Fatalf("%s: Got %d points, expected %d", c.query, len(points), c.points)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/metrics?check=check&host=host", nil)
+	router.ServeHTTP(w, req)
+
+	var keys []string
+	json.Unmarshal(w.Body.Bytes(), &keys)
+	if len(keys) != 1 || keys[0] != "value" {
+		t.Fatalf("Failed to list keys, got %v", keys)
+	}
+}
+
+func TestStoreQueryHandlerPeers(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+
+	s := newTestStore(t, 0)
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	s.Record(result(start.Add(time.Minute), map[string]interface{}{"local": 1}))
+
+	s.SetPeers(func(req *Request) []*Response {
+		return []*Response{
+			nil,
+			{Found: false},
+			{
+				Found: true,
+				Keys:  []string{"remote", "local"},
+				Points: []Point{
+					{Time: start, Value: 0},
+					{Time: start.Add(2 * time.Minute), Value: 2},
+				},
+			},
+		}
+	})
+
+	router := gin.New()
+	s.Router(router.Group("/metrics"))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/metrics?check=check&host=host&key=local&from=2020-01-01T00:00:00Z&to=2020-01-01T00:10:00Z", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Got status %d, expected %d", w.Code, http.StatusOK)
+	}
+
+	var points []Point
+	json.Unmarshal(w.Body.Bytes(), &points)
+	if len(points) != 3 || points[0].Value != 0 || points[1].Value != 1 || points[2].Value != 2 {
+		t.Fatalf("Failed to merge points from peers, got %v", points)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/metrics?check=check&host=host", nil)
+	router.ServeHTTP(w, req)
+
+	var keys []string
+	json.Unmarshal(w.Body.Bytes(), &keys)
+	if len(keys) != 2 || keys[0] != "local" || keys[1] != "remote" {
+		t.Fatalf("Failed to merge keys from peers, got %v", keys)
+	}
+
+	// A series only known by a peer should be found.
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/metrics?check=check&host=other&key=remote", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Got status %d for series known by peer, expected %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestStoreNodeRouter(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+
+	s := newTestStore(t, 0)
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	s.Record(result(start, map[string]interface{}{"value": 1}))
+
+	// Peers must not be asked when answering for this node only.
+	s.SetPeers(func(req *Request) []*Response {
+		t.Fatalf("NodeRouter asked peers")
+		return nil
+	})
+
+	router := gin.New()
+	s.NodeRouter(router.Group("/metrics"))
+
+	cases := []struct {
+		body   string
+		status int
+		found  bool
+		keys   int
+		points int
+	}{
+		{`{`, http.StatusBadRequest, false, 0, 0},
+		{`{"checkhostid":"` + checks.CheckHostID("check", "host") + `"}`, http.StatusOK, true, 1, 0},
+		{`{"checkhostid":"` + checks.CheckHostID("check", "host") + `","key":"value","from":"2020-01-01T00:00:00Z","to":"2020-01-01T01:00:00Z"}`, http.StatusOK, true, 0, 1},
+		{`{"checkhostid":"` + checks.CheckHostID("check", "host") + `","key":"unknown"}`, http.StatusOK, false, 0, 0},
+		{`{"checkhostid":"unknown"}`, http.StatusOK, false, 0, 0},
+	}
+
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/metrics", strings.NewReader(c.body))
+		router.ServeHTTP(w, req)
+
+		if w.Code != c.status {
+			t.Fatalf("%s: Got status %d, expected %d", c.body, w.Code, c.status)
+		}
+
+		if c.status != http.StatusOK {
+			continue
+		}
+
+		var resp Response
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if resp.Found != c.found || len(resp.Keys) != c.keys || len(resp.Points) != c.points {
+			t.Fatalf("%s: Got wrong response %+v", c.body, resp)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package tsdb
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/gansoi/gansoi/checks"
+)
+
+const (
+	// defaultRange is the range queried if from is not given.
+	defaultRange = 24 * time.Hour
+)
+
+// Router will mount the query endpoint on router. The endpoint accepts the
+// query parameters check, host, key, from, to and step. If key is left out,
+// the keys known for the check/host pair will be returned instead. Results
+// recorded on other nodes are included.
+func (s *Store) Router(router *gin.RouterGroup) {
+	router.GET("", s.queryHandler)
+}
+
+// NodeRouter will mount the endpoint used by other nodes to query results
+// recorded on this node.
+func (s *Store) NodeRouter(router *gin.RouterGroup) {
+	router.POST("", func(c *gin.Context) {
+		var req Request
+
+		err := c.BindJSON(&req)
+		if err != nil {
+			return
+		}
+
+		resp, err := s.Answer(&req)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, resp)
+	})
+}
+
+func (s *Store) queryHandler(c *gin.Context) {
+	checkID := c.Query("check")
+	if checkID == "" {
+		c.AbortWithError(http.StatusBadRequest, fmt.Errorf("check is required"))
+		return
+	}
+
+	req := &Request{
+		CheckHostID: checks.CheckHostID(checkID, c.Query("host")),
+		Key:         c.Query("key"),
+	}
+
+	if req.Key == "" {
+		resp, err := s.collect(req)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, resp.Keys)
+		return
+	}
+
+	var err error
+
+	req.To, err = parseTime(c.Query("to"), time.Now())
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	req.From, err = parseTime(c.Query("from"), req.To.Add(-defaultRange))
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	var step time.Duration
+	if c.Query("step") != "" {
+		step, err = time.ParseDuration(c.Query("step"))
+		if err != nil || step < 0 {
+			c.AbortWithError(http.StatusBadRequest, fmt.Errorf("invalid step '%s'", c.Query("step")))
+			return
+		}
+	}
+
+	resp, err := s.collect(req)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	if !resp.Found {
+		c.AbortWithError(http.StatusNotFound, ErrNoSeries)
+		return
+	}
+
+	points := resp.Points
+	if step > 0 {
+		points = downsample(points, step)
+	}
+
+	c.JSON(http.StatusOK, points)
+}
+
+// Answer will answer req using results recorded on this node only.
+func (s *Store) Answer(req *Request) (*Response, error) {
+	resp := &Response{
+		Keys:   []string{},
+		Points: []Point{},
+	}
+
+	var err error
+
+	if req.Key == "" {
+		var keys []string
+
+		keys, err = s.Keys(req.CheckHostID)
+		if err == nil {
+			resp.Keys = keys
+		}
+	} else {
+		var points []Point
+
+		points, err = s.Query(req.CheckHostID, req.Key, req.From, req.To, 0)
+		if err == nil {
+			resp.Points = points
+		}
+	}
+
+	if err == ErrNoSeries {
+		return resp, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Found = true
+
+	return resp, nil
+}
+
+// collect will answer req using results recorded on all nodes. Keys are
+// merged and points are sorted by time.
+func (s *Store) collect(req *Request) (*Response, error) {
+	resp, err := s.Answer(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.peers == nil {
+		return resp, nil
+	}
+
+	seen := make(map[string]bool)
+	for _, key := range resp.Keys {
+		seen[key] = true
+	}
+
+	for _, remote := range s.peers(req) {
+		if remote == nil || !remote.Found {
+			continue
+		}
+
+		resp.Found = true
+		resp.Points = append(resp.Points, remote.Points...)
+
+		for _, key := range remote.Keys {
+			if !seen[key] {
+				seen[key] = true
+				resp.Keys = append(resp.Keys, key)
+			}
+		}
+	}
+
+	sort.Strings(resp.Keys)
+
+	sort.SliceStable(resp.Points, func(i, j int) bool {
+		return resp.Points[i].Time.Before(resp.Points[j].Time)
+	})
+
+	return resp, nil
+}
+
+// parseTime will parse value as either RFC3339 or seconds since the epoch.
+// If value is empty, def is returned.
+func parseTime(value string, def time.Time) (time.Time, error) {
+	if value == "" {
+		return def, nil
+	}
+
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time '%s'", value)
+	}
+
+	return t, nil
+}
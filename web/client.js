@@ -314,7 +314,8 @@ var editCheck = Vue.component('edit-check', {
                 name: '',
                 expressions: []
             },
-            results: {results: {}},
+            results: {},
+            allNodes: false,
             lastEvaluations: lastEvaluations
         };
     },
@@ -361,7 +362,12 @@ var editCheck = Vue.component('edit-check', {
         testCheck: function() {
             removeEmptyStrings(this.check.arguments);
 
-            this.$http.post('/api/test', this.check).then(function(response) {
+            var url = '/api/test';
+            if (this.allNodes) {
+                url += '?nodes=all';
+            }
+
+            this.$http.post(url, this.check).then(function(response) {
                 this.results = response.body;
             });
         },
@@ -50,6 +50,7 @@ g.Collection = function(identifier) {
                 self.deleteId(log.data[identifier]);
                 break;
             case 'save':
+            case 'forward':
                 self.upsert(log.data);
                 break;
             default:
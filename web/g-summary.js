@@ -13,6 +13,9 @@ g.Summary = function(audio) {
     self.states = {
         'unknown': '-',
         'up': '-',
+        'warning': '-',
+        'critical': '-',
+        'unreachable': '-',
         'down': '-'
     };
 
@@ -20,6 +23,9 @@ g.Summary = function(audio) {
         self.checks = log.data.checks;
         self.states.unknown = log.data.states.unknown;
         self.states.up = log.data.states.up;
+        self.states.warning = log.data.states.warning;
+        self.states.critical = log.data.states.critical;
+        self.states.unreachable = log.data.states.unreachable;
         self.states.down = log.data.states.down;
 
         var message = self.message();
@@ -35,11 +41,14 @@ g.Summary = function(audio) {
             return 'checks-up';
         }
 
-        if (self.states.down === 1) {
+        // Critical checks are as bad as down checks.
+        var down = self.states.down + self.states.critical;
+
+        if (down === 1) {
             return 'check-down';
         }
 
-        if (self.states.down > 1) {
+        if (down > 1) {
             return 'checks-down';
         }
     };